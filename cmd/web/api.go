@@ -0,0 +1,366 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"sabiraliyev.net/snippetbox/pkg/forms"
+	"sabiraliyev.net/snippetbox/pkg/models"
+)
+
+// The API lists snippets a page at a time; clients can ask for pages of up to maxAPIPageSize.
+const maxAPIPageSize = 100
+
+// The apiPrefix is the start of the path of every JSON API route.
+const apiPrefix = "/api/"
+
+// An apiError is the body of every error response from the JSON API. Fields lists the problems
+// with each field of a snippet which failed validation.
+type apiError struct {
+	Error apiErrorDetail `json:"error"`
+}
+
+type apiErrorDetail struct {
+	Status  int                 `json:"status"`
+	Message string              `json:"message"`
+	Fields  map[string][]string `json:"fields,omitempty"`
+}
+
+// An apiSnippet is a snippet as the API sends it. Expires shadows the field of the same name in
+// models.Snippet, so snippets which never expire have a null expiry rather than the zero time, and
+// URL is the address of the snippet`s page.
+type apiSnippet struct {
+	*models.Snippet
+	Expires *time.Time `json:"expires"`
+	URL     string     `json:"url"`
+}
+
+// An apiSnippetList is one page of a snippet listing. Next and Prev are cursors to pass back as
+// the after and before query parameters, to fetch the neighbouring pages.
+type apiSnippetList struct {
+	Snippets []*apiSnippet `json:"snippets"`
+	Next     string        `json:"next,omitempty"`
+	Prev     string        `json:"prev,omitempty"`
+}
+
+// An apiSnippetInput is the body of a request creating or updating a snippet. Only the title,
+// filename, content and files can be changed by an update. Expires takes the same values as the
+// paste endpoint: a short duration like 12h, a number of days, "never", "burn" or "views" (with
+// MaxViews).
+type apiSnippetInput struct {
+	Title      string         `json:"title"`
+	Filename   string         `json:"filename"`
+	Content    string         `json:"content"`
+	Language   string         `json:"language"`
+	Visibility string         `json:"visibility"`
+	Tags       []string       `json:"tags"`
+	Expires    string         `json:"expires"`
+	MaxViews   int            `json:"max_views"`
+	Files      []*models.File `json:"files"`
+}
+
+// The values method turns the input into form values, so it can be validated by the same code as
+// the create and edit forms. Snippets are public and expire after a year unless asked otherwise,
+// just like in the create form.
+func (in *apiSnippetInput) values() url.Values {
+	values := url.Values{
+		"title":      {in.Title},
+		"filename":   {in.Filename},
+		"content":    {in.Content},
+		"language":   {in.Language},
+		"visibility": {firstNonEmpty(in.Visibility, models.VisibilityPublic)},
+		"tags":       {strings.Join(in.Tags, ",")},
+		"expires":    {firstNonEmpty(in.Expires, "365")},
+	}
+	if in.MaxViews != 0 {
+		values.Set("max_views", strconv.Itoa(in.MaxViews))
+	}
+	for _, f := range in.Files {
+		if f == nil {
+			f = &models.File{}
+		}
+		values.Add("file_name", f.Name)
+		values.Add("file_language", f.Language)
+		values.Add("file_content", f.Content)
+	}
+	shortExpiry(values)
+	return values
+}
+
+// The apiListSnippets handler sends a page of public snippets. The sort, after and before query
+// parameters work as they do on the home page, and limit sets the size of the page.
+func (app *application) apiListSnippets(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	limit := pageSize
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxAPIPageSize {
+			app.apiError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxAPIPageSize))
+			return
+		}
+		limit = n
+	}
+
+	page, err := app.snippets.List(models.ListOptions{
+		Sort:   firstNonEmpty(query.Get("sort"), models.SortNewest),
+		After:  query.Get("after"),
+		Before: query.Get("before"),
+		Limit:  limit,
+		Tag:    query.Get("tag"),
+	})
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCursor) {
+			app.apiError(w, http.StatusBadRequest, "invalid sort order or cursor")
+		} else {
+			app.apiServerError(w, err)
+		}
+		return
+	}
+
+	list := &apiSnippetList{Snippets: []*apiSnippet{}, Next: page.Next, Prev: page.Prev}
+	for _, s := range page.Snippets {
+		list.Snippets = append(list.Snippets, newAPISnippet(r, s))
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// The apiShowSnippet handler sends a snippet along with all of its further files.
+func (app *application) apiShowSnippet(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.apiSnippetFromURL(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, newAPISnippet(r, s))
+}
+
+// The apiCreateSnippet handler creates a snippet owned by the user the API token belongs to. It
+// responds with the new snippet and its API address in the Location header.
+func (app *application) apiCreateSnippet(w http.ResponseWriter, r *http.Request) {
+	var in apiSnippetInput
+	if !app.decodeJSON(w, r, &in) {
+		return
+	}
+
+	maxExpiry, err := app.maxExpiry(r)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	form := forms.New(in.values())
+	s := newSnippet(form, maxExpiry, app.authenticatedUserID(r))
+	if !form.Valid() {
+		app.apiValidationError(w, form)
+		return
+	}
+
+	err = app.snippets.Insert(s)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+	app.events.publish(eventSnippetCreated, s)
+
+	w.Header().Set("Location", fmt.Sprintf("%sv1/snippets/%d", apiPrefix, s.ID))
+	writeJSON(w, http.StatusCreated, newAPISnippet(r, s))
+}
+
+// The apiUpdateSnippet handler replaces the title, filename, content and further files of one of
+// the user`s snippets, recording a new revision, and responds with the updated snippet.
+func (app *application) apiUpdateSnippet(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.apiSnippetFromURL(w, r)
+	if !ok {
+		return
+	}
+	if !app.isOwner(r, s) {
+		app.apiError(w, http.StatusForbidden, "only the owner of a snippet can change it")
+		return
+	}
+
+	var in apiSnippetInput
+	if !app.decodeJSON(w, r, &in) {
+		return
+	}
+
+	form := forms.New(in.values())
+	edited := editedSnippet(form, s)
+	if !form.Valid() {
+		app.apiValidationError(w, form)
+		return
+	}
+
+	err := app.snippets.Update(edited, app.authenticatedUserID(r))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.apiError(w, http.StatusNotFound, "snippet not found")
+		} else {
+			app.apiServerError(w, err)
+		}
+		return
+	}
+	writeJSON(w, http.StatusOK, newAPISnippet(r, edited))
+}
+
+// The apiDeleteSnippet handler moves a snippet to its owner`s trash. Its owner and administrators
+// can delete it.
+func (app *application) apiDeleteSnippet(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.apiSnippetFromURL(w, r)
+	if !ok {
+		return
+	}
+	if !app.canModify(r, s) {
+		app.apiError(w, http.StatusForbidden, "only the owner of a snippet can delete it")
+		return
+	}
+
+	err := app.snippets.Delete(s.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.apiError(w, http.StatusNotFound, "snippet not found")
+		} else {
+			app.apiServerError(w, err)
+		}
+		return
+	}
+	app.events.publish(eventSnippetDeleted, s)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// The apiCurrentUser handler sends the account of the user the API token belongs to.
+func (app *application) apiCurrentUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(contextKeyTokenUser).(*models.User)
+	if !ok {
+		app.unauthorized(w, r)
+		return
+	}
+	writeJSON(w, http.StatusOK, user)
+}
+
+// The apiSnippetFromURL helper is the API`s version of snippetFromURL. It fetches the snippet
+// identified by the ":id" URL parameter, together with its further files, and sends a JSON error
+// response if there is no such snippet or the user can`t see it by its ID.
+func (app *application) apiSnippetFromURL(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	id, err := strconv.Atoi(r.URL.Query().Get(":id"))
+	if err != nil || id < 1 {
+		app.apiError(w, http.StatusNotFound, "snippet not found")
+		return nil, false
+	}
+
+	s, err := app.snippets.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.apiError(w, http.StatusNotFound, "snippet not found")
+		} else {
+			app.apiServerError(w, err)
+		}
+		return nil, false
+	}
+	if (s.Visibility != models.VisibilityPublic || s.MaxViews > 0) && !app.canModify(r, s) {
+		app.apiError(w, http.StatusNotFound, "snippet not found")
+		return nil, false
+	}
+
+	// The snippet is copied before its files are filled in, so the one returned by the model
+	// isn`t changed.
+	copied := *s
+	copied.Files, err = app.snippets.Files(s.ID)
+	if err != nil {
+		app.apiServerError(w, err)
+		return nil, false
+	}
+	return &copied, true
+}
+
+// The newAPISnippet function prepares a snippet to be sent by the API.
+func newAPISnippet(r *http.Request, s *models.Snippet) *apiSnippet {
+	as := &apiSnippet{Snippet: s, URL: absoluteURL(r, "/s/"+s.Slug)}
+	if !s.Expires.IsZero() {
+		as.Expires = &s.Expires
+	}
+	if s.Tags == nil {
+		copied := *s
+		copied.Tags = []string{}
+		as.Snippet = &copied
+	}
+	return as
+}
+
+// The decodeJSON helper reads a JSON request body into dst. Unknown fields are refused, so that
+// typos don`t go unnoticed. If the body can`t be decoded, a 400 Bad Request (or 413 Request Entity
+// Too Large) response is sent and false is returned.
+func (app *application) decodeJSON(w http.ResponseWriter, r *http.Request, dst interface{}) bool {
+	// A snippet`s files together may be bigger than a single paste, and JSON escaping adds more.
+	r.Body = http.MaxBytesReader(w, r.Body, 4*maxPasteSize)
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(dst)
+	if err == nil && dec.Decode(&struct{}{}) != io.EOF {
+		err = errors.New("body must contain a single JSON value")
+	}
+	if err != nil {
+		if err.Error() == "http: request body too large" {
+			app.apiError(w, http.StatusRequestEntityTooLarge, "request body too large")
+		} else {
+			app.apiError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		}
+		return false
+	}
+	return true
+}
+
+// The writeJSON function sends v as a JSON response with the given status code.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+// The apiError helper sends a JSON error response with the given status code and message.
+func (app *application) apiError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, &apiError{apiErrorDetail{Status: status, Message: message}})
+}
+
+// The apiValidationError helper sends a 422 Unprocessable Entity response listing the problems
+// with each field of a form.
+func (app *application) apiValidationError(w http.ResponseWriter, form *forms.Form) {
+	status := http.StatusUnprocessableEntity
+	writeJSON(w, status, &apiError{apiErrorDetail{
+		Status:  status,
+		Message: "the snippet is invalid",
+		Fields:  form.Errors,
+	}})
+}
+
+// The apiServerError helper is the API`s version of serverError: it logs the error with a stack
+// trace and sends a generic 500 Internal Server Error response.
+func (app *application) apiServerError(w http.ResponseWriter, err error) {
+	app.errorLog.Output(2, fmt.Sprintf("%s\n%s", err.Error(), debug.Stack()))
+	app.apiError(w, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+}
+
+// The isAPI function reports whether a request is for the JSON API, and so should get JSON errors.
+func isAPI(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, apiPrefix)
+}
+
+// The routeNotFound handler answers requests which don`t match any route, with a JSON error for
+// API requests and the usual plain text response otherwise.
+func (app *application) routeNotFound(w http.ResponseWriter, r *http.Request) {
+	if isAPI(r) {
+		app.apiError(w, http.StatusNotFound, "no such endpoint")
+		return
+	}
+	app.notFound(w)
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"sabiraliyev.net/snippetbox/pkg/models"
+	"sabiraliyev.net/snippetbox/pkg/models/mock"
+)
+
+// The apiRequest method sends a request to the JSON API, authenticated with token if it isn`t empty.
+func (ts *testServer) apiRequest(t *testing.T, method, urlPath, token, body string) (int, http.Header, []byte) {
+	req, err := http.NewRequest(method, ts.URL+urlPath, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	rs, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Body.Close()
+	b, err := ioutil.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rs.StatusCode, rs.Header, b
+}
+
+func TestAPISnippets(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		method   string
+		urlPath  string
+		token    string
+		body     string
+		wantCode int
+		wantBody string
+	}{
+		{"List", http.MethodGet, "/api/v1/snippets", "", "", http.StatusOK, `"next": "bmV4dA"`},
+		{"List bad cursor", http.MethodGet, "/api/v1/snippets?after=nonsense", "", "", http.StatusBadRequest, `"status": 400`},
+		{"List bad limit", http.MethodGet, "/api/v1/snippets?limit=1000", "", "", http.StatusBadRequest, `limit must be between 1 and 100`},
+		{"Get", http.MethodGet, "/api/v1/snippets/1", "", "", http.StatusOK, `"name": "frog.sh"`},
+		{"Get private", http.MethodGet, "/api/v1/snippets/3", "", "", http.StatusNotFound, `"message": "snippet not found"`},
+		{"Get missing", http.MethodGet, "/api/v1/snippets/2", "", "", http.StatusNotFound, `"status": 404`},
+		{"Create without token", http.MethodPost, "/api/v1/snippets", "", `{"title": "T", "content": "C"}`, http.StatusUnauthorized, `"status": 401`},
+		{"Create with bad token", http.MethodPost, "/api/v1/snippets", "sb_mallory", `{"title": "T", "content": "C"}`, http.StatusUnauthorized, `"status": 401`},
+		{"Create", http.MethodPost, "/api/v1/snippets", "sb_alice", `{"title": "T", "content": "C", "expires": "2h", "tags": ["Go"]}`, http.StatusCreated, `"go"`},
+		{"Create invalid", http.MethodPost, "/api/v1/snippets", "sb_alice", `{"content": "C", "visibility": "secret"}`, http.StatusUnprocessableEntity, `"visibility": [`},
+		{"Create unknown field", http.MethodPost, "/api/v1/snippets", "sb_alice", `{"title": "T", "body": "C"}`, http.StatusBadRequest, `unknown field`},
+		{"Create malformed", http.MethodPost, "/api/v1/snippets", "sb_alice", `{"title": `, http.StatusBadRequest, `invalid JSON body`},
+		{"Create with read-only token", http.MethodPost, "/api/v1/snippets", "sb_readonly", `{"title": "T", "content": "C"}`, http.StatusForbidden, `doesn` + "`" + `t have the snippets:write scope`},
+		{"Get with read-only token", http.MethodGet, "/api/v1/snippets/1", "sb_readonly", "", http.StatusOK, `"title"`},
+		{"Update", http.MethodPut, "/api/v1/snippets/1", "sb_alice", `{"title": "New title", "content": "C"}`, http.StatusOK, `"title": "New title"`},
+		{"Update without token", http.MethodPut, "/api/v1/snippets/1", "", `{"title": "T", "content": "C"}`, http.StatusUnauthorized, `"status": 401`},
+		{"Delete", http.MethodDelete, "/api/v1/snippets/1", "sb_alice", "", http.StatusNoContent, ""},
+		{"Delete someone else`s", http.MethodDelete, "/api/v1/snippets/3", "sb_alice", "", http.StatusNotFound, `"status": 404`},
+		{"Current user", http.MethodGet, "/api/v1/user", "sb_readonly", "", http.StatusOK, `"administrator": false`},
+		{"Current user without token", http.MethodGet, "/api/v1/user", "", "", http.StatusUnauthorized, `"status": 401`},
+		{"Unknown endpoint", http.MethodGet, "/api/v1/nothing", "", "", http.StatusNotFound, `"message": "no such endpoint"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, header, body := ts.apiRequest(t, tt.method, tt.urlPath, tt.token, tt.body)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d (%s)", tt.wantCode, code, body)
+			}
+			if !bytes.Contains(body, []byte(tt.wantBody)) {
+				t.Errorf("want body to contain %q; got %s", tt.wantBody, body)
+			}
+			if code != http.StatusNoContent && header.Get("Content-Type") != "application/json" {
+				t.Errorf("want a JSON response; got %q", header.Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestAPITokens(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// Expired tokens don`t authenticate, and read-only tokens can`t be used to make changes.
+	code, _, body := ts.apiRequest(t, http.MethodGet, "/api/v1/snippets", "sb_expired", "")
+	if code != http.StatusUnauthorized {
+		t.Errorf("want %d for an expired token; got %d (%s)", http.StatusUnauthorized, code, body)
+	}
+	for _, method := range []string{http.MethodPost, http.MethodPut, http.MethodDelete} {
+		urlPath := "/api/v1/snippets/1"
+		if method == http.MethodPost {
+			urlPath = "/api/v1/snippets"
+		}
+		code, _, body = ts.apiRequest(t, method, urlPath, "sb_readonly", `{"title": "T", "content": "C"}`)
+		if code != http.StatusForbidden {
+			t.Errorf("want %d for %s with a read-only token; got %d (%s)", http.StatusForbidden, method, code, body)
+		}
+	}
+
+	// Using a token records when it was last used.
+	tokens := app.tokens.(*mock.TokenModel)
+	if !tokens.LastUsed(1).IsZero() {
+		t.Fatal("want the token unused to begin with")
+	}
+	code, _, body = ts.apiRequest(t, http.MethodGet, "/api/v1/snippets", "sb_alice", "")
+	if code != http.StatusOK {
+		t.Fatalf("want %d; got %d (%s)", http.StatusOK, code, body)
+	}
+	if tokens.LastUsed(1).IsZero() {
+		t.Error("want the token`s last use recorded")
+	}
+
+	// Once revoked, the token no longer works.
+	form := url.Values{}
+	form.Add("csrf_token", ts.login(t, "alice@example.com"))
+	form.Add("id", "1")
+	code, header, _ := ts.postForm(t, "/user/tokens/revoke", form)
+	if code != http.StatusSeeOther || header.Get("Location") != "/user/tokens" {
+		t.Fatalf("want redirect to /user/tokens; got %d %q", code, header.Get("Location"))
+	}
+	code, _, body = ts.apiRequest(t, http.MethodGet, "/api/v1/snippets", "sb_alice", "")
+	if code != http.StatusUnauthorized {
+		t.Errorf("want %d for a revoked token; got %d (%s)", http.StatusUnauthorized, code, body)
+	}
+}
+
+func TestAPIListHidesSnippets(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// Only public snippets without a view limit are listed, as the listing includes their content.
+	code, _, body := ts.apiRequest(t, http.MethodGet, "/api/v1/snippets", "sb_alice", "")
+	if code != http.StatusOK {
+		t.Fatalf("want %d; got %d (%s)", http.StatusOK, code, body)
+	}
+	for _, hidden := range []string{"Database password", "correct horse", "Draft haiku", "Internal stack trace"} {
+		if bytes.Contains(body, []byte(hidden)) {
+			t.Errorf("want body not to contain %q", hidden)
+		}
+	}
+}
+
+func TestAPISnippetJSON(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, header, body := ts.apiRequest(t, http.MethodPost, "/api/v1/snippets", "sb_alice", `{"title": "T", "content": "C", "expires": "1d"}`)
+	if code != http.StatusCreated {
+		t.Fatalf("want %d; got %d (%s)", http.StatusCreated, code, body)
+	}
+	if want := "/api/v1/snippets/2"; header.Get("Location") != want {
+		t.Errorf("want Location %q; got %q", want, header.Get("Location"))
+	}
+
+	var got map[string]interface{}
+	err := json.Unmarshal(body, &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got["url"] != ts.URL+"/s/newsnippet" {
+		t.Errorf("want url %q; got %v", ts.URL+"/s/newsnippet", got["url"])
+	}
+	if _, ok := got["deleted"]; ok {
+		t.Error("want the deleted flag left out")
+	}
+
+	// Snippets which never expire have a null expiry rather than the zero time.
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/snippets/1", nil)
+	b, err := json.Marshal(newAPISnippet(r, &models.Snippet{ID: 1}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(b, []byte(`"expires":null`)) || !bytes.Contains(b, []byte(`"tags":[]`)) {
+		t.Errorf("want a null expiry and empty tags; got %s", b)
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"sabiraliyev.net/snippetbox/pkg/forms"
+	"sabiraliyev.net/snippetbox/pkg/models"
+	"sabiraliyev.net/snippetbox/pkg/websocket"
+)
+
+// The largest WebSocket message a chat client may send. Chat messages are limited to 1000 characters,
+// which is up to 4000 bytes of UTF-8, plus the JSON around them.
+const maxSocketMessage = 8 << 10
+
+// A chatEvent is what the chat sends to WebSocket clients: either a new message in one of the rooms
+// they can see, or an error about a message the client itself sent.
+type chatEvent struct {
+	Type    string          `json:"type"`
+	Message *models.Message `json:"message,omitempty"`
+	Error   string          `json:"error,omitempty"`
+}
+
+// A chatInput is what WebSocket clients send: either a message to post to a room, or, with a type of
+// "read", the ID of the newest message they have shown in a room.
+type chatInput struct {
+	Type    string `json:"type"`
+	Room    int    `json:"room"`
+	Content string `json:"content"`
+	Message int    `json:"message"`
+}
+
+// The checkMessage function validates a chat message, for both the chat form and WebSocket clients.
+func checkMessage(form *forms.Form) {
+	form.Set("content", strings.TrimSpace(form.Get("content")))
+	form.Required("content")
+	form.MaxLength("content", 1000)
+}
+
+// The chatSocket handler upgrades the connection of a signed in user to a WebSocket, over which new
+// chat messages are delivered as they are posted. Clients can post messages over the socket too.
+// The reading side of the connection runs here; writing happens on a goroutine of its own, fed by
+// the hub.
+func (app *application) chatSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := websocket.Upgrade(w, r)
+	if err != nil {
+		// Upgrade has already sent an error response.
+		return
+	}
+	defer conn.Close()
+
+	c := newClient(conn, app.session.GetInt(r, "authenticatedUserID"))
+	if !app.chat.register(c) {
+		conn.WriteClose(websocket.CloseGoingAway, "server shutting down")
+		return
+	}
+	defer app.chat.unregister(c)
+
+	written := make(chan struct{})
+	go func() {
+		c.writePump()
+		close(written)
+	}()
+	defer func() {
+		app.chat.leave(c)
+		<-written
+	}()
+
+	// Clients which stop answering pings are given up on. Once the client is being closed, the
+	// deadline is left for the closing handshake.
+	conn.ReadLimit = maxSocketMessage
+	conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func() {
+		select {
+		case <-c.stop:
+		default:
+			conn.SetReadDeadline(time.Now().Add(pongWait))
+		}
+	})
+
+	for {
+		messageType, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if messageType != websocket.TextMessage {
+			app.chat.sendTo(c, chatError("messages must be JSON text"))
+			continue
+		}
+		app.receiveMessage(c, data)
+	}
+}
+
+// The receiveMessage method handles a message sent by a WebSocket client, posting it to a room or
+// marking a room read. Problems with the message are reported back to that client alone.
+func (app *application) receiveMessage(c *client, data []byte) {
+	var in chatInput
+	err := json.Unmarshal(data, &in)
+	if err != nil {
+		app.chat.sendTo(c, chatError("invalid JSON message"))
+		return
+	}
+	if in.Room == 0 {
+		in.Room = models.GeneralRoom
+	}
+
+	if in.Type == "read" {
+		err = app.rooms.MarkRead(in.Room, c.userID, in.Message)
+		if errors.Is(err, models.ErrNoRecord) {
+			app.chat.sendTo(c, chatError("there is no such message in the room"))
+		} else if err != nil {
+			app.errorLog.Print(err)
+		}
+		return
+	}
+
+	form := forms.New(url.Values{"content": {in.Content}})
+	checkMessage(form)
+	if !form.Valid() {
+		app.chat.sendTo(c, chatError(form.Errors.Get("content")))
+		return
+	}
+
+	_, err = app.enterRoom(in.Room, c.userID)
+	if errors.Is(err, models.ErrNoRecord) {
+		app.chat.sendTo(c, chatError("there is no such room"))
+		return
+	}
+	if err != nil {
+		app.errorLog.Print(err)
+		app.chat.sendTo(c, chatError("the message couldn`t be posted"))
+		return
+	}
+
+	id, err := app.messages.Insert(in.Room, c.userID, form.Get("content"))
+	if err == nil {
+		err = app.publishMessage(id)
+	}
+	if err != nil {
+		app.errorLog.Print(err)
+		app.chat.sendTo(c, chatError("the message couldn`t be posted"))
+	}
+}
+
+// The publishMessage method delivers a newly posted message, with its author`s name, to the clients
+// which can see its room: everybody for a public room, otherwise only the room`s members.
+func (app *application) publishMessage(id int) error {
+	msg, err := app.messages.Get(id)
+	if err != nil {
+		return err
+	}
+	err = app.messageAuthors([]*models.Message{msg})
+	if err != nil {
+		return err
+	}
+	room, err := app.rooms.Get(msg.RoomID, 0)
+	if err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(&chatEvent{Type: "message", Message: msg})
+	if err != nil {
+		return err
+	}
+	if room.Kind == models.RoomPublic {
+		app.chat.broadcast(b)
+		return nil
+	}
+	members, err := app.rooms.Members(room.ID)
+	if err != nil {
+		return err
+	}
+	app.chat.broadcastTo(b, members)
+	return nil
+}
+
+// The viewRoom method returns a room if the user with the given ID (0 for anonymous visitors) may
+// read it: anybody may read a public room, but only members may read the others. Rooms the user
+// can`t see are reported as ErrNoRecord, so their existence isn`t given away.
+func (app *application) viewRoom(id, userID int) (*models.Room, error) {
+	room, err := app.rooms.Get(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if room.Kind != models.RoomPublic && !room.Member {
+		return nil, models.ErrNoRecord
+	}
+	return room, nil
+}
+
+// The enterRoom method returns a room the user with the given ID is about to post to. Posting to a
+// public room joins it; other rooms have to be joined by invitation first.
+func (app *application) enterRoom(id, userID int) (*models.Room, error) {
+	room, err := app.viewRoom(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !room.Member {
+		err = app.rooms.AddMember(room.ID, userID)
+		if err != nil {
+			return nil, err
+		}
+		room.Member = true
+	}
+	return room, nil
+}
+
+// The chatPath function returns the address of a room`s page. The general room lives at the
+// address the chat had before it had rooms.
+func chatPath(roomID int) string {
+	if roomID == models.GeneralRoom {
+		return "/snippet/chat"
+	}
+	return fmt.Sprintf("/snippet/chat/%d", roomID)
+}
+
+// The chatError function returns the event telling a client what was wrong with its message.
+func chatError(message string) []byte {
+	b, _ := json.Marshal(&chatEvent{Type: "error", Error: message})
+	return b
+}
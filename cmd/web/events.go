@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"sabiraliyev.net/snippetbox/pkg/models"
+)
+
+// The types of the events sent on the /events stream.
+const (
+	eventSnippetCreated = "snippet-created"
+	eventSnippetDeleted = "snippet-deleted"
+	eventSnippetExpired = "snippet-expired"
+)
+
+const (
+	// The number of recent events kept for clients which reconnect with a Last-Event-ID.
+	eventBacklog = 256
+	// The number of events queued for a stream before it is treated as too slow and dropped.
+	streamBuffer = 64
+	// How often a comment is sent on an idle stream, to keep proxies from closing it.
+	heartbeatPeriod = 15 * time.Second
+	// How often the expiry watcher looks for snippets which have expired.
+	expiryCheckPeriod = 30 * time.Second
+)
+
+// A snippetEvent is something which happened to a snippet. Only the snippet`s owner gets events
+// about snippets which aren`t public, or which have a view limit, as the event gives their address
+// away.
+type snippetEvent struct {
+	ID      int64
+	Type    string
+	Snippet eventSnippet
+}
+
+// An eventSnippet is the part of a snippet sent with an event. It leaves out the content.
+type eventSnippet struct {
+	ID         int    `json:"id"`
+	Slug       string `json:"slug"`
+	Title      string `json:"title"`
+	Visibility string `json:"visibility"`
+	UserID     int    `json:"user_id"`
+	Path       string `json:"path"`
+	public     bool
+}
+
+// The visibleTo method reports whether the user with the given ID (0 for anonymous visitors) may
+// see an event.
+func (e *snippetEvent) visibleTo(userID int) bool {
+	return e.Snippet.public || (userID != 0 && userID == e.Snippet.UserID)
+}
+
+// An eventBroker fans snippet events out to the clients of the /events stream, and keeps the most
+// recent ones so that clients which lose their connection can catch up on what they missed. Like
+// the chat hub, it never waits for a slow client: a stream whose queue is full is dropped, and the
+// client reconnects and catches up from the backlog.
+type eventBroker struct {
+	mu      sync.Mutex
+	lastID  int64
+	backlog []*snippetEvent
+	streams map[*eventStream]bool
+	closed  bool
+}
+
+// An eventStream is a client of the /events stream. The events channel is closed when the broker
+// drops the stream.
+type eventStream struct {
+	userID int
+	events chan *snippetEvent
+}
+
+func newEventBroker() *eventBroker {
+	return &eventBroker{streams: map[*eventStream]bool{}}
+}
+
+// The publish method records an event about a snippet and queues it for every stream allowed to see it.
+func (b *eventBroker) publish(typ string, s *models.Snippet) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastID++
+	e := &snippetEvent{
+		ID:   b.lastID,
+		Type: typ,
+		Snippet: eventSnippet{
+			ID:         s.ID,
+			Slug:       s.Slug,
+			Title:      s.Title,
+			Visibility: s.Visibility,
+			UserID:     s.UserID,
+			Path:       "/s/" + s.Slug,
+			public:     s.Visibility == models.VisibilityPublic && s.MaxViews == 0,
+		},
+	}
+
+	if len(b.backlog) == eventBacklog {
+		copy(b.backlog, b.backlog[1:])
+		b.backlog = b.backlog[:eventBacklog-1]
+	}
+	b.backlog = append(b.backlog, e)
+
+	for stream := range b.streams {
+		if !e.visibleTo(stream.userID) {
+			continue
+		}
+		select {
+		case stream.events <- e:
+		default:
+			b.drop(stream)
+		}
+	}
+}
+
+// The subscribe method adds a stream for a user, along with the events after lastID they are
+// allowed to see which are still in the backlog. Event IDs start again from 1 when the server
+// restarts, so a lastID from the future means the whole backlog is new to the client. It returns
+// false if the broker has been shut down.
+func (b *eventBroker) subscribe(userID int, lastID int64) (*eventStream, []*snippetEvent, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil, nil, false
+	}
+
+	var missed []*snippetEvent
+	if lastID > b.lastID {
+		lastID = 0
+	}
+	if lastID > 0 {
+		for _, e := range b.backlog {
+			if e.ID > lastID && e.visibleTo(userID) {
+				missed = append(missed, e)
+			}
+		}
+	}
+
+	stream := &eventStream{userID: userID, events: make(chan *snippetEvent, streamBuffer)}
+	b.streams[stream] = true
+	return stream, missed, true
+}
+
+// The unsubscribe method removes a stream, once its client has gone.
+func (b *eventBroker) unsubscribe(stream *eventStream) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.drop(stream)
+}
+
+// The drop method removes a stream and closes its events channel. It must be called with b.mu held.
+func (b *eventBroker) drop(stream *eventStream) {
+	if b.streams[stream] {
+		delete(b.streams, stream)
+		close(stream.events)
+	}
+}
+
+// The shutdown method ends every stream, so that srv.Shutdown() doesn`t wait on them, and refuses
+// new ones.
+func (b *eventBroker) shutdown() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for stream := range b.streams {
+		b.drop(stream)
+	}
+}
+
+// The clearWriteDeadline middleware lifts the server`s WriteTimeout for long-lived responses like
+// the /events stream, which would otherwise be cut off 10 seconds in. It has to come before
+// app.session.Enable in the chain, as http.ResponseController can`t see through the session
+// middleware`s buffered ResponseWriter.
+func clearWriteDeadline(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := http.NewResponseController(w).SetWriteDeadline(time.Time{})
+		if err != nil && err != http.ErrNotSupported {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// The streamEvents handler sends snippet events as a text/event-stream, for clients which can`t use
+// WebSockets. Clients reconnecting with a Last-Event-ID header first get the events they missed,
+// as far back as the backlog goes. Events about snippets which aren`t public only go to their owner.
+func (app *application) streamEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		app.serverError(w, fmt.Errorf("streaming isn`t supported by %T", w))
+		return
+	}
+
+	lastID, _ := strconv.ParseInt(r.Header.Get("Last-Event-ID"), 10, 64)
+	stream, missed, ok := app.events.subscribe(app.authenticatedUserID(r), lastID)
+	if !ok {
+		app.clientError(w, http.StatusServiceUnavailable)
+		return
+	}
+	defer app.events.unsubscribe(stream)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	// Ask clients to wait a few seconds before reconnecting, so a restart isn`t met with a stampede.
+	fmt.Fprint(w, "retry: 5000\n\n")
+	for _, e := range missed {
+		writeEvent(w, e)
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(heartbeatPeriod)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-stream.events:
+			if !ok {
+				// The stream was dropped for falling behind, or the server is shutting down.
+				return
+			}
+			writeEvent(w, e)
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		}
+		flusher.Flush()
+	}
+}
+
+// The writeEvent function writes an event in the text/event-stream format.
+func writeEvent(w http.ResponseWriter, e *snippetEvent) {
+	data, _ := json.Marshal(e.Snippet)
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+}
+
+// The watchExpiry method publishes an event for every snippet which expires, checking every
+// interval until ctx is cancelled. Snippets which run out of views are published as they are viewed.
+func (app *application) watchExpiry(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	from := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		to := time.Now()
+		expired, err := app.snippets.Expired(from, to)
+		if err != nil {
+			app.errorLog.Printf("expiry watcher: %v", err)
+			continue
+		}
+		for _, s := range expired {
+			app.events.publish(eventSnippetExpired, s)
+		}
+		from = to
+	}
+}
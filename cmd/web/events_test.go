@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"sabiraliyev.net/snippetbox/pkg/models"
+)
+
+func TestEventBrokerReplay(t *testing.T) {
+	b := newEventBroker()
+	public := &models.Snippet{ID: 1, Slug: "public", UserID: 1, Visibility: models.VisibilityPublic}
+	private := &models.Snippet{ID: 2, Slug: "private", UserID: 1, Visibility: models.VisibilityPrivate}
+	b.publish(eventSnippetCreated, public)
+	b.publish(eventSnippetCreated, private)
+	b.publish(eventSnippetDeleted, public)
+
+	tests := []struct {
+		name    string
+		userID  int
+		lastID  int64
+		wantIDs []int64
+	}{
+		{"New client", 0, 0, nil},
+		{"Anonymous", 0, 1, []int64{3}},
+		{"Owner", 1, 1, []int64{2, 3}},
+		{"Someone else", 2, 1, []int64{3}},
+		{"Up to date", 1, 3, nil},
+		{"After a restart", 1, 99, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream, missed, ok := b.subscribe(tt.userID, tt.lastID)
+			if !ok {
+				t.Fatal("want the stream accepted")
+			}
+			defer b.unsubscribe(stream)
+
+			var ids []int64
+			for _, e := range missed {
+				ids = append(ids, e.ID)
+			}
+			if len(ids) != len(tt.wantIDs) {
+				t.Fatalf("want events %v; got %v", tt.wantIDs, ids)
+			}
+			for i := range ids {
+				if ids[i] != tt.wantIDs[i] {
+					t.Errorf("want events %v; got %v", tt.wantIDs, ids)
+				}
+			}
+		})
+	}
+}
+
+func TestEventBrokerBacklog(t *testing.T) {
+	b := newEventBroker()
+	s := &models.Snippet{ID: 1, Slug: "public", Visibility: models.VisibilityPublic}
+	for i := 0; i < eventBacklog+10; i++ {
+		b.publish(eventSnippetCreated, s)
+	}
+
+	_, missed, _ := b.subscribe(0, 1)
+	if len(missed) != eventBacklog {
+		t.Fatalf("want %d events; got %d", eventBacklog, len(missed))
+	}
+	if missed[0].ID != 11 {
+		t.Errorf("want the oldest events forgotten; got event %d first", missed[0].ID)
+	}
+}
+
+func TestEventBrokerDropsSlowStreams(t *testing.T) {
+	b := newEventBroker()
+	stream, _, _ := b.subscribe(0, 0)
+	owner, _, _ := b.subscribe(1, 0)
+
+	// A private snippet isn`t sent to the anonymous stream at all.
+	b.publish(eventSnippetCreated, &models.Snippet{ID: 2, UserID: 1, Visibility: models.VisibilityPrivate})
+	if len(stream.events) != 0 {
+		t.Errorf("want no events for someone else`s private snippet; got %d", len(stream.events))
+	}
+
+	// Nobody reads from the streams, so they are dropped once their queues are full.
+	s := &models.Snippet{ID: 1, Visibility: models.VisibilityPublic}
+	for i := 0; i <= streamBuffer; i++ {
+		b.publish(eventSnippetCreated, s)
+	}
+	for range stream.events {
+	}
+	for range owner.events {
+	}
+
+	b.shutdown()
+	if _, _, ok := b.subscribe(0, 0); ok {
+		t.Error("want no new streams after shutdown")
+	}
+}
+
+func TestStreamEvents(t *testing.T) {
+	app := newTestApplication(t)
+	app.events.publish(eventSnippetCreated, &models.Snippet{ID: 1, Slug: "abc", Title: "An old snippet", Visibility: models.VisibilityPublic})
+
+	// Use a real server, with a write timeout like the production one, to check the stream
+	// outlives it.
+	ts := httptest.NewUnstartedServer(app.routes())
+	ts.Config.WriteTimeout = 100 * time.Millisecond
+	ts.Start()
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/events", nil)
+	req.Header.Set("Last-Event-ID", "0")
+	rs, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Body.Close()
+
+	if rs.StatusCode != http.StatusOK {
+		t.Fatalf("want %d; got %d", http.StatusOK, rs.StatusCode)
+	}
+	if ct := rs.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("want Content-Type text/event-stream; got %q", ct)
+	}
+
+	// Publish after the write timeout has passed.
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		app.events.publish(eventSnippetDeleted, &models.Snippet{ID: 1, Slug: "abc", Title: "An old snippet", Visibility: models.VisibilityPublic})
+	}()
+
+	lines := bufio.NewScanner(rs.Body)
+	var event []string
+	for lines.Scan() {
+		if lines.Text() == "" && len(event) > 0 && strings.HasPrefix(event[0], "id:") {
+			break
+		}
+		if lines.Text() == "" {
+			event = nil
+			continue
+		}
+		event = append(event, lines.Text())
+	}
+	if err := lines.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"id: 2",
+		"event: snippet-deleted",
+		`data: {"id":1,"slug":"abc","title":"An old snippet","visibility":"public","user_id":0,"path":"/s/abc"}`,
+	}
+	if strings.Join(event, "\n") != strings.Join(want, "\n") {
+		t.Errorf("want event %q; got %q", want, event)
+	}
+}
+
+func TestStreamEventsNeedsReadScope(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/events", nil)
+	req.Header.Set("Authorization", "Bearer sb_nope")
+	rs, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs.Body.Close()
+	if rs.StatusCode != http.StatusUnauthorized {
+		t.Errorf("want %d; got %d", http.StatusUnauthorized, rs.StatusCode)
+	}
+}
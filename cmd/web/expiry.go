@@ -0,0 +1,144 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"sabiraliyev.net/snippetbox/pkg/forms"
+	"sabiraliyev.net/snippetbox/pkg/models"
+)
+
+const day = 24 * time.Hour
+
+// The defaultMaxExpiry constant is the expiry limit of roles which have none configured, and
+// viewLimitedExpiry is how long snippets with a view limit live for if nobody reads them.
+const (
+	defaultMaxExpiry  = 365 * day
+	viewLimitedExpiry = 7 * day
+)
+
+// The expiryPresets map holds the durations offered as one-click choices on the expiry forms.
+var expiryPresets = map[string]time.Duration{
+	"1":   day,
+	"7":   7 * day,
+	"365": 365 * day,
+}
+
+// The expiryUnits map holds the units a custom expiry duration can be given in.
+var expiryUnits = map[string]time.Duration{
+	"minutes": time.Minute,
+	"hours":   time.Hour,
+	"days":    day,
+}
+
+// The expiryDateLayout is the format of the value sent by a datetime-local input. Dates are
+// entered and displayed in UTC.
+const expiryDateLayout = "2006-01-02T15:04"
+
+// The maxExpiry helper returns the longest time a snippet created by the current user may live for.
+// A zero duration means there is no limit.
+func (app *application) maxExpiry(r *http.Request) (time.Duration, error) {
+	role := models.RoleUser
+	if app.isAdministrator(r) {
+		role = models.RoleAdministrator
+	}
+
+	max, err := app.expiryLimits.Get(role)
+	if errors.Is(err, models.ErrNoRecord) {
+		return defaultMaxExpiry, nil
+	}
+	return max, err
+}
+
+// The parseExpiry function validates the expiry fields of a form, adding any problems to the form
+// errors, and works out when the snippet expires (the zero time meaning never) and how many times it
+// can be viewed (0 meaning no limit). The "expires" field selects the mode: one of the presets, a
+// "custom" duration, an explicit "date", "never", or, when allowViewLimit is set, "burn" after the
+// first view or after a number of "views". No snippet may live longer than max, unless max is zero.
+func parseExpiry(form *forms.Form, max time.Duration, now time.Time, allowViewLimit bool) (time.Time, int) {
+	var ttl time.Duration
+	var expires time.Time
+	maxViews := 0
+
+	switch mode := form.Get("expires"); {
+	case expiryPresets[mode] != 0:
+		ttl = expiryPresets[mode]
+	case mode == "custom":
+		form.Required("expires_amount", "expires_unit")
+		form.IntRange("expires_amount", 1, 100000)
+		form.PermittedValues("expires_unit", "minutes", "hours", "days")
+		if !form.Valid() {
+			return time.Time{}, 0
+		}
+		amount, _ := strconv.Atoi(form.Get("expires_amount"))
+		ttl = time.Duration(amount) * expiryUnits[form.Get("expires_unit")]
+	case mode == "date":
+		form.Required("expires_at")
+		if !form.Valid() {
+			return time.Time{}, 0
+		}
+		t, err := time.Parse(expiryDateLayout, form.Get("expires_at"))
+		if err != nil {
+			form.Errors.Add("expires_at", "This field must be a date and time")
+			return time.Time{}, 0
+		}
+		if !t.After(now) {
+			form.Errors.Add("expires_at", "This date is in the past")
+			return time.Time{}, 0
+		}
+		ttl, expires = t.Sub(now), t
+	case mode == "never":
+		if max != 0 {
+			form.Errors.Add("expires", "You can`t create snippets which never expire")
+		}
+		return time.Time{}, 0
+	case allowViewLimit && mode == "burn":
+		ttl, maxViews = viewLimitedExpiry, 1
+	case allowViewLimit && mode == "views":
+		form.Required("max_views")
+		form.IntRange("max_views", 1, maxViewLimit)
+		if !form.Valid() {
+			return time.Time{}, 0
+		}
+		maxViews, _ = strconv.Atoi(form.Get("max_views"))
+		ttl = viewLimitedExpiry
+	default:
+		form.Errors.Add("expires", "This field is invalid")
+		return time.Time{}, 0
+	}
+
+	// Unread view-limited snippets are quietly given the longest lifetime allowed, rather than
+	// refusing them when the limit is shorter than viewLimitedExpiry.
+	if max != 0 && ttl > max {
+		if maxViews == 0 {
+			form.Errors.Add("expires", fmt.Sprintf("Snippets can`t live for longer than %s", humanDuration(max)))
+			return time.Time{}, 0
+		}
+		ttl = max
+	}
+
+	if expires.IsZero() {
+		expires = now.Add(ttl)
+	}
+	return expires.UTC(), maxViews
+}
+
+// The humanDuration function formats a duration in the largest whole unit which represents it exactly.
+func humanDuration(d time.Duration) string {
+	for _, unit := range []struct {
+		name string
+		size time.Duration
+	}{{"day", day}, {"hour", time.Hour}, {"minute", time.Minute}} {
+		if d >= unit.size && d%unit.size == 0 {
+			n := int(d / unit.size)
+			if n == 1 {
+				return fmt.Sprintf("1 %s", unit.name)
+			}
+			return fmt.Sprintf("%d %ss", n, unit.name)
+		}
+	}
+	return d.String()
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"sabiraliyev.net/snippetbox/pkg/forms"
+)
+
+func TestParseExpiry(t *testing.T) {
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		values    url.Values
+		max       time.Duration
+		wantTime  time.Time
+		wantViews int
+		wantValid bool
+	}{
+		{"Preset", url.Values{"expires": {"7"}}, defaultMaxExpiry, now.Add(7 * day), 0, true},
+		{"Custom minutes", url.Values{"expires": {"custom"}, "expires_amount": {"90"}, "expires_unit": {"minutes"}}, defaultMaxExpiry, now.Add(90 * time.Minute), 0, true},
+		{"Custom bad unit", url.Values{"expires": {"custom"}, "expires_amount": {"2"}, "expires_unit": {"weeks"}}, defaultMaxExpiry, time.Time{}, 0, false},
+		{"Custom over limit", url.Values{"expires": {"custom"}, "expires_amount": {"2"}, "expires_unit": {"days"}}, day, time.Time{}, 0, false},
+		{"Date", url.Values{"expires": {"date"}, "expires_at": {"2021-03-02T08:30"}}, defaultMaxExpiry, time.Date(2021, 3, 2, 8, 30, 0, 0, time.UTC), 0, true},
+		{"Date in past", url.Values{"expires": {"date"}, "expires_at": {"2021-02-01T08:30"}}, defaultMaxExpiry, time.Time{}, 0, false},
+		{"Never allowed", url.Values{"expires": {"never"}}, 0, time.Time{}, 0, true},
+		{"Never refused", url.Values{"expires": {"never"}}, defaultMaxExpiry, time.Time{}, 0, false},
+		{"Burn", url.Values{"expires": {"burn"}}, defaultMaxExpiry, now.Add(viewLimitedExpiry), 1, true},
+		{"Views capped by limit", url.Values{"expires": {"views"}, "max_views": {"5"}}, day, now.Add(day), 5, true},
+		{"Unknown", url.Values{"expires": {"30"}}, defaultMaxExpiry, time.Time{}, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := forms.New(tt.values)
+			expires, maxViews := parseExpiry(form, tt.max, now, true)
+
+			if form.Valid() != tt.wantValid {
+				t.Fatalf("want valid %v; got %v (%v)", tt.wantValid, form.Valid(), form.Errors)
+			}
+			if !expires.Equal(tt.wantTime) {
+				t.Errorf("want expires %v; got %v", tt.wantTime, expires)
+			}
+			if maxViews != tt.wantViews {
+				t.Errorf("want max views %d; got %d", tt.wantViews, maxViews)
+			}
+		})
+	}
+
+	t.Run("View limits refused when changing expiry", func(t *testing.T) {
+		form := forms.New(url.Values{"expires": {"burn"}})
+		parseExpiry(form, defaultMaxExpiry, now, false)
+		if form.Valid() {
+			t.Error("want form to be invalid")
+		}
+	})
+}
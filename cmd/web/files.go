@@ -0,0 +1,191 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"sabiraliyev.net/snippetbox/pkg/diff"
+	"sabiraliyev.net/snippetbox/pkg/forms"
+	"sabiraliyev.net/snippetbox/pkg/highlight"
+	"sabiraliyev.net/snippetbox/pkg/models"
+)
+
+const (
+	// The most files a snippet can have, counting its first file.
+	maxFiles = 10
+	// The number of empty file slots offered in the create and edit forms.
+	blankFiles = 2
+)
+
+// The fileNameRX regular expression matches a valid file name: up to 100 characters, without slashes,
+// backslashes or control characters. Names made of dots alone are rejected separately.
+var fileNameRX = regexp.MustCompile(`^[^/\\\x00-\x1f\x7f]{1,100}$`)
+
+// The unsafeNameRX regular expression matches the runs of characters which are replaced with a dash
+// when a file name is made up from a snippet`s title.
+var unsafeNameRX = regexp.MustCompile(`[^a-z0-9._]+`)
+
+// The validFileName function reports whether a file name can be used in a snippet, in a URL path
+// segment and in a zip archive.
+func validFileName(name string) bool {
+	return fileNameRX.MatchString(name) && strings.Trim(name, ".") != ""
+}
+
+// The checkFilename function validates the optional name of a snippet`s first file.
+func checkFilename(form *forms.Form) {
+	form.Set("filename", strings.TrimSpace(form.Get("filename")))
+	if name := form.Get("filename"); name != "" && !validFileName(name) {
+		form.Errors.Add("filename", "File names can`t contain slashes or control characters")
+	}
+}
+
+// The parseFiles function reads a snippet`s further files from the repeated file_name, file_language
+// and file_content fields of a form, skipping slots which were left empty. Any problems are recorded
+// against the "files" field. Files without a language have one detected from their name or shebang
+// line; unlike the first file, a file`s language isn`t guessed from clues in the code. The first
+// file`s name, as given or made up by fileName(), is needed to check no other file takes it.
+func parseFiles(form *forms.Form, first string) []*models.File {
+	names, languages, contents := form.Values["file_name"], form.Values["file_language"], form.Values["file_content"]
+	if len(names) != len(contents) || len(languages) != len(contents) {
+		form.Errors.Add("files", "The files are incomplete")
+		return nil
+	}
+
+	files := []*models.File{}
+	seen := map[string]bool{first: true}
+	for i := range names {
+		f := &models.File{Name: strings.TrimSpace(names[i]), Language: languages[i], Content: contents[i]}
+		if f.Name == "" && strings.TrimSpace(f.Content) == "" {
+			continue
+		}
+		files = append(files, f)
+
+		switch {
+		case f.Name == "":
+			form.Errors.Add("files", "Every file needs a name")
+		case !validFileName(f.Name):
+			form.Errors.Add("files", "File names can`t contain slashes or control characters")
+		case seen[f.Name]:
+			form.Errors.Add("files", "Every file needs a different name")
+		case strings.TrimSpace(f.Content) == "":
+			form.Errors.Add("files", "Files can`t be empty")
+		case f.Language != "" && !knownLanguage(f.Language):
+			form.Errors.Add("files", "A file has an unknown language")
+		}
+		seen[f.Name] = true
+
+		if f.Language == "" {
+			if language, confidence := highlight.Detect(f.Name, f.Content); confidence >= 0.9 {
+				f.Language = language
+			}
+		}
+	}
+
+	if len(files) >= maxFiles {
+		form.Errors.Add("files", "A snippet can`t have that many files")
+	}
+	return files
+}
+
+// The knownLanguage function reports whether a snippet can be in the named language.
+func knownLanguage(name string) bool {
+	for _, l := range languageNames() {
+		if l == name {
+			return true
+		}
+	}
+	return false
+}
+
+// The withBlankFiles function adds empty slots to a snippet`s further files for the create and edit
+// forms, as long as there is room for more files.
+func withBlankFiles(files []*models.File) []*models.File {
+	for i := 0; i < blankFiles && len(files) < maxFiles-1; i++ {
+		files = append(files, &models.File{})
+	}
+	return files
+}
+
+// The allFiles function returns every file of a snippet in order: its own content first, followed
+// by its further files.
+func allFiles(s *models.Snippet, files []*models.File) []*models.File {
+	first := &models.File{Name: fileName(s), Language: s.Language, Content: s.Content}
+	return append([]*models.File{first}, files...)
+}
+
+// The revisionFiles function returns every file of a revision in order, like allFiles(). A first
+// file the author didn`t name is named after the revision`s title and the snippet`s language.
+func revisionFiles(s *models.Snippet, rev *models.Revision) []*models.File {
+	first := &models.Snippet{Title: rev.Title, Filename: rev.Filename, Language: s.Language, Content: rev.Content}
+	return allFiles(first, rev.Files)
+}
+
+// The diffFiles function pairs up the files of two revisions and diffs each pair. The first files
+// are always compared with each other, even if renamed, and further files are matched by name. Files
+// which only one revision has are diffed against nothing, with removed files coming last.
+func diffFiles(from, to []*models.File) []*fileDiff {
+	old := map[string]*models.File{}
+	for _, f := range from[1:] {
+		old[f.Name] = f
+	}
+
+	diffs := []*fileDiff{newFileDiff(from[0], to[0])}
+	matched := map[string]bool{}
+	for _, f := range to[1:] {
+		diffs = append(diffs, newFileDiff(old[f.Name], f))
+		matched[f.Name] = true
+	}
+	for _, f := range from[1:] {
+		if !matched[f.Name] {
+			diffs = append(diffs, newFileDiff(f, nil))
+		}
+	}
+	return diffs
+}
+
+// The newFileDiff function diffs two versions of a file, either of which can be nil.
+func newFileDiff(from, to *models.File) *fileDiff {
+	fd := &fileDiff{}
+	var oldContent, newContent string
+	if from != nil {
+		fd.OldName, oldContent = from.Name, from.Content
+	}
+	if to != nil {
+		fd.NewName, newContent = to.Name, to.Content
+	}
+	fd.Lines = diff.Lines(oldContent, newContent)
+	fd.Changed = fd.OldName != fd.NewName || diff.Changed(fd.Lines)
+	fd.Rows = diff.SideBySide(fd.Lines)
+	fd.Hunks = diff.Hunks(fd.Lines, diffContext)
+	return fd
+}
+
+// The fileName function returns the name of a snippet`s first file. If the author didn`t name it,
+// a name is made up from the snippet`s title and language.
+func fileName(s *models.Snippet) string {
+	if s.Filename != "" {
+		return s.Filename
+	}
+	if s.Language == "dockerfile" {
+		return "Dockerfile"
+	}
+	name := baseName(s.Title)
+	if ext := highlight.Extension(s.Language); !strings.HasSuffix(name, ext) {
+		name += ext
+	}
+	return name
+}
+
+// The baseName function turns a snippet`s title into something safe to use as a file name, without
+// an extension. Characters other than lower case letters, digits, dots and underscores become dashes.
+func baseName(title string) string {
+	name := unsafeNameRX.ReplaceAllString(strings.ToLower(title), "-")
+	name = strings.Trim(name, "-.")
+	if len(name) > 50 {
+		name = strings.Trim(name[:50], "-.")
+	}
+	if name == "" {
+		return "snippet"
+	}
+	return name
+}
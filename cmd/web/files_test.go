@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+
+	"sabiraliyev.net/snippetbox/pkg/forms"
+	"sabiraliyev.net/snippetbox/pkg/models"
+)
+
+func TestParseFiles(t *testing.T) {
+	tests := []struct {
+		name      string
+		values    url.Values
+		wantFiles int
+		wantLang  string
+		wantValid bool
+	}{
+		{"Empty slots", url.Values{"file_name": {"", ""}, "file_language": {"", ""}, "file_content": {"", " "}}, 0, "", true},
+		{"Detected from shebang", url.Values{"file_name": {"entrypoint"}, "file_language": {""}, "file_content": {"#!/bin/sh\nexec web"}}, 1, "shell", true},
+		{"Detected from name", url.Values{"file_name": {"Dockerfile"}, "file_language": {""}, "file_content": {"FROM scratch"}}, 1, "dockerfile", true},
+		{"Chosen language", url.Values{"file_name": {"notes"}, "file_language": {"markdown"}, "file_content": {"# Notes"}}, 1, "markdown", true},
+		{"Unknown language", url.Values{"file_name": {"x"}, "file_language": {"cobol"}, "file_content": {"x"}}, 1, "cobol", false},
+		{"Missing name", url.Values{"file_name": {""}, "file_language": {""}, "file_content": {"x"}}, 1, "", false},
+		{"Slash in name", url.Values{"file_name": {"../etc/passwd"}, "file_language": {""}, "file_content": {"x"}}, 1, "", false},
+		{"Dots for name", url.Values{"file_name": {".."}, "file_language": {""}, "file_content": {"x"}}, 1, "", false},
+		{"Empty content", url.Values{"file_name": {"x"}, "file_language": {""}, "file_content": {" "}}, 1, "", false},
+		{"Same as first file", url.Values{"filename": {"main.go"}, "file_name": {"main.go"}, "file_language": {""}, "file_content": {"x"}}, 1, "go", false},
+		{"Same as made-up first file name", url.Values{"title": {"Frog"}, "language": {"shell"}, "file_name": {"frog.sh"}, "file_language": {""}, "file_content": {"x"}}, 1, "shell", false},
+		{"Mismatched fields", url.Values{"file_name": {"x", "y"}, "file_language": {""}, "file_content": {"x"}}, 0, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := forms.New(tt.values)
+			first := &models.Snippet{Title: form.Get("title"), Filename: form.Get("filename"), Language: form.Get("language")}
+			files := parseFiles(form, fileName(first))
+
+			if form.Valid() != tt.wantValid {
+				t.Errorf("want valid %v; got %v (%v)", tt.wantValid, form.Valid(), form.Errors)
+			}
+			if len(files) != tt.wantFiles {
+				t.Fatalf("want %d files; got %d", tt.wantFiles, len(files))
+			}
+			if len(files) > 0 && files[0].Language != tt.wantLang {
+				t.Errorf("want language %q; got %q", tt.wantLang, files[0].Language)
+			}
+		})
+	}
+}
+
+func TestFileName(t *testing.T) {
+	tests := []struct {
+		name    string
+		snippet *models.Snippet
+		want    string
+	}{
+		{"Named", &models.Snippet{Title: "Deploy", Filename: "deploy.sh", Language: "shell"}, "deploy.sh"},
+		{"From title", &models.Snippet{Title: "Hello, World!", Language: "go"}, "hello-world.go"},
+		{"Title with extension", &models.Snippet{Title: "main.go", Language: "go"}, "main.go"},
+		{"Plain text", &models.Snippet{Title: "Shopping list"}, "shopping-list.txt"},
+		{"Dockerfile", &models.Snippet{Title: "Web image", Language: "dockerfile"}, "Dockerfile"},
+		{"Nothing usable", &models.Snippet{Title: "¿¡!?", Language: "python"}, "snippet.py"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fileName(tt.snippet); got != tt.want {
+				t.Errorf("want %q; got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -1,12 +1,21 @@
 package main
 
 import (
+	"archive/zip"
+	"bytes"
 	"errors"
 	"fmt"
+	"io"
+	"mime"
 	"strconv"
+	"strings"
+	"time"
 
 	"net/http"
+	"net/url"
+	"sabiraliyev.net/snippetbox/pkg/diff"
 	"sabiraliyev.net/snippetbox/pkg/forms"
+	"sabiraliyev.net/snippetbox/pkg/highlight"
 	"sabiraliyev.net/snippetbox/pkg/models"
 )
 
@@ -14,7 +23,11 @@ import (
 func (app *application) home(w http.ResponseWriter, r *http.Request) {
 	// Because Pat matches the "/" path exactly, we can now remove the manual check of r.URL.PAth != "/" from this handler.
 
-	s, err := app.snippets.Latest()
+	page, pg, ok := app.listSnippets(w, r, models.ListOptions{})
+	if !ok {
+		return
+	}
+	tags, err := app.tags.Cloud(tagCloudSize)
 	if err != nil {
 		app.serverError(w, err)
 		return
@@ -22,7 +35,62 @@ func (app *application) home(w http.ResponseWriter, r *http.Request) {
 
 	// Use the render helper.
 	app.render(w, r, "home.page.tmpl", &templateData{
-		Snippets: s,
+		Snippets:   page.Snippets,
+		Pagination: pg,
+		Tags:       tags,
+	})
+}
+
+// The tagCloudSize constant is the number of tags shown in the tag cloud on the home page.
+const tagCloudSize = 30
+
+// The showTag handler lists the public snippets with the tag given in the URL.
+func (app *application) showTag(w http.ResponseWriter, r *http.Request) {
+	tag := r.URL.Query().Get(":name")
+	if !forms.TagRX.MatchString(tag) {
+		app.notFound(w)
+		return
+	}
+
+	page, pg, ok := app.listSnippets(w, r, models.ListOptions{Tag: tag})
+	if !ok {
+		return
+	}
+
+	app.render(w, r, "tag.page.tmpl", &templateData{
+		Snippets:   page.Snippets,
+		Pagination: pg,
+		Tag:        tag,
+	})
+}
+
+// The search handler shows the public snippets matching the ?q= query, one ?page= at a time.
+func (app *application) search(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		app.render(w, r, "search.page.tmpl", &templateData{})
+		return
+	}
+
+	page := 1
+	if p := r.URL.Query().Get("page"); p != "" {
+		var err error
+		page, err = strconv.Atoi(p)
+		if err != nil || page < 1 {
+			app.clientError(w, http.StatusBadRequest)
+			return
+		}
+	}
+
+	results, err := app.snippets.Search(query, page)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.render(w, r, "search.page.tmpl", &templateData{
+		Query:         query,
+		SearchResults: results,
 	})
 }
 
@@ -49,84 +117,1200 @@ func (app *application) showSnippet(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Pass the flash message to the template.
-	app.render(w, r, "show.page.tmpl", &templateData{
+	// Sequential IDs are easy to enumerate, so only public snippets can be read through them.
+	// Owners and administrators are redirected to the snippet`s unguessable URL instead, and
+	// everybody else is told the snippet doesn`t exist. Public snippets with a view limit are
+	// also redirected, so that their views are only used up behind the confirmation page.
+	switch {
+	case s.Visibility == models.VisibilityPublic && s.MaxViews == 0:
+		// Pass the flash message to the template.
+		app.renderSnippet(w, r, &templateData{
+			Form:    forms.New(nil),
+			Snippet: s,
+		})
+	case s.Visibility == models.VisibilityPublic || app.canModify(r, s):
+		http.Redirect(w, r, "/s/"+s.Slug, http.StatusSeeOther)
+	default:
+		app.notFound(w)
+	}
+}
+
+// The showSnippetBySlug handler displays a snippet identified by its random slug. This is the
+// canonical URL of a snippet, and the only one through which unlisted snippets can be read.
+func (app *application) showSnippetBySlug(w http.ResponseWriter, r *http.Request) {
+	s, err := app.snippets.GetBySlug(r.URL.Query().Get(":slug"))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	// Private snippets are treated as if they don`t exist for anyone but their owner and administrators.
+	if !app.canView(r, s) {
+		app.notFound(w)
+		return
+	}
+
+	// Viewing a snippet with a view limit uses one of its views up. Link previews and crawlers
+	// follow links with GET requests, so rather than showing the content we ask the reader to
+	// confirm with a POST request first. The owner and administrators can look at it freely.
+	if s.MaxViews > 0 && !app.canModify(r, s) {
+		app.render(w, r, "confirm.page.tmpl", &templateData{
+			Snippet: s,
+		})
+		return
+	}
+
+	app.renderSnippet(w, r, &templateData{
+		Form:    forms.New(nil),
 		Snippet: s,
 	})
 }
 
-func (app *application) showAdminPage(w http.ResponseWriter, r *http.Request) {
-	s, err := app.snippets.Latest()
+// The viewSnippet handler reveals a snippet with a view limit, once the reader has confirmed
+// they want to use up one of its views.
+func (app *application) viewSnippet(w http.ResponseWriter, r *http.Request) {
+	slug := r.URL.Query().Get(":slug")
+
+	// Check that the reader is allowed to see the snippet before a view is counted.
+	s, err := app.snippets.GetBySlug(slug)
+	if err == nil {
+		if !app.canView(r, s) {
+			app.notFound(w)
+			return
+		}
+		s, err = app.snippets.View(slug)
+	}
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	// A snippet has expired once its last view is used up.
+	if s.MaxViews > 0 && s.Views >= s.MaxViews {
+		app.events.publish(eventSnippetExpired, s)
+	}
+
+	// The content must never be cached, or the view limit could be side-stepped.
+	w.Header().Set("Cache-Control", "no-store")
+	app.renderSnippet(w, r, &templateData{
+		Form:    forms.New(nil),
+		Snippet: s,
+	})
+}
+
+// The userSnippets handler lists every unexpired snippet owned by the authenticated user.
+func (app *application) userSnippets(w http.ResponseWriter, r *http.Request) {
+	s, err := app.snippets.ByUser(app.session.GetInt(r, "authenticatedUserID"))
 	if err != nil {
 		app.serverError(w, err)
 		return
 	}
-	app.render(w, r, "admin.page.tmpl", &templateData{
+	app.render(w, r, "usersnippets.page.tmpl", &templateData{
 		Snippets: s,
 	})
 }
 
+func (app *application) showAdminPage(w http.ResponseWriter, r *http.Request) {
+	page, pg, ok := app.listSnippets(w, r, models.ListOptions{All: true})
+	if !ok {
+		return
+	}
+	deleted, err := app.snippets.Deleted()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	limits, err := app.expiryLimits.All()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// The limits are shown in days, and roles without a configured limit get the default one.
+	limitDays := map[string]int{}
+	for _, role := range []string{models.RoleUser, models.RoleAdministrator} {
+		max, ok := limits[role]
+		if !ok {
+			max = defaultMaxExpiry
+		}
+		limitDays[role] = int(max / day)
+	}
+
+	data := &templateData{
+		Snippets:        page.Snippets,
+		Pagination:      pg,
+		DeletedSnippets: deleted,
+		ExpiryLimits:    limitDays,
+	}
+	if app.reaper != nil {
+		stats := app.reaper.lastRun()
+		data.Reaper = &stats
+	}
+	app.render(w, r, "admin.page.tmpl", data)
+}
+
+// The chatPageSize constant is the number of messages shown on a page of the chat.
+const chatPageSize = 50
+
+// The showChatPage handler shows the latest messages in a chat room, with a form to post another.
+// The general room is shown at /snippet/chat, and the others at /snippet/chat/:id. The before query
+// parameter pages back through older messages.
 func (app *application) showChatPage(w http.ResponseWriter, r *http.Request) {
-	//m, err := app.messages.Latest()
-	//if err != nil {
-	//	app.serverError(w, err)
-	//	return
-	//}
-	app.render(w, r, "chat.page.tmpl", &templateData{
-		// Messages: m,
-	})
+	roomID := models.GeneralRoom
+	if v := r.URL.Query().Get(":id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil || id < 1 {
+			app.notFound(w)
+			return
+		}
+		roomID = id
+	}
+	app.renderChat(w, r, roomID, &templateData{Form: forms.New(nil)})
 }
 
-// Add new createSnippetForm handler, which for now a placeholder response.
-func (app *application) createSnippetForm(w http.ResponseWriter, r *http.Request) {
-	app.render(w, r, "create.page.tmpl", &templateData{
-		// Pass the new empty form.Form object to the template.
-		Form: forms.New(nil),
-	})
+// The postMessage handler posts a message from the authenticated user to the room given by the
+// room field, or to the general room.
+func (app *application) postMessage(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	roomID, ok := chatRoomField(form)
+	if !ok {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	checkMessage(form)
+	if !form.Valid() {
+		app.renderChat(w, r, roomID, &templateData{Form: form})
+		return
+	}
+
+	userID := app.session.GetInt(r, "authenticatedUserID")
+	_, err = app.enterRoom(roomID, userID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	id, err := app.messages.Insert(roomID, userID, form.Get("content"))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// Deliver the message to everybody connected to the chat who can see the room, too.
+	err = app.publishMessage(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	http.Redirect(w, r, chatPath(roomID), http.StatusSeeOther)
 }
 
-func (app *application) createSnippet(w http.ResponseWriter, r *http.Request) {
-	// First we call r.ParseForm() which add any data in POST request bodies to the r.PostForm map.
-	// This also works in the same way for PUT and PATCH requests. If there any errors, we use
-	// our app.Client helper to send a 400 Bad Request response to the user.
+// The createRoom handler creates a public or private chat room, with the authenticated user as its
+// first member.
+func (app *application) createRoom(w http.ResponseWriter, r *http.Request) {
 	err := r.ParseForm()
 	if err != nil {
 		app.clientError(w, http.StatusBadRequest)
 		return
 	}
 
-	// Create a new forms.Form struct containing the POSTed data from the form, the use the
-	// validation methods to check the validation.
 	form := forms.New(r.PostForm)
-	form.Required("title", "content", "expires")
-	form.MaxLength("title", 100)
-	form.PermittedValues("expires", "365", "7", "1")
+	form.Set("name", strings.TrimSpace(form.Get("name")))
+	form.Required("name", "kind")
+	form.MaxLength("name", 50)
+	form.PermittedValues("kind", models.RoomPublic, models.RoomPrivate)
+	if !form.Valid() {
+		app.renderChat(w, r, models.GeneralRoom, &templateData{Form: form})
+		return
+	}
 
-	// If the form isn`t valid, redisplay the template passing in the form.Form object as the data.
+	id, err := app.rooms.Insert(app.session.GetInt(r, "authenticatedUserID"), form.Get("kind"), form.Get("name"))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.session.Put(r, "flash", "Room created!")
+	http.Redirect(w, r, chatPath(id), http.StatusSeeOther)
+}
+
+// The joinRoom handler adds the authenticated user to a public room. Private rooms can only be
+// joined by invitation, so they are treated as not found.
+func (app *application) joinRoom(w http.ResponseWriter, r *http.Request) {
+	room, ok := app.formRoom(w, r)
+	if !ok {
+		return
+	}
+	if room.Kind != models.RoomPublic {
+		app.notFound(w)
+		return
+	}
+
+	err := app.rooms.AddMember(room.ID, app.session.GetInt(r, "authenticatedUserID"))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	http.Redirect(w, r, chatPath(room.ID), http.StatusSeeOther)
+}
+
+// The inviteToRoom handler lets a member of a private room add somebody else to it, by the email
+// address in the invitee field.
+func (app *application) inviteToRoom(w http.ResponseWriter, r *http.Request) {
+	room, ok := app.formRoom(w, r)
+	if !ok {
+		return
+	}
+	if room.Kind != models.RoomPrivate || !room.Member {
+		app.notFound(w)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	user, err := app.chatUser(form, "invitee")
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
 	if !form.Valid() {
-		app.render(w, r, "create.page.tmpl", &templateData{Form: form})
+		app.renderChat(w, r, room.ID, &templateData{Form: form})
+		return
 	}
 
-	// Because the form data (with type url.Values) has been anonymously embedded in the form.Form struct,
-	// we can use the Get() method to retrieve the validated value for the particular form filed.
-	id, err := app.snippets.Insert(form.Get("title"), form.Get("content"), form.Get("expires"))
+	err = app.rooms.AddMember(room.ID, user.ID)
 	if err != nil {
 		app.serverError(w, err)
 		return
 	}
+	app.session.Put(r, "flash", fmt.Sprintf("%s has been invited.", user.Name))
+	http.Redirect(w, r, chatPath(room.ID), http.StatusSeeOther)
+}
 
-	// Use the Put() method to add a string value ("Your snippet was saved successfully1") and the
-	// corresponding key ("flash") to the session data. Note yhat if there`s no session for the current user
-	// (or their session has expired) the new, empty session for them will automatically be created
-	// by the session middleware.
-	app.session.Put(r, "flash", "Snippet successfully created!")
+// The startDirect handler opens the direct room between the authenticated user and the user with the
+// given email address, creating it if they haven`t talked before.
+func (app *application) startDirect(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	user, err := app.chatUser(form, "email")
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	userID := app.session.GetInt(r, "authenticatedUserID")
+	if user != nil && user.ID == userID {
+		form.Errors.Add("email", "You can`t send messages to yourself")
+	}
+	if !form.Valid() {
+		app.renderChat(w, r, models.GeneralRoom, &templateData{Form: form})
+		return
+	}
+
+	id, err := app.rooms.Direct(userID, user.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	http.Redirect(w, r, chatPath(id), http.StatusSeeOther)
+}
+
+// The formRoom helper parses the form of a request to a room`s /join or /invite address and returns
+// the room, if the authenticated user can see it. Otherwise it sends an error response and returns
+// false.
+func (app *application) formRoom(w http.ResponseWriter, r *http.Request) (*models.Room, bool) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return nil, false
+	}
+	id, err := strconv.Atoi(r.URL.Query().Get(":id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return nil, false
+	}
+
+	room, err := app.viewRoom(id, app.session.GetInt(r, "authenticatedUserID"))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return nil, false
+	}
+	return room, true
+}
 
-	http.Redirect(w, r, fmt.Sprintf("/snippet/%d", id), http.StatusSeeOther)
+// The chatUser helper looks up the user with the email address given in a form field. If the address
+// is missing, or nobody has it, an error is added to the form and the user returned is nil.
+func (app *application) chatUser(form *forms.Form, field string) (*models.User, error) {
+	form.Set(field, strings.TrimSpace(form.Get(field)))
+	form.Required(field)
+	if !form.Valid() {
+		return nil, nil
+	}
+	user, err := app.users.GetByEmail(form.Get(field))
+	if errors.Is(err, models.ErrNoRecord) {
+		form.Errors.Add(field, "There`s nobody with that email address")
+		return nil, nil
+	}
+	return user, err
 }
 
-func (app *application) deleteSnippet(w http.ResponseWriter, r *http.Request) {
-	//form := forms.New(r.Form.Get())
-	fmt.Println("deleteSnippet method...")
+// The chatRoomField function returns the room given by the room field of a form, or the general room
+// if the field is empty. It returns false if the field isn`t a room ID.
+func chatRoomField(form *forms.Form) (int, bool) {
+	v := form.Get("room")
+	if v == "" {
+		return models.GeneralRoom, true
+	}
+	id, err := strconv.Atoi(v)
+	if err != nil || id < 1 {
+		return 0, false
+	}
+	return id, true
+}
+
+// The renderChat helper loads a page of messages from a chat room, with the names of their authors,
+// and shows them with the chat page, along with the rooms the user can switch to. Members reading
+// the latest messages have the room marked read.
+func (app *application) renderChat(w http.ResponseWriter, r *http.Request, roomID int, td *templateData) {
+	before := 0
+	if v := r.URL.Query().Get("before"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			app.notFound(w)
+			return
+		}
+		before = n
+	}
+
+	userID := app.authenticatedUserID(r)
+	room, err := app.viewRoom(roomID, userID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	page, err := app.messages.Latest(room.ID, before, chatPageSize)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	err = app.messageAuthors(page.Messages)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if room.Member && before == 0 && len(page.Messages) > 0 {
+		err = app.rooms.MarkRead(room.ID, userID, page.Messages[len(page.Messages)-1].ID)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+
+	// Anonymous visitors aren`t a member of any room, but can read the public ones.
+	td.Rooms = []*models.Room{}
+	if userID != 0 {
+		td.Rooms, err = app.rooms.ForUser(userID)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+	td.PublicRooms, err = app.rooms.Public(userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	td.Room = room
+	td.Messages = page
+	app.render(w, r, "chat.page.tmpl", td)
+}
+
+// The messageAuthors helper fills in the names of the authors of chat messages. A page of messages
+// usually comes from a handful of people, so each of them is only looked up once.
+func (app *application) messageAuthors(messages []*models.Message) error {
+	names := map[int]string{}
+	for _, msg := range messages {
+		name, ok := names[msg.UserId]
+		if !ok {
+			user, err := app.users.Get(msg.UserId)
+			if err != nil {
+				return err
+			}
+			name = user.Name
+			names[msg.UserId] = name
+		}
+		msg.UserName = name
+	}
+	return nil
+}
+
+// Add new createSnippetForm handler, which for now a placeholder response.
+func (app *application) createSnippetForm(w http.ResponseWriter, r *http.Request) {
+	app.render(w, r, "create.page.tmpl", &templateData{
+		// Pass the new empty form.Form object to the template.
+		Form:  forms.New(nil),
+		Files: withBlankFiles(nil),
+	})
+}
+
+// The maxViewLimit constant is the largest view limit which can be chosen for a snippet, and
+// maxTags is the largest number of tags a snippet can have.
+const (
+	maxViewLimit = 1000
+	maxTags      = 10
+)
+
+func (app *application) createSnippet(w http.ResponseWriter, r *http.Request) {
+	// First we call r.ParseForm() which add any data in POST request bodies to the r.PostForm map.
+	// This also works in the same way for PUT and PATCH requests. If there any errors, we use
+	// our app.Client helper to send a 400 Bad Request response to the user.
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	maxExpiry, err := app.maxExpiry(r)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// Create a new forms.Form struct containing the POSTed data from the form, the use the
+	// validation methods to check the validation. The snippet is owned by the currently
+	// authenticated user.
+	form := forms.New(r.PostForm)
+	s := newSnippet(form, maxExpiry, app.session.GetInt(r, "authenticatedUserID"))
+
+	// If the form isn`t valid, redisplay the template passing in the form.Form object as the data.
+	if !form.Valid() {
+		app.render(w, r, "create.page.tmpl", &templateData{Form: form, Files: withBlankFiles(s.Files)})
+		return
+	}
+
+	err = app.snippets.Insert(s)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.events.publish(eventSnippetCreated, s)
+
+	// Use the Put() method to add a string value ("Your snippet was saved successfully1") and the
+	// corresponding key ("flash") to the session data. Note yhat if there`s no session for the current user
+	// (or their session has expired) the new, empty session for them will automatically be created
+	// by the session middleware.
+	app.session.Put(r, "flash", "Snippet successfully created!")
+
+	http.Redirect(w, r, "/s/"+s.Slug, http.StatusSeeOther)
+}
+
+// The newSnippet function validates the fields of a new snippet, adding any problems to the form
+// errors, and returns the snippet they describe. It is shared by the create form and the paste
+// endpoint, so snippets are checked the same way however they are made.
+func newSnippet(form *forms.Form, maxExpiry time.Duration, userID int) *models.Snippet {
+	form.Required("title", "content", "expires", "visibility")
+	form.MaxLength("title", 100)
+	form.PermittedValues("visibility", models.VisibilityPublic, models.VisibilityUnlisted, models.VisibilityPrivate)
+	expires, maxViews := parseExpiry(form, maxExpiry, time.Now(), true)
+	form.Set("tags", strings.ToLower(form.Get("tags")))
+	tags := form.List("tags", maxTags, forms.TagRX)
+	form.PermittedValues("language", languageNames()...)
+	checkFilename(form)
+
+	// Because the form data (with type url.Values) has been anonymously embedded in the form.Form struct,
+	// we can use the Get() method to retrieve the validated value for the particular form filed.
+	s := &models.Snippet{
+		UserID:     userID,
+		Title:      form.Get("title"),
+		Filename:   form.Get("filename"),
+		Content:    form.Get("content"),
+		Expires:    expires,
+		Visibility: form.Get("visibility"),
+		MaxViews:   maxViews,
+		Tags:       tags,
+		Language:   form.Get("language"),
+	}
+
+	// If the author didn`t say what language the snippet is in, make a guess. The name of the first
+	// file is a better clue than the title, if there is one.
+	s.LanguageConfidence = 1
+	if s.Language == "" {
+		s.Language, s.LanguageConfidence = highlight.Detect(firstNonEmpty(s.Filename, s.Title), s.Content)
+	}
+	// The further files are read once the language is known, as it goes into the first file`s name.
+	s.Files = parseFiles(form, fileName(s))
+	return s
+}
+
+// The changeExpiry handler lets the owner of a snippet extend or shorten its life.
+func (app *application) changeExpiry(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.snippetFromURL(w, r)
+	if !ok {
+		return
+	}
+	if !app.isOwner(r, s) {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	maxExpiry, err := app.maxExpiry(r)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("expires")
+	expires, _ := parseExpiry(form, maxExpiry, time.Now(), false)
+
+	if !form.Valid() {
+		app.renderSnippet(w, r, &templateData{Form: form, Snippet: s})
+		return
+	}
+
+	err = app.snippets.SetExpires(s.ID, expires)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.session.Put(r, "flash", "Snippet expiry updated!")
+	http.Redirect(w, r, "/s/"+s.Slug, http.StatusSeeOther)
+}
+
+// The setExpiryLimits handler changes the longest time snippets created by ordinary users and
+// by administrators may live for. The limits are entered in days, and 0 means no limit.
+func (app *application) setExpiryLimits(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	roles := []string{models.RoleUser, models.RoleAdministrator}
+	form := forms.New(r.PostForm)
+	form.Required(roles...)
+	for _, role := range roles {
+		form.IntRange(role, 0, 36500)
+	}
+	if !form.Valid() {
+		app.session.Put(r, "flash", "Expiry limits must be a number of days between 0 and 36500.")
+		http.Redirect(w, r, "/snippet/admin", http.StatusSeeOther)
+		return
+	}
+
+	for _, role := range roles {
+		days, _ := strconv.Atoi(form.Get(role))
+		err = app.expiryLimits.Set(role, time.Duration(days)*day)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+
+	app.session.Put(r, "flash", "Expiry limits updated!")
+	http.Redirect(w, r, "/snippet/admin", http.StatusSeeOther)
+}
+
+// The editSnippetForm handler displays the edit form, pre-filled with the snippet`s current
+// title and content. Only the owner of a snippet can edit it.
+func (app *application) editSnippetForm(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.snippetFromURL(w, r)
+	if !ok {
+		return
+	}
+	if !app.isOwner(r, s) {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+
+	files, err := app.snippets.Files(s.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.render(w, r, "edit.page.tmpl", &templateData{
+		Form: forms.New(url.Values{
+			"title":    []string{s.Title},
+			"filename": []string{s.Filename},
+			"content":  []string{s.Content},
+		}),
+		Snippet: s,
+		Files:   withBlankFiles(files),
+	})
+}
+
+// The editSnippet handler saves the submitted title and content as a new revision of the snippet,
+// along with its further files.
+func (app *application) editSnippet(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.snippetFromURL(w, r)
+	if !ok {
+		return
+	}
+	if !app.isOwner(r, s) {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	edited := editedSnippet(form, s)
+
+	if !form.Valid() {
+		app.render(w, r, "edit.page.tmpl", &templateData{Form: form, Snippet: s, Files: withBlankFiles(edited.Files)})
+		return
+	}
+
+	userID := app.session.GetInt(r, "authenticatedUserID")
+	err = app.snippets.Update(edited, userID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.session.Put(r, "flash", "Snippet successfully updated!")
+	http.Redirect(w, r, "/s/"+s.Slug, http.StatusSeeOther)
+}
+
+// The editedSnippet function validates the fields which can be changed when a snippet is edited,
+// adding any problems to the form errors, and returns a copy of the snippet with the changes made.
+// The snippet is copied, so the one returned by the model isn`t changed.
+func editedSnippet(form *forms.Form, s *models.Snippet) *models.Snippet {
+	form.Required("title", "content")
+	form.MaxLength("title", 100)
+	checkFilename(form)
+
+	edited := *s
+	edited.Title = form.Get("title")
+	edited.Filename = form.Get("filename")
+	edited.Content = form.Get("content")
+	edited.Files = parseFiles(form, fileName(&edited))
+	return &edited
+}
+
+// The snippetHistory handler lists every revision of a snippet, newest first.
+func (app *application) snippetHistory(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.snippetFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	revisions, err := app.snippets.Revisions(s.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.render(w, r, "history.page.tmpl", &templateData{
+		Snippet:   s,
+		Revisions: revisions,
+	})
+}
+
+// The showRevision handler displays a single past revision of a snippet.
+func (app *application) showRevision(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.snippetFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	version, err := strconv.Atoi(r.URL.Query().Get(":version"))
+	if err != nil || version < 1 {
+		app.notFound(w)
+		return
+	}
+
+	revision, err := app.snippets.Revision(s.ID, version)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.render(w, r, "revision.page.tmpl", &templateData{
+		Snippet:  s,
+		Revision: revision,
+		Files:    revisionFiles(s, revision),
+	})
+}
+
+// The diffContext constant is the number of unchanged lines shown around every change in a diff.
+const diffContext = 3
+
+// The snippetDiff handler shows what changed between two revisions of a snippet, either
+// side-by-side (the default) or as a unified diff when "view=unified" is requested.
+func (app *application) snippetDiff(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.snippetFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	from, to, ok := app.revisionsToCompare(w, r, s)
+	if !ok {
+		return
+	}
+
+	files := diffFiles(revisionFiles(s, from), revisionFiles(s, to))
+	changed := from.Title != to.Title
+	for _, fd := range files {
+		changed = changed || fd.Changed
+	}
+
+	app.render(w, r, "diff.page.tmpl", &templateData{
+		Snippet: s,
+		Diff: &diffData{
+			From:    from,
+			To:      to,
+			Changed: changed,
+			Files:   files,
+			Unified: r.URL.Query().Get("view") == "unified",
+		},
+	})
+}
+
+// The rawSnippetDiff handler sends the unified diff between two revisions as a downloadable
+// text/x-diff file, which can be fed straight to patch(1).
+func (app *application) rawSnippetDiff(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.snippetFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	from, to, ok := app.revisionsToCompare(w, r, s)
+	if !ok {
+		return
+	}
+
+	// Every file gets its own section, with added and removed files diffed against /dev/null
+	// the way patch(1) expects.
+	var unified strings.Builder
+	for _, fd := range diffFiles(revisionFiles(s, from), revisionFiles(s, to)) {
+		oldName, newName := "/dev/null", "/dev/null"
+		if fd.OldName != "" {
+			oldName = fmt.Sprintf("a/%s (revision %d)", fd.OldName, from.Version)
+		}
+		if fd.NewName != "" {
+			newName = fmt.Sprintf("b/%s (revision %d)", fd.NewName, to.Version)
+		}
+		unified.WriteString(diff.Unified(oldName, newName, fd.Lines, diffContext))
+	}
+
+	w.Header().Set("Content-Type", "text/x-diff; charset=utf-8")
+	w.Header().Set("Content-Disposition",
+		fmt.Sprintf(`attachment; filename="snippet-%d-r%d-r%d.diff"`, s.ID, from.Version, to.Version))
+	w.Write([]byte(unified.String()))
+}
+
+// The rawSnippet handler serves the content of a snippet as plain text, so it can be fetched with
+// tools like curl. For a multi-file snippet, this is its first file.
+func (app *application) rawSnippet(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.snippetFromURL(w, r)
+	if !ok {
+		return
+	}
+	serveRaw(w, s.Content)
+}
+
+// The rawSnippetBySlug handler serves the content of a snippet`s first file as plain text, like
+// rawSnippet, to anyone with a link to the snippet.
+func (app *application) rawSnippetBySlug(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.snippetFromSlug(w, r)
+	if !ok {
+		return
+	}
+	serveRaw(w, s.Content)
+}
+
+// The downloadSnippet handler sends the content of a snippet as a file to save, named after the
+// snippet`s title and language unless the author named it.
+func (app *application) downloadSnippet(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.snippetFromURL(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": fileName(s)}))
+	serveRaw(w, s.Content)
+}
+
+// The downloadSnippetBySlug handler sends the content of a snippet`s first file to save, like
+// downloadSnippet, to anyone with a link to the snippet.
+func (app *application) downloadSnippetBySlug(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.snippetFromSlug(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": fileName(s)}))
+	serveRaw(w, s.Content)
+}
+
+// The rawFile handler serves one file of a snippet, picked by name, as plain text.
+func (app *application) rawFile(w http.ResponseWriter, r *http.Request) {
+	if s, ok := app.snippetFromURL(w, r); ok {
+		app.serveFile(w, r, s)
+	}
+}
+
+// The rawFileBySlug handler is rawFile for snippets addressed by their slug, such as unlisted ones.
+func (app *application) rawFileBySlug(w http.ResponseWriter, r *http.Request) {
+	if s, ok := app.snippetFromSlug(w, r); ok {
+		app.serveFile(w, r, s)
+	}
+}
+
+// The serveFile helper sends the file of a snippet named by the ":name" URL parameter as plain text.
+func (app *application) serveFile(w http.ResponseWriter, r *http.Request, s *models.Snippet) {
+	files, err := app.snippets.Files(s.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	name := r.URL.Query().Get(":name")
+	for _, f := range allFiles(s, files) {
+		if f.Name == name {
+			serveRaw(w, f.Content)
+			return
+		}
+	}
+	app.notFound(w)
+}
+
+// The zipSnippet handler sends every file of a snippet in a zip archive named after the snippet.
+func (app *application) zipSnippet(w http.ResponseWriter, r *http.Request) {
+	if s, ok := app.snippetFromURL(w, r); ok {
+		app.serveZip(w, s)
+	}
+}
+
+// The zipSnippetBySlug handler is zipSnippet for snippets addressed by their slug.
+func (app *application) zipSnippetBySlug(w http.ResponseWriter, r *http.Request) {
+	if s, ok := app.snippetFromSlug(w, r); ok {
+		app.serveZip(w, s)
+	}
+}
+
+// The serveZip helper sends every file of a snippet in a zip archive.
+func (app *application) serveZip(w http.ResponseWriter, s *models.Snippet) {
+	files, err := app.snippets.Files(s.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// The archive is built in memory before anything is sent, so that an error can still be
+	// reported properly. Snippets are small enough for this not to matter.
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range allFiles(s, files) {
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: f.Name, Method: zip.Deflate, Modified: s.Created})
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		_, err = io.WriteString(fw, f.Content)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+	err = zw.Close()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment",
+		map[string]string{"filename": baseName(s.Title) + ".zip"}))
+	w.Write(buf.Bytes())
+}
+
+// The revisionsToCompare helper loads the revisions named by the "from" and "to" query string
+// parameters. When they are missing, the latest revision is compared with the one before it.
+func (app *application) revisionsToCompare(w http.ResponseWriter, r *http.Request, s *models.Snippet) (*models.Revision, *models.Revision, bool) {
+	fromVersion, toVersion := 0, 0
+	var err error
+	if v := r.URL.Query().Get("from"); v != "" {
+		fromVersion, err = strconv.Atoi(v)
+		if err != nil || fromVersion < 1 {
+			app.clientError(w, http.StatusBadRequest)
+			return nil, nil, false
+		}
+	}
+	if v := r.URL.Query().Get("to"); v != "" {
+		toVersion, err = strconv.Atoi(v)
+		if err != nil || toVersion < 1 {
+			app.clientError(w, http.StatusBadRequest)
+			return nil, nil, false
+		}
+	}
+
+	if toVersion == 0 {
+		revisions, err := app.snippets.Revisions(s.ID)
+		if err != nil {
+			app.serverError(w, err)
+			return nil, nil, false
+		}
+		if len(revisions) == 0 {
+			app.notFound(w)
+			return nil, nil, false
+		}
+		toVersion = revisions[0].Version
+	}
+	if fromVersion == 0 {
+		fromVersion = toVersion - 1
+		if fromVersion < 1 {
+			fromVersion = 1
+		}
+	}
+
+	from, err := app.snippets.Revision(s.ID, fromVersion)
+	if err == nil {
+		var to *models.Revision
+		to, err = app.snippets.Revision(s.ID, toVersion)
+		if err == nil {
+			return from, to, true
+		}
+	}
+	if errors.Is(err, models.ErrNoRecord) {
+		app.notFound(w)
+	} else {
+		app.serverError(w, err)
+	}
+	return nil, nil, false
+}
+
+// The deleteSnippet handler moves a snippet to its owner`s trash. Only the owner
+// of the snippet and administrators are allowed to do this. Snippets which have
+// expired can still be moved to the trash. Afterwards the user is sent back to
+// the page named by the "from" field, or to their own snippets.
+func (app *application) deleteSnippet(w http.ResponseWriter, r *http.Request) {
+	id, ok := app.postedID(w, r)
+	if !ok {
+		return
+	}
+
+	s, err := app.snippets.Find(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	if !app.canModify(r, s) {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+
+	err = app.snippets.Delete(s.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+	app.events.publish(eventSnippetDeleted, s)
+
+	app.session.Put(r, "flash", "Snippet moved to trash.")
+	http.Redirect(w, r, localPath(r.PostForm.Get("from"), "/user/snippets"), http.StatusSeeOther)
+}
+
+// The restoreSnippet handler takes one of the current user`s snippets out of the trash.
+func (app *application) restoreSnippet(w http.ResponseWriter, r *http.Request) {
+	id, ok := app.postedID(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.snippets.Restore(id, app.session.GetInt(r, "authenticatedUserID"))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.session.Put(r, "flash", "Snippet restored!")
+	http.Redirect(w, r, "/user/snippets", http.StatusSeeOther)
+}
+
+// The purgeSnippet handler permanently removes a snippet from the trash. The route is
+// restricted to administrators.
+func (app *application) purgeSnippet(w http.ResponseWriter, r *http.Request) {
+	id, ok := app.postedID(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.snippets.Purge(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.session.Put(r, "flash", "Snippet permanently deleted.")
+	http.Redirect(w, r, "/snippet/admin", http.StatusSeeOther)
+}
+
+// The userTrash handler lists the snippets the authenticated user has deleted.
+func (app *application) userTrash(w http.ResponseWriter, r *http.Request) {
+	s, err := app.snippets.Trash(app.session.GetInt(r, "authenticatedUserID"))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.render(w, r, "trash.page.tmpl", &templateData{
+		Snippets: s,
+	})
+}
+
+// The tokenExpiries slice holds the choices of expiry for a new API token: a number of days, or never.
+var tokenExpiries = []string{"7", "30", "90", "365", "never"}
+
+// The userTokens handler lists the authenticated user`s API tokens, with a form to create another.
+func (app *application) userTokens(w http.ResponseWriter, r *http.Request) {
+	app.renderTokens(w, r, &templateData{Form: forms.New(nil)})
+}
+
+// The createToken handler creates a new API token. Its text is shown on the page it responds with,
+// rather than after a redirect, as it can`t be shown again once the page is gone.
+func (app *application) createToken(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("name", "expires")
+	form.MaxLength("name", 100)
+	form.PermittedValues("expires", tokenExpiries...)
+
+	// A token needs at least one scope, and only administrators can give their tokens the admin scope.
+	scopes := form.Values["scopes"]
+	if len(scopes) == 0 {
+		form.Errors.Add("scopes", "Choose at least one scope")
+	}
+	for _, scope := range scopes {
+		if !hasScope(models.Scopes, scope) || (scope == models.ScopeAdmin && !app.isAdministrator(r)) {
+			form.Errors.Add("scopes", "This scope isn`t available")
+			break
+		}
+	}
+
+	if !form.Valid() {
+		app.renderTokens(w, r, &templateData{Form: form})
+		return
+	}
+
+	var expires time.Time
+	if days, err := strconv.Atoi(form.Get("expires")); err == nil {
+		expires = time.Now().AddDate(0, 0, days)
+	}
+
+	token, err := app.tokens.Insert(app.session.GetInt(r, "authenticatedUserID"), form.Get("name"), scopes, expires)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.renderTokens(w, r, &templateData{Form: forms.New(nil), NewToken: token})
+}
+
+// The revokeToken handler deletes one of the authenticated user`s API tokens.
+func (app *application) revokeToken(w http.ResponseWriter, r *http.Request) {
+	id, ok := app.postedID(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.tokens.Delete(id, app.session.GetInt(r, "authenticatedUserID"))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.session.Put(r, "flash", "Token revoked.")
+	http.Redirect(w, r, "/user/tokens", http.StatusSeeOther)
+}
+
+// The renderTokens helper loads the authenticated user`s API tokens and shows them with the tokens page.
+func (app *application) renderTokens(w http.ResponseWriter, r *http.Request, td *templateData) {
+	tokens, err := app.tokens.ByUser(app.session.GetInt(r, "authenticatedUserID"))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	td.Tokens = tokens
+	app.render(w, r, "tokens.page.tmpl", td)
 }
 
 func (app *application) signupUser(w http.ResponseWriter, r *http.Request) {
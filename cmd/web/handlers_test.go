@@ -1,10 +1,17 @@
 package main
 
 import (
+	"archive/zip"
 	"bytes"
+	"io/ioutil"
+	"mime/multipart"
 	"net/http"
 	"net/url"
+	"sabiraliyev.net/snippetbox/pkg/models"
+	"sabiraliyev.net/snippetbox/pkg/models/mock"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestPing(t *testing.T) {
@@ -23,6 +30,74 @@ func TestPing(t *testing.T) {
 	}
 }
 
+func TestHome(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody []byte
+	}{
+		{"First page", "/", http.StatusOK, []byte(`href="/?sort=newest&after=bmV4dA"`)},
+		{"Next page", "/?sort=newest&after=bmV4dA", http.StatusOK, []byte(`href="/?sort=newest&before=cHJldg"`)},
+		{"Sorted by title", "/?sort=title", http.StatusOK, []byte(`<strong>title</strong>`)},
+		{"Invalid cursor", "/?after=nonsense", http.StatusBadRequest, nil},
+		{"Tag cloud", "/", http.StatusOK, []byte(`<a href="/tag/poetry" title="1 snippets">poetry</a>`)},
+		{"Tag", "/tag/poetry", http.StatusOK, []byte("An old silent pond")},
+		{"Unused tag", "/tag/billing", http.StatusOK, []byte("There are no snippets with this tag.")},
+		{"Invalid tag", "/tag/Not%20A%20Tag", http.StatusNotFound, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestSearch(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody []byte
+	}{
+		{"No query", "/search", http.StatusOK, []byte("Type some words")},
+		{"Match", "/search?q=silent", http.StatusOK, []byte("An old <mark>silent</mark> pond &lt;3")},
+		{"No match", "/search?q=kubernetes", http.StatusOK, []byte("No snippets match")},
+		{"Invalid page", "/search?q=silent&page=0", http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
 func TestShowSnippet(t *testing.T) {
 	// Create new instance of our application struct which uses the mocked dependencies.
 	app := newTestApplication(t)
@@ -40,11 +115,18 @@ func TestShowSnippet(t *testing.T) {
 	}{
 		{"Valid ID", "/snippet/1", http.StatusOK, []byte("An old silent pond...")},
 		{"Non-existent ID", "/snippet/2", http.StatusNotFound, nil},
+		{"Private ID", "/snippet/3", http.StatusNotFound, nil},
 		{"Negative ID", "/snippet/-1", http.StatusNotFound, nil},
 		{"Decimal ID", "/snippet/1.23", http.StatusNotFound, nil},
 		{"String ID", "/snippet/foo", http.StatusNotFound, nil},
 		{"Empty ID", "/snippet/", http.StatusNotFound, nil},
 		{"Trailing slash ID", "/snippet/1/", http.StatusNotFound, nil},
+		{"Valid slug", "/s/pondpondpo", http.StatusOK, []byte("An old silent pond...")},
+		{"Line anchors", "/s/pondpondpo", http.StatusOK, []byte(`<span class="line" id="L1"><a class="ln" href="#L1">1</a>`)},
+		{"Second file anchors", "/s/pondpondpo", http.StatusOK, []byte(`<span class="line" id="F2-L1"><a class="ln" href="#F2-L1">1</a>`)},
+		{"Raw file link", "/s/pondpondpo", http.StatusOK, []byte(`<a href="/snippet/1/raw/frog.sh">Raw</a>`)},
+		{"Private slug", "/s/tracetrace", http.StatusNotFound, nil},
+		{"Non-existent slug", "/s/nothinghere", http.StatusNotFound, nil},
 	}
 
 	for _, tt := range tests {
@@ -63,6 +145,243 @@ func TestShowSnippet(t *testing.T) {
 
 }
 
+func TestRawSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name            string
+		urlPath         string
+		wantCode        int
+		wantBody        []byte
+		wantDisposition string
+	}{
+		{"Raw", "/snippet/1/raw", http.StatusOK, []byte("An old silent pond..."), ""},
+		{"Download", "/snippet/1/download", http.StatusOK, []byte("An old silent pond..."), "attachment; filename=an-old-silent-pond.txt"},
+		{"Private raw", "/snippet/3/raw", http.StatusNotFound, nil, ""},
+		{"Private download", "/snippet/3/download", http.StatusNotFound, nil, ""},
+		{"View limited raw", "/snippet/4/raw", http.StatusNotFound, nil, ""},
+		{"Non-existent ID", "/snippet/2/raw", http.StatusNotFound, nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, header, body := ts.get(t, tt.urlPath)
+
+			if code != tt.wantCode {
+				t.Fatalf("want %d; got %d", tt.wantCode, code)
+			}
+			if code != http.StatusOK {
+				return
+			}
+			if !bytes.Equal(body, tt.wantBody) {
+				t.Errorf("want body %q; got %q", tt.wantBody, body)
+			}
+			if header.Get("Content-Disposition") != tt.wantDisposition {
+				t.Errorf("want disposition %q; got %q", tt.wantDisposition, header.Get("Content-Disposition"))
+			}
+
+			wantHeaders := map[string]string{
+				"Content-Type":            "text/plain; charset=utf-8",
+				"X-Content-Type-Options":  "nosniff",
+				"Content-Security-Policy": "default-src 'none'; sandbox",
+			}
+			for name, want := range wantHeaders {
+				if got := header.Get(name); got != want {
+					t.Errorf("want %s %q; got %q", name, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestRawSnippetBySlug(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		wantCode     int
+		wantBody     []byte
+		wantLocation string
+	}{
+		{"Raw", "/s/pondpondpo/raw", http.StatusOK, []byte("An old silent pond..."), ""},
+		{"Download", "/s/pondpondpo/download", http.StatusOK, []byte("An old silent pond..."), ""},
+		{"Unlisted raw", "/s/hiddenhidd/raw", http.StatusOK, []byte("A hidden frog leaps..."), ""},
+		{"Unlisted download", "/s/hiddenhidd/download", http.StatusOK, []byte("A hidden frog leaps..."), ""},
+		{"Private raw", "/s/tracetrace/raw", http.StatusNotFound, nil, ""},
+		{"Private download", "/s/tracetrace/download", http.StatusNotFound, nil, ""},
+		{"View limited raw", "/s/burnburnbu/raw", http.StatusSeeOther, nil, "/s/burnburnbu"},
+		{"View limited download", "/s/burnburnbu/download", http.StatusSeeOther, nil, "/s/burnburnbu"},
+		{"Non-existent slug", "/s/nopenopeno/raw", http.StatusNotFound, nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, header, body := ts.get(t, tt.urlPath)
+
+			if code != tt.wantCode {
+				t.Fatalf("want %d; got %d", tt.wantCode, code)
+			}
+			if header.Get("Location") != tt.wantLocation {
+				t.Errorf("want Location %q; got %q", tt.wantLocation, header.Get("Location"))
+			}
+			if code == http.StatusOK && !bytes.Equal(body, tt.wantBody) {
+				t.Errorf("want body %q; got %q", tt.wantBody, body)
+			}
+		})
+	}
+
+	// The unlisted snippet`s page links to the raw routes by slug, as they can`t be fetched by ID.
+	_, _, body := ts.get(t, "/s/hiddenhidd")
+	if !bytes.Contains(body, []byte(`<a href="/s/hiddenhidd/raw">Raw</a>`)) {
+		t.Error("want a raw link by slug")
+	}
+}
+
+func TestSnippetFiles(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody []byte
+	}{
+		{"First file", "/snippet/1/raw/an-old-silent-pond.txt", http.StatusOK, []byte("An old silent pond...")},
+		{"Second file", "/snippet/1/raw/frog.sh", http.StatusOK, []byte("echo splash")},
+		{"Unknown file", "/snippet/1/raw/toad.sh", http.StatusNotFound, nil},
+		{"Private snippet", "/snippet/3/raw/internal-stack-trace.txt", http.StatusNotFound, nil},
+		{"View limited snippet", "/snippet/4/raw/database-password.txt", http.StatusNotFound, nil},
+		{"Unlisted snippet", "/snippet/5/raw/draft-haiku.txt", http.StatusNotFound, nil},
+		{"By slug", "/s/pondpondpo/raw/frog.sh", http.StatusOK, []byte("echo splash")},
+		{"Unlisted by slug", "/s/hiddenhidd/raw/draft-haiku.txt", http.StatusOK, []byte("A hidden frog leaps...")},
+		{"Private by slug", "/s/tracetrace/raw/internal-stack-trace.txt", http.StatusNotFound, nil},
+		{"View limited by slug", "/s/burnburnbu/raw/database-password.txt", http.StatusSeeOther, nil},
+		{"Unknown slug", "/s/nothinghere/raw/frog.sh", http.StatusNotFound, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, header, body := ts.get(t, tt.urlPath)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+			if code == http.StatusOK && header.Get("Content-Type") != "text/plain; charset=utf-8" {
+				t.Errorf("want plain text; got %q", header.Get("Content-Type"))
+			}
+		})
+	}
+
+	for _, urlPath := range []string{"/snippet/1/zip", "/s/pondpondpo/zip"} {
+		t.Run("Zip "+urlPath, func(t *testing.T) {
+			code, header, body := ts.get(t, urlPath)
+			if code != http.StatusOK {
+				t.Fatalf("want %d; got %d", http.StatusOK, code)
+			}
+			if want := `attachment; filename=an-old-silent-pond.zip`; header.Get("Content-Disposition") != want {
+				t.Errorf("want %q; got %q", want, header.Get("Content-Disposition"))
+			}
+
+			zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, f := range zr.File {
+				names = append(names, f.Name)
+			}
+			if got := strings.Join(names, " "); got != "an-old-silent-pond.txt frog.sh" {
+				t.Errorf("want the snippet`s files in order; got %q", got)
+			}
+		})
+	}
+}
+
+func TestPaste(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	var multipartBody bytes.Buffer
+	mw := multipart.NewWriter(&multipartBody)
+	fw, err := mw.CreateFormFile("file", "main.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write([]byte("package main\n"))
+	mw.Close()
+
+	tests := []struct {
+		name        string
+		urlPath     string
+		token       string
+		contentType string
+		headers     map[string]string
+		body        []byte
+		wantCode    int
+		wantBody    []byte
+	}{
+		{"No token", "/paste", "", "", nil, []byte("x"), http.StatusUnauthorized, nil},
+		{"Invalid token", "/paste", "sb_mallory", "", nil, []byte("x"), http.StatusUnauthorized, nil},
+		{"Raw body", "/paste?title=Notes&expires=30m", "sb_alice", "application/x-www-form-urlencoded", nil, []byte("a=b"), http.StatusCreated, []byte("/s/newsnippet\n")},
+		{"Headers", "/paste", "sb_alice", "", map[string]string{"X-Title": "Notes", "X-Visibility": "public"}, []byte("x"), http.StatusCreated, []byte("/s/newsnippet\n")},
+		{"Multipart file", "/paste", "sb_alice", mw.FormDataContentType(), nil, multipartBody.Bytes(), http.StatusCreated, []byte("/s/newsnippet\n")},
+		{"Empty body", "/paste", "sb_alice", "", nil, nil, http.StatusBadRequest, []byte("content: This field cannot be blank")},
+		{"Bad expiry", "/paste?expires=soon", "sb_alice", "", nil, []byte("x"), http.StatusBadRequest, []byte("expires:")},
+		{"Bad visibility", "/paste", "sb_alice", "", map[string]string{"X-Visibility": "secret"}, []byte("x"), http.StatusBadRequest, []byte("visibility:")},
+		{"Not UTF-8", "/paste", "sb_alice", "", nil, []byte{0xff, 0xfe}, http.StatusBadRequest, nil},
+		{"Too large", "/paste", "sb_alice", "", nil, bytes.Repeat([]byte("x"), maxPasteSize+1), http.StatusRequestEntityTooLarge, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, ts.URL+tt.urlPath, bytes.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+
+			rs, err := ts.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rs.Body.Close()
+			body, err := ioutil.ReadAll(rs.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if rs.StatusCode != tt.wantCode {
+				t.Errorf("want %d; got %d (%s)", tt.wantCode, rs.StatusCode, body)
+			}
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q; got %q", tt.wantBody, body)
+			}
+			if rs.StatusCode == http.StatusUnauthorized && rs.Header.Get("WWW-Authenticate") == "" {
+				t.Error("want a WWW-Authenticate header")
+			}
+		})
+	}
+}
+
 func TestSignupUser(t *testing.T) {
 	// Create an application struct including our mocked dependencies
 	// and setup the test server for running and end-to-end test.
@@ -116,3 +435,753 @@ func TestSignupUser(t *testing.T) {
 		})
 	}
 }
+
+func TestUserSnippets(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// An anonymous visitor has no snippets of their own, so they should be sent to the login page.
+	code, header, _ := ts.get(t, "/user/snippets")
+
+	if code != http.StatusSeeOther {
+		t.Errorf("want %d; got %d", http.StatusSeeOther, code)
+	}
+
+	if loc := header.Get("Location"); loc != "/user/login" {
+		t.Errorf("want Location %q; got %q", "/user/login", loc)
+	}
+}
+
+func TestTrash(t *testing.T) {
+	tests := []struct {
+		name         string
+		email        string
+		urlPath      string
+		id           string
+		from         string
+		wantCode     int
+		wantLocation string
+	}{
+		{"Delete anonymously", "", "/snippet/delete", "1", "", http.StatusSeeOther, "/user/login"},
+		{"Delete own snippet", "alice@example.com", "/snippet/delete", "1", "/user/snippets", http.StatusSeeOther, "/user/snippets"},
+		{"Delete expired snippet", "alice@example.com", "/snippet/delete", "7", "/user/snippets", http.StatusSeeOther, "/user/snippets"},
+		{"Delete someone else`s snippet", "alice@example.com", "/snippet/delete", "3", "/user/snippets", http.StatusForbidden, ""},
+		{"Delete as administrator", "admin@example.com", "/snippet/delete", "3", "/snippet/admin", http.StatusSeeOther, "/snippet/admin"},
+		{"Delete back to another site", "alice@example.com", "/snippet/delete", "1", "//example.org/", http.StatusSeeOther, "/user/snippets"},
+		{"Delete missing snippet", "alice@example.com", "/snippet/delete", "99", "", http.StatusNotFound, ""},
+		{"Delete invalid ID", "alice@example.com", "/snippet/delete", "frog", "", http.StatusBadRequest, ""},
+		{"Restore own snippet", "alice@example.com", "/snippet/restore", "6", "", http.StatusSeeOther, "/user/snippets"},
+		{"Restore someone else`s snippet", "admin@example.com", "/snippet/restore", "6", "", http.StatusNotFound, ""},
+		{"Restore live snippet", "alice@example.com", "/snippet/restore", "1", "", http.StatusNotFound, ""},
+		{"Purge as owner", "alice@example.com", "/snippet/purge", "6", "", http.StatusForbidden, ""},
+		{"Purge as administrator", "admin@example.com", "/snippet/purge", "6", "", http.StatusSeeOther, "/snippet/admin"},
+		{"Purge missing snippet", "admin@example.com", "/snippet/purge", "99", "", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			var csrfToken string
+			if tt.email != "" {
+				csrfToken = ts.login(t, tt.email)
+			} else {
+				_, _, body := ts.get(t, "/user/login")
+				csrfToken = extractSCRFToken(t, body)
+			}
+
+			form := url.Values{}
+			form.Add("csrf_token", csrfToken)
+			form.Add("id", tt.id)
+			form.Add("from", tt.from)
+			code, header, _ := ts.postForm(t, tt.urlPath, form)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if loc := header.Get("Location"); loc != tt.wantLocation {
+				t.Errorf("want Location %q; got %q", tt.wantLocation, loc)
+			}
+		})
+	}
+}
+
+func TestUserTrash(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// Anonymous visitors have no trash, so they are sent to the login page.
+	code, header, _ := ts.get(t, "/user/trash")
+	if code != http.StatusSeeOther || header.Get("Location") != "/user/login" {
+		t.Errorf("want redirect to /user/login; got %d %q", code, header.Get("Location"))
+	}
+
+	ts.login(t, "alice@example.com")
+	code, _, body := ts.get(t, "/user/trash")
+	if code != http.StatusOK {
+		t.Errorf("want %d; got %d", http.StatusOK, code)
+	}
+	if !bytes.Contains(body, []byte("Forgotten limerick")) {
+		t.Errorf("want body to list the deleted snippet")
+	}
+}
+
+func TestShowAdminPage(t *testing.T) {
+	tests := []struct {
+		name     string
+		email    string
+		wantCode int
+		wantBody []byte
+	}{
+		{"Anonymous", "", http.StatusForbidden, nil},
+		{"Not an administrator", "alice@example.com", http.StatusForbidden, nil},
+		{"Administrator", "admin@example.com", http.StatusOK, []byte("Forgotten limerick")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			if tt.email != "" {
+				ts.login(t, tt.email)
+			}
+			code, _, body := ts.get(t, "/snippet/admin")
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestShowRevision(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody []byte
+	}{
+		{"Valid revision", "/snippet/1/revision/1", http.StatusOK, []byte("An old silent pond...")},
+		{"Non-existent revision", "/snippet/1/revision/2", http.StatusNotFound, nil},
+		{"Non-existent snippet", "/snippet/2/revision/1", http.StatusNotFound, nil},
+		{"Private snippet", "/snippet/3/history", http.StatusNotFound, nil},
+		{"String revision", "/snippet/1/revision/foo", http.StatusNotFound, nil},
+		{"History", "/snippet/1/history", http.StatusOK, []byte("/snippet/1/revision/1")},
+		{"Diff", "/snippet/1/diff?from=1&to=1", http.StatusOK, []byte("These revisions are identical.")},
+		{"Raw diff", "/snippet/1/diff/raw?from=1&to=1", http.StatusOK, []byte("--- a/an-old-silent-pond.txt (revision 1)")},
+		{"Non-existent diff revision", "/snippet/1/diff?from=1&to=3", http.StatusNotFound, nil},
+		{"Invalid diff revision", "/snippet/1/diff?from=foo", http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestEditSnippet(t *testing.T) {
+	tests := []struct {
+		name         string
+		email        string
+		urlPath      string
+		wantCode     int
+		wantLocation string
+		wantRevised  int
+	}{
+		{"Anonymous", "", "/snippet/1/edit", http.StatusSeeOther, "/user/login", 0},
+		{"Owner", "alice@example.com", "/snippet/1/edit", http.StatusSeeOther, "/s/pondpondpo", 1},
+		{"Not the owner", "admin@example.com", "/snippet/1/edit", http.StatusForbidden, "", 0},
+		{"Someone else`s private snippet", "alice@example.com", "/snippet/3/edit", http.StatusNotFound, "", 0},
+		{"Missing snippet", "alice@example.com", "/snippet/99/edit", http.StatusNotFound, "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			snippets := app.snippets.(*mock.SnippetModel)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			var csrfToken string
+			if tt.email != "" {
+				csrfToken = ts.login(t, tt.email)
+			} else {
+				_, _, body := ts.get(t, "/user/login")
+				csrfToken = extractSCRFToken(t, body)
+			}
+
+			form := url.Values{}
+			form.Add("csrf_token", csrfToken)
+			form.Add("title", "An old silent pond")
+			form.Add("content", "A frog jumps into the pond, splash! Silence again.")
+			code, header, _ := ts.postForm(t, tt.urlPath, form)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if loc := header.Get("Location"); loc != tt.wantLocation {
+				t.Errorf("want Location %q; got %q", tt.wantLocation, loc)
+			}
+			if len(snippets.Revised) != tt.wantRevised {
+				t.Fatalf("want %d revisions saved; got %d", tt.wantRevised, len(snippets.Revised))
+			}
+			if tt.wantRevised == 0 {
+				return
+			}
+
+			// The save is listed in the history as the next revision, after the original one.
+			_, _, body := ts.get(t, "/snippet/1/history")
+			if !bytes.Contains(body, []byte("/snippet/1/revision/2")) {
+				t.Errorf("want the history to link to revision 2")
+			}
+		})
+	}
+}
+
+func TestRevisionFiles(t *testing.T) {
+	app := newTestApplication(t)
+	snippets := app.snippets.(*mock.SnippetModel)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	form := url.Values{}
+	form.Add("csrf_token", ts.login(t, "alice@example.com"))
+	form.Add("title", "An old silent pond")
+	form.Add("filename", "pond.txt")
+	form.Add("content", "An old silent pond...")
+	form.Add("file_name", "frog.sh")
+	form.Add("file_language", "")
+	form.Add("file_content", "echo splash")
+	code, _, _ := ts.postForm(t, "/snippet/1/edit", form)
+	if code != http.StatusSeeOther {
+		t.Fatalf("want %d; got %d", http.StatusSeeOther, code)
+	}
+
+	if len(snippets.Revised) != 1 {
+		t.Fatalf("want 1 revision saved; got %d", len(snippets.Revised))
+	}
+	if rev := snippets.Revised[0]; rev.Filename != "pond.txt" || len(rev.Files) != 1 || rev.Files[0].Name != "frog.sh" {
+		t.Errorf("want the revision to keep every file")
+	}
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantBody []byte
+	}{
+		{"Revision", "/snippet/1/revision/2", []byte("echo splash")},
+		{"Renamed file", "/snippet/1/diff?from=1&to=2", []byte("an-old-silent-pond.txt &rarr; pond.txt")},
+		{"Added file", "/snippet/1/diff?from=1&to=2", []byte("frog.sh (added)")},
+		{"Raw diff", "/snippet/1/diff/raw?from=1&to=2", []byte("--- /dev/null\n+++ b/frog.sh (revision 2)\n")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			if code != http.StatusOK {
+				t.Errorf("want %d; got %d", http.StatusOK, code)
+			}
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestListingsHideSnippets(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// Only public snippets are listed. Listings show the content of snippets, so they must also leave
+	// out view-limited snippets, which would otherwise be readable without using up a view.
+	tests := []struct {
+		name    string
+		urlPath string
+		hidden  []string
+	}{
+		{"Home", "/", []string{"Database password", "Draft haiku", "Internal stack trace"}},
+		{"Tag", "/tag/poetry", []string{"Database password", "Draft haiku", "Internal stack trace"}},
+		{"Search unlisted", "/search?q=frog", []string{"Draft haiku"}},
+		{"Search private", "/search?q=panic", []string{"Internal stack trace"}},
+		{"Search view-limited", "/search?q=horse", []string{"Database password", "correct horse"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			if code != http.StatusOK {
+				t.Errorf("want %d; got %d", http.StatusOK, code)
+			}
+			for _, hidden := range tt.hidden {
+				if bytes.Contains(body, []byte(hidden)) {
+					t.Errorf("want body not to contain %q", hidden)
+				}
+			}
+		})
+	}
+}
+
+func TestShowSnippetVisibility(t *testing.T) {
+	tests := []struct {
+		name     string
+		email    string
+		urlPath  string
+		wantCode int
+	}{
+		{"Unlisted anonymously", "", "/s/hiddenhidd", http.StatusOK},
+		{"Unlisted by ID", "", "/snippet/5", http.StatusNotFound},
+		{"Unlisted history", "", "/snippet/5/history", http.StatusNotFound},
+		{"Private anonymously", "", "/s/tracetrace", http.StatusNotFound},
+		{"Private by ID", "", "/snippet/3", http.StatusNotFound},
+		{"Private history", "", "/snippet/3/history", http.StatusNotFound},
+		{"Someone else`s private snippet", "alice@example.com", "/s/tracetrace", http.StatusNotFound},
+		{"Private as administrator", "admin@example.com", "/s/tracetrace", http.StatusOK},
+		{"Private by ID as administrator", "admin@example.com", "/snippet/3", http.StatusSeeOther},
+		{"Private history as administrator", "admin@example.com", "/snippet/3/history", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			if tt.email != "" {
+				ts.login(t, tt.email)
+			}
+			code, _, _ := ts.get(t, tt.urlPath)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+		})
+	}
+}
+
+func TestUnlistedSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// Anybody with the link can read an unlisted snippet...
+	code, _, body := ts.get(t, "/s/hiddenhidd")
+	if code != http.StatusOK {
+		t.Errorf("want %d; got %d", http.StatusOK, code)
+	}
+	if !bytes.Contains(body, []byte("A hidden frog leaps...")) {
+		t.Errorf("want body to contain the snippet content")
+	}
+
+	// ...but its history is served by its sequential ID, which only its owner and administrators
+	// can use, so it isn`t linked.
+	if bytes.Contains(body, []byte("/snippet/5/history")) {
+		t.Errorf("want body not to link to the snippet history")
+	}
+}
+
+func TestCreateSnippetVisibility(t *testing.T) {
+	tests := []struct {
+		name       string
+		visibility string
+		wantCode   int
+	}{
+		{"Public", models.VisibilityPublic, http.StatusSeeOther},
+		{"Unlisted", models.VisibilityUnlisted, http.StatusSeeOther},
+		{"Private", models.VisibilityPrivate, http.StatusSeeOther},
+		{"Unknown", "secret", http.StatusOK},
+		{"Missing", "", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			snippets := app.snippets.(*mock.SnippetModel)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			form := url.Values{}
+			form.Add("csrf_token", ts.login(t, "alice@example.com"))
+			form.Add("title", "O snail")
+			form.Add("content", "Climb Mount Fuji")
+			form.Add("expires", "7")
+			form.Add("visibility", tt.visibility)
+			code, header, _ := ts.postForm(t, "/snippet/create", form)
+
+			if code != tt.wantCode {
+				t.Fatalf("want %d; got %d", tt.wantCode, code)
+			}
+			if tt.wantCode != http.StatusSeeOther {
+				if snippets.Inserted != nil {
+					t.Error("want no snippet saved")
+				}
+				return
+			}
+			if loc := header.Get("Location"); loc != "/s/newsnippet" {
+				t.Errorf("want Location %q; got %q", "/s/newsnippet", loc)
+			}
+			if snippets.Inserted == nil || snippets.Inserted.Visibility != tt.visibility {
+				t.Errorf("want a %s snippet saved; got %+v", tt.visibility, snippets.Inserted)
+			}
+		})
+	}
+}
+
+func TestViewLimitedSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// Following the link must not reveal the content, only the confirmation page.
+	code, _, body := ts.get(t, "/s/burnburnbu")
+	if code != http.StatusOK {
+		t.Errorf("want %d; got %d", http.StatusOK, code)
+	}
+	if bytes.Contains(body, []byte("correct horse battery staple")) {
+		t.Errorf("want confirmation page without the snippet content")
+	}
+
+	// Snippets with a view limit can`t be read through their sequential ID.
+	code, header, _ := ts.get(t, "/snippet/4")
+	if code != http.StatusSeeOther || header.Get("Location") != "/s/burnburnbu" {
+		t.Errorf("want redirect to /s/burnburnbu; got %d %q", code, header.Get("Location"))
+	}
+
+	// Confirming reveals the snippet.
+	form := url.Values{}
+	form.Add("csrf_token", extractSCRFToken(t, body))
+	code, _, body = ts.postForm(t, "/s/burnburnbu", form)
+	if code != http.StatusOK {
+		t.Errorf("want %d; got %d", http.StatusOK, code)
+	}
+	if !bytes.Contains(body, []byte("correct horse battery staple")) {
+		t.Errorf("want body to contain the snippet content")
+	}
+	if !bytes.Contains(body, []byte("will not be shown again")) {
+		t.Errorf("want body to say the snippet has been burned")
+	}
+
+	// Its history can only be read by its owner and administrators, so it isn`t linked.
+	if bytes.Contains(body, []byte("/snippet/4/history")) {
+		t.Errorf("want body not to link to the snippet history")
+	}
+}
+
+func TestChangeExpiry(t *testing.T) {
+	tests := []struct {
+		name       string
+		email      string
+		urlPath    string
+		form       url.Values
+		wantCode   int
+		wantExpiry time.Duration
+	}{
+		{"Anonymous", "", "/snippet/1/expiry", url.Values{"expires": {"7"}}, http.StatusSeeOther, 0},
+		{"Not the owner", "admin@example.com", "/snippet/1/expiry", url.Values{"expires": {"7"}}, http.StatusForbidden, 0},
+		{"Missing snippet", "alice@example.com", "/snippet/99/expiry", url.Values{"expires": {"7"}}, http.StatusNotFound, 0},
+		{"Extend", "alice@example.com", "/snippet/1/expiry", url.Values{"expires": {"365"}}, http.StatusSeeOther, 365 * day},
+		{"Shorten", "alice@example.com", "/snippet/1/expiry", url.Values{"expires": {"custom"}, "expires_amount": {"90"}, "expires_unit": {"minutes"}}, http.StatusSeeOther, 90 * time.Minute},
+		{"Extend past the limit", "alice@example.com", "/snippet/1/expiry", url.Values{"expires": {"custom"}, "expires_amount": {"400"}, "expires_unit": {"days"}}, http.StatusOK, 0},
+		{"Never past the limit", "alice@example.com", "/snippet/1/expiry", url.Values{"expires": {"never"}}, http.StatusOK, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			snippets := app.snippets.(*mock.SnippetModel)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			if tt.email != "" {
+				tt.form.Set("csrf_token", ts.login(t, tt.email))
+			} else {
+				_, _, body := ts.get(t, "/user/login")
+				tt.form.Set("csrf_token", extractSCRFToken(t, body))
+			}
+			code, _, _ := ts.postForm(t, tt.urlPath, tt.form)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if tt.wantExpiry == 0 {
+				if snippets.NewExpiry != nil {
+					t.Errorf("want the expiry unchanged; got %v", *snippets.NewExpiry)
+				}
+				return
+			}
+			if snippets.NewExpiry == nil {
+				t.Fatal("want the expiry changed")
+			}
+			if got := time.Until(*snippets.NewExpiry); got > tt.wantExpiry || got < tt.wantExpiry-time.Minute {
+				t.Errorf("want the snippet to expire in %v; got %v", tt.wantExpiry, got)
+			}
+		})
+	}
+}
+
+func TestSetExpiryLimits(t *testing.T) {
+	tests := []struct {
+		name         string
+		email        string
+		user         string
+		wantCode     int
+		wantLocation string
+		wantUpdated  bool
+	}{
+		{"Anonymous", "", "30", http.StatusForbidden, "", false},
+		{"Not an administrator", "alice@example.com", "30", http.StatusForbidden, "", false},
+		{"Administrator", "admin@example.com", "30", http.StatusSeeOther, "/snippet/admin", true},
+		{"Out of range", "admin@example.com", "-1", http.StatusSeeOther, "/snippet/admin", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			limits := app.expiryLimits.(*mock.ExpiryLimitModel)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			var csrfToken string
+			if tt.email != "" {
+				csrfToken = ts.login(t, tt.email)
+			} else {
+				_, _, body := ts.get(t, "/user/login")
+				csrfToken = extractSCRFToken(t, body)
+			}
+
+			form := url.Values{}
+			form.Add("csrf_token", csrfToken)
+			form.Add(models.RoleUser, tt.user)
+			form.Add(models.RoleAdministrator, "0")
+			code, header, _ := ts.postForm(t, "/snippet/admin/limits", form)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if loc := header.Get("Location"); loc != tt.wantLocation {
+				t.Errorf("want Location %q; got %q", tt.wantLocation, loc)
+			}
+			if !tt.wantUpdated {
+				if limits.Updated != nil {
+					t.Errorf("want no limits saved; got %v", limits.Updated)
+				}
+				return
+			}
+			if got := limits.Updated[models.RoleUser]; got != 30*day {
+				t.Errorf("want the user limit set to %v; got %v", 30*day, got)
+			}
+		})
+	}
+}
+
+func TestCreateToken(t *testing.T) {
+	tests := []struct {
+		name      string
+		email     string
+		scopes    []string
+		wantToken bool
+	}{
+		{"Read and write", "alice@example.com", []string{models.ScopeSnippetsRead, models.ScopeSnippetsWrite}, true},
+		{"No scopes", "alice@example.com", nil, false},
+		{"Unknown scope", "alice@example.com", []string{"snippets:delete"}, false},
+		{"Admin scope for a user", "alice@example.com", []string{models.ScopeSnippetsRead, models.ScopeAdmin}, false},
+		{"Admin scope for an administrator", "admin@example.com", []string{models.ScopeAdmin}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			form := url.Values{}
+			form.Add("csrf_token", ts.login(t, tt.email))
+			form.Add("name", "laptop")
+			form.Add("expires", "30")
+			for _, scope := range tt.scopes {
+				form.Add("scopes", scope)
+			}
+			code, _, body := ts.postForm(t, "/user/tokens", form)
+
+			if code != http.StatusOK {
+				t.Errorf("want %d; got %d", http.StatusOK, code)
+			}
+			if got := bytes.Contains(body, []byte("sb_newtoken")); got != tt.wantToken {
+				t.Errorf("want token shown %v; got %v", tt.wantToken, got)
+			}
+		})
+	}
+}
+
+func TestShowChatPage(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody []byte
+	}{
+		{"Latest", "/snippet/chat", http.StatusOK, []byte("Has anybody seen my frog?")},
+		{"Author", "/snippet/chat", http.StatusOK, []byte(`<span class="author">Alice</span>`)},
+		{"Anonymous", "/snippet/chat", http.StatusOK, []byte("to join the chat")},
+		{"Older", "/snippet/chat?before=1", http.StatusOK, []byte("Nobody has said anything yet.")},
+		{"Bad cursor", "/snippet/chat?before=frog", http.StatusNotFound, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestPostMessage(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/user/signup")
+	csrfToken := extractSCRFToken(t, body)
+
+	// Only signed in users can post, so an anonymous visitor is sent to the login page.
+	form := url.Values{}
+	form.Add("csrf_token", csrfToken)
+	form.Add("content", "Hello")
+	code, header, _ := ts.postForm(t, "/snippet/chat", form)
+
+	if code != http.StatusSeeOther {
+		t.Errorf("want %d; got %d", http.StatusSeeOther, code)
+	}
+	if loc := header.Get("Location"); loc != "/user/login" {
+		t.Errorf("want Location %q; got %q", "/user/login", loc)
+	}
+}
+
+func TestShowChatRoom(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		login    bool
+		urlPath  string
+		wantCode int
+		wantBody []byte
+	}{
+		{"Public room", false, "/snippet/chat/2", http.StatusOK, []byte("<h2>#lounge</h2>")},
+		{"Private room", false, "/snippet/chat/3", http.StatusNotFound, nil},
+		{"Direct room", false, "/snippet/chat/5", http.StatusNotFound, nil},
+		{"Missing room", false, "/snippet/chat/99", http.StatusNotFound, nil},
+		{"Bad ID", false, "/snippet/chat/frog", http.StatusNotFound, nil},
+		{"Not a member", true, "/snippet/chat/2", http.StatusOK, []byte(`action="/snippet/chat/2/join"`)},
+		{"Member", true, "/snippet/chat/3", http.StatusOK, []byte(`action="/snippet/chat/3/invite"`)},
+		{"Unread", true, "/snippet/chat", http.StatusOK, []byte(`<span class="unread">2</span>`)},
+		{"Switcher", true, "/snippet/chat", http.StatusOK, []byte(`<a href="/snippet/chat/5">@Bob</a>`)},
+		{"Someone else`s room", true, "/snippet/chat/4", http.StatusNotFound, nil},
+		{"Own direct room", true, "/snippet/chat/5", http.StatusOK, []byte("<h2>@Bob</h2>")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.login {
+				ts.login(t, "alice@example.com")
+			}
+			code, _, body := ts.get(t, tt.urlPath)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestChatRoomForms(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+	csrfToken := ts.login(t, "alice@example.com")
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		fields       url.Values
+		wantCode     int
+		wantLocation string
+		wantBody     []byte
+	}{
+		{"Create room", "/snippet/chat/rooms", url.Values{"name": {"frogs"}, "kind": {"private"}}, http.StatusSeeOther, "/snippet/chat/6", nil},
+		{"Unnamed room", "/snippet/chat/rooms", url.Values{"name": {" "}, "kind": {"public"}}, http.StatusOK, "", []byte("This field cannot be blank")},
+		{"Direct room kind", "/snippet/chat/rooms", url.Values{"name": {"frogs"}, "kind": {"direct"}}, http.StatusOK, "", []byte("This field is invalid")},
+		{"Direct message", "/snippet/chat/direct", url.Values{"email": {"bob@example.com"}}, http.StatusSeeOther, "/snippet/chat/5", nil},
+		{"Message to nobody", "/snippet/chat/direct", url.Values{"email": {"carol@example.com"}}, http.StatusOK, "", []byte("nobody with that email address")},
+		{"Message to yourself", "/snippet/chat/direct", url.Values{"email": {"alice@example.com"}}, http.StatusOK, "", []byte("to yourself")},
+		{"Join public room", "/snippet/chat/2/join", nil, http.StatusSeeOther, "/snippet/chat/2", nil},
+		{"Join private room", "/snippet/chat/4/join", nil, http.StatusNotFound, "", nil},
+		{"Invite", "/snippet/chat/3/invite", url.Values{"invitee": {"bob@example.com"}}, http.StatusSeeOther, "/snippet/chat/3", nil},
+		{"Invite nobody", "/snippet/chat/3/invite", url.Values{"invitee": {"carol@example.com"}}, http.StatusOK, "", []byte("nobody with that email address")},
+		{"Invite to public room", "/snippet/chat/1/invite", url.Values{"invitee": {"bob@example.com"}}, http.StatusNotFound, "", nil},
+		{"Invite to someone else`s room", "/snippet/chat/4/invite", url.Values{"invitee": {"bob@example.com"}}, http.StatusNotFound, "", nil},
+		{"Post to room", "/snippet/chat", url.Values{"room": {"3"}, "content": {"Hello"}}, http.StatusSeeOther, "/snippet/chat/3", nil},
+		{"Post to someone else`s room", "/snippet/chat", url.Values{"room": {"4"}, "content": {"Hello"}}, http.StatusNotFound, "", nil},
+		{"Post to a bad room", "/snippet/chat", url.Values{"room": {"frog"}, "content": {"Hello"}}, http.StatusBadRequest, "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			for name, values := range tt.fields {
+				form[name] = values
+			}
+			form.Add("csrf_token", csrfToken)
+			code, header, body := ts.postForm(t, tt.urlPath, form)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if loc := header.Get("Location"); loc != tt.wantLocation {
+				t.Errorf("want Location %q; got %q", tt.wantLocation, loc)
+			}
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
@@ -2,10 +2,15 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"github.com/justinas/nosurf"
+	"io"
 	"net/http"
 	"runtime/debug"
+	"sabiraliyev.net/snippetbox/pkg/models"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -34,6 +39,17 @@ func (app *application) notFound(w http.ResponseWriter) {
 	app.clientError(w, http.StatusNotFound)
 }
 
+// The unauthorized helper sends a 401 Unauthorized response to a client which needs to present an
+// API token, telling it how to do so. Requests for the JSON API get a JSON error.
+func (app *application) unauthorized(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="snippetbox"`)
+	if isAPI(r) {
+		app.apiError(w, http.StatusUnauthorized, "a valid API token is required")
+		return
+	}
+	app.clientError(w, http.StatusUnauthorized)
+}
+
 // The addDefaultData helper takes a pointer to a TemplateData struct, add the current year
 // to the CurrentYear field, and then returns the pointer. Again, we`re not using the *http.Request
 // parameter at the moment.
@@ -51,6 +67,9 @@ func (app *application) addDefaultData(td *templateData, r *http.Request) *templ
 	td.Flash = app.session.PopString(r, "flash")
 	td.IsAuthenticated = app.isAuthenticated(r)
 	td.IsAdministrator = app.isAdministrator(r)
+	if td.IsAuthenticated {
+		td.AuthenticatedUserID = app.session.GetInt(r, "authenticatedUserID")
+	}
 	return td
 }
 
@@ -80,6 +99,33 @@ func (app *application) render(w http.ResponseWriter, r *http.Request, name stri
 	buf.WriteTo(w)
 }
 
+// The postedID helper parses the "id" field of a submitted form. If the form can`t be parsed
+// or the ID isn`t a positive integer, it sends a 400 Bad Request response and returns false.
+func (app *application) postedID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return 0, false
+	}
+
+	id, err := strconv.Atoi(r.PostForm.Get("id"))
+	if err != nil || id < 1 {
+		app.clientError(w, http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// The localPath function returns path if it is a path on this site, so that it is safe to redirect
+// to, and fallback otherwise. Paths starting with "//" or "/\" are left out, as browsers treat them
+// as links to other sites.
+func localPath(path, fallback string) string {
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") || strings.HasPrefix(path, "/\\") {
+		return fallback
+	}
+	return path
+}
+
 func (app *application) isAuthenticated(r *http.Request) bool {
 	isAuthenticated, ok := r.Context().Value(contextKeyIsAuthenticated).(bool)
 	if !ok {
@@ -97,3 +143,137 @@ func (app *application) isAdministrator(r *http.Request) bool {
 
 	return isAdministrator
 }
+
+// The authenticatedUserID helper returns the ID of the current user, whether they signed in with the
+// session cookie or an API token, or 0 if nobody is signed in. Routes authenticated by token don`t
+// load the session, so it mustn`t be touched for them.
+func (app *application) authenticatedUserID(r *http.Request) int {
+	if user, ok := r.Context().Value(contextKeyTokenUser).(*models.User); ok {
+		return user.ID
+	}
+	if !app.isAuthenticated(r) {
+		return 0
+	}
+	return app.session.GetInt(r, "authenticatedUserID")
+}
+
+// The tokenScopes helper returns the scopes of the API token the request was authenticated with.
+// The ok result is false if the request wasn`t authenticated with a token.
+func tokenScopes(r *http.Request) (scopes []string, ok bool) {
+	scopes, ok = r.Context().Value(contextKeyTokenScopes).([]string)
+	return scopes, ok
+}
+
+// The hasScope function reports whether a list of scopes includes the given one.
+func hasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// The isOwner helper reports whether the current user created the snippet.
+func (app *application) isOwner(r *http.Request, s *models.Snippet) bool {
+	return app.isAuthenticated(r) && s.UserID == app.authenticatedUserID(r)
+}
+
+// The canModify helper reports whether the current user may delete or otherwise change the snippet:
+// only its owner and administrators can.
+func (app *application) canModify(r *http.Request, s *models.Snippet) bool {
+	return app.isAdministrator(r) || app.isOwner(r, s)
+}
+
+// The canView helper reports whether the current user may see the snippet. Public and unlisted
+// snippets are visible to everybody; private ones only to their owner and administrators.
+func (app *application) canView(r *http.Request, s *models.Snippet) bool {
+	if s.Visibility == models.VisibilityPrivate {
+		return app.canModify(r, s)
+	}
+	return true
+}
+
+// The snippetFromURL helper fetches the snippet identified by the ":id" URL parameter. If the ID is
+// invalid or no live snippet matches it, it sends the appropriate error response and returns false.
+// Because IDs can be enumerated, only public snippets without a view limit are served this way to
+// users who can`t modify them.
+func (app *application) snippetFromURL(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	id, err := strconv.Atoi(r.URL.Query().Get(":id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return nil, false
+	}
+
+	s, err := app.snippets.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return nil, false
+	}
+
+	if (s.Visibility != models.VisibilityPublic || s.MaxViews > 0) && !app.canModify(r, s) {
+		app.notFound(w)
+		return nil, false
+	}
+	return s, true
+}
+
+// The snippetFromSlug helper fetches the snippet identified by the ":slug" URL parameter for the
+// raw, download, file and zip routes, which serve anyone who could see the snippet on its page.
+// Snippets with a view limit aren`t served this way without using up a view, so readers who can`t
+// modify them are sent to the snippet`s page instead.
+func (app *application) snippetFromSlug(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	s, err := app.snippets.GetBySlug(r.URL.Query().Get(":slug"))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return nil, false
+	}
+
+	if !app.canView(r, s) {
+		app.notFound(w)
+		return nil, false
+	}
+	if s.MaxViews > 0 && !app.canModify(r, s) {
+		http.Redirect(w, r, "/s/"+s.Slug, http.StatusSeeOther)
+		return nil, false
+	}
+	return s, true
+}
+
+// The renderSnippet helper loads the files of the snippet in td and shows it with the show page.
+func (app *application) renderSnippet(w http.ResponseWriter, r *http.Request, td *templateData) {
+	files, err := app.snippets.Files(td.Snippet.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	td.Files = allFiles(td.Snippet, files)
+	app.render(w, r, "show.page.tmpl", td)
+}
+
+// The serveRaw helper sends snippet content as plain text. Browsers are told not to sniff it as
+// something else, and the content security policy stops it from doing anything if they do.
+func serveRaw(w http.ResponseWriter, content string) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; sandbox")
+	io.WriteString(w, content)
+}
+
+// The firstNonEmpty helper returns the first of its arguments which isn`t empty.
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
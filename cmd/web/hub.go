@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"sabiraliyev.net/snippetbox/pkg/websocket"
+)
+
+const (
+	// The number of messages queued for a client before it is treated as too slow and evicted.
+	clientBuffer = 32
+	// How often clients are pinged, and how long they have to answer before they are given up on.
+	pingPeriod = 30 * time.Second
+	pongWait   = 60 * time.Second
+	// How long a client has to answer a close frame before its connection is cut.
+	closeWait = 5 * time.Second
+)
+
+// A hub keeps track of the clients connected to the chat over WebSockets and fans messages out to
+// them. Each client has a bounded queue of outgoing messages, written by its own goroutine, so one
+// slow connection can`t hold up the others: a client whose queue is full is evicted instead.
+type hub struct {
+	mu      sync.Mutex
+	clients map[*client]bool
+	closed  bool
+	wg      sync.WaitGroup
+}
+
+// A client is one WebSocket connection to the chat. The stop channel is closed, with the status code
+// to close the connection with in code, when the hub wants the client gone.
+type client struct {
+	conn   *websocket.Conn
+	userID int
+	send   chan []byte
+	stop   chan struct{}
+	code   int
+	reason string
+}
+
+func newHub() *hub {
+	return &hub{clients: map[*client]bool{}}
+}
+
+func newClient(conn *websocket.Conn, userID int) *client {
+	return &client{
+		conn:   conn,
+		userID: userID,
+		send:   make(chan []byte, clientBuffer),
+		stop:   make(chan struct{}),
+	}
+}
+
+// The register method adds a client to the hub. It returns false if the hub has been shut down.
+func (h *hub) register(c *client) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return false
+	}
+	h.clients[c] = true
+	h.wg.Add(1)
+	return true
+}
+
+// The unregister method removes a client from the hub, once its connection is finished with.
+func (h *hub) unregister(c *client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.clients[c] {
+		delete(h.clients, c)
+		h.wg.Done()
+	}
+}
+
+// The broadcast method queues a message for every client. Clients whose queues are full are evicted.
+func (h *hub) broadcast(msg []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for c := range h.clients {
+		select {
+		case c.send <- msg:
+		default:
+			h.evict(c, websocket.ClosePolicyViolation, "too slow")
+		}
+	}
+}
+
+// The broadcastTo method queues a message for the clients of the users with the given IDs, for
+// messages in rooms which aren`t public. A user can be connected from more than one tab.
+func (h *hub) broadcastTo(msg []byte, userIDs []int) {
+	to := map[int]bool{}
+	for _, id := range userIDs {
+		to[id] = true
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for c := range h.clients {
+		if !to[c.userID] {
+			continue
+		}
+		select {
+		case c.send <- msg:
+		default:
+			h.evict(c, websocket.ClosePolicyViolation, "too slow")
+		}
+	}
+}
+
+// The sendTo method queues a message for a single client, evicting it if its queue is full.
+func (h *hub) sendTo(c *client, msg []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.clients[c] {
+		return
+	}
+	select {
+	case c.send <- msg:
+	default:
+		h.evict(c, websocket.ClosePolicyViolation, "too slow")
+	}
+}
+
+// The leave method tells a client to close its connection normally, once it is done reading.
+func (h *hub) leave(c *client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.evict(c, websocket.CloseNormalClosure, "")
+}
+
+// The evict method tells a client to close its connection with the given status code. The client is
+// left registered until its goroutines have finished. It must be called with h.mu held.
+func (h *hub) evict(c *client, code int, reason string) {
+	select {
+	case <-c.stop:
+		// Already on its way out.
+	default:
+		c.code, c.reason = code, reason
+		close(c.stop)
+	}
+}
+
+// The shutdown method closes every connection with a "going away" status and waits for the clients
+// to finish, or for the context to be done. No new clients are accepted afterwards.
+func (h *hub) shutdown(ctx context.Context) error {
+	h.mu.Lock()
+	h.closed = true
+	for c := range h.clients {
+		h.evict(c, websocket.CloseGoingAway, "server shutting down")
+	}
+	h.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		h.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// The writePump method writes a client`s queued messages to its connection and pings it to check it
+// is still there. When the hub evicts the client, it starts the closing handshake, then gives the
+// client closeWait to answer before the reading side gives up.
+func (c *client) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case msg := <-c.send:
+			if err := c.conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+				c.conn.Close()
+				return
+			}
+		case <-ticker.C:
+			if err := c.conn.WritePing(nil); err != nil {
+				c.conn.Close()
+				return
+			}
+		case <-c.stop:
+			c.conn.WriteClose(c.code, c.reason)
+			c.conn.SetReadDeadline(time.Now().Add(closeWait))
+			return
+		}
+	}
+}
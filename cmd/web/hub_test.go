@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"sabiraliyev.net/snippetbox/pkg/websocket"
+)
+
+func TestHubEvictsSlowClients(t *testing.T) {
+	h := newHub()
+	fast, slow := newClient(nil, 1), newClient(nil, 2)
+	h.register(fast)
+	h.register(slow)
+
+	// The fast client keeps up with every message; the slow one reads none of them.
+	for i := 0; i <= clientBuffer; i++ {
+		h.broadcast([]byte("hello"))
+		<-fast.send
+	}
+
+	select {
+	case <-slow.stop:
+		if slow.code != websocket.ClosePolicyViolation {
+			t.Errorf("want close code %d; got %d", websocket.ClosePolicyViolation, slow.code)
+		}
+	default:
+		t.Error("want the slow client evicted")
+	}
+	select {
+	case <-fast.stop:
+		t.Error("want the fast client left alone")
+	default:
+	}
+}
+
+func TestHubShutdown(t *testing.T) {
+	h := newHub()
+	c := newClient(nil, 1)
+	h.register(c)
+
+	// Stand in for the client`s goroutines, which finish once the client is told to stop.
+	go func() {
+		<-c.stop
+		h.unregister(c)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err := h.shutdown(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.code != websocket.CloseGoingAway {
+		t.Errorf("want close code %d; got %d", websocket.CloseGoingAway, c.code)
+	}
+	if h.register(newClient(nil, 2)) {
+		t.Error("want no new clients after shutdown")
+	}
+}
+
+func TestChatSocketNeedsLogin(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, header, _ := ts.get(t, "/chat/ws")
+	if code != http.StatusSeeOther {
+		t.Errorf("want %d; got %d", http.StatusSeeOther, code)
+	}
+	if loc := header.Get("Location"); loc != "/user/login" {
+		t.Errorf("want Location %q; got %q", "/user/login", loc)
+	}
+}
+
+func TestHubBroadcastTo(t *testing.T) {
+	h := newHub()
+	alice, aliceAgain, bob := newClient(nil, 1), newClient(nil, 1), newClient(nil, 2)
+	h.register(alice)
+	h.register(aliceAgain)
+	h.register(bob)
+
+	h.broadcastTo([]byte("psst"), []int{1})
+	if len(alice.send) != 1 || len(aliceAgain.send) != 1 {
+		t.Error("want the message sent to every connection of a member")
+	}
+	if len(bob.send) != 0 {
+		t.Error("want no message for someone who isn`t a member")
+	}
+}
+
+func TestChatMarkRead(t *testing.T) {
+	tests := []struct {
+		name      string
+		message   string
+		wantError bool
+	}{
+		{"Message in the room", `{"type": "read", "room": 1, "message": 1}`, false},
+		{"Message in another room", `{"type": "read", "room": 3, "message": 1}`, true},
+		{"Non-existent message", `{"type": "read", "room": 1, "message": 99}`, true},
+		{"Room the user isn`t in", `{"type": "read", "room": 2, "message": 1}`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			c := newClient(nil, 1)
+			app.chat.register(c)
+
+			app.receiveMessage(c, []byte(tt.message))
+			if got := len(c.send) == 1; got != tt.wantError {
+				t.Errorf("want error %v; got %v", tt.wantError, got)
+			}
+		})
+	}
+}
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"database/sql"
+	"errors"
 	"flag"
 	"fmt"
 	"github.com/golangcollege/sessions"
@@ -10,7 +12,9 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"sabiraliyev.net/snippetbox/pkg/models"
+	"syscall"
 	"time"
 
 	"sabiraliyev.net/snippetbox/pkg/models/mysql"
@@ -30,6 +34,11 @@ const (
 const contextKeyIsAuthenticated = contextKey("isAuthenticated")
 const contextKeyIsAdministrator = contextKey("isAdministrator")
 
+// The user who authenticated with an API token rather than the session cookie, and the scopes
+// their token was granted.
+const contextKeyTokenUser = contextKey("tokenUser")
+const contextKeyTokenScopes = contextKey("tokenScopes")
+
 // Define an application struct to hold the application wide dependencies for the web application.
 // For now we`ll only include fields for the two custom loggers, but we`ll add more to it as build process.
 type application struct {
@@ -37,20 +46,64 @@ type application struct {
 	infoLog  *log.Logger
 	session  *sessions.Session
 	snippets interface {
-		Insert(string, string, string) (int, error)
+		Insert(*models.Snippet) error
 		Get(int) (*models.Snippet, error)
-		Latest() ([]*models.Snippet, error)
+		Find(int) (*models.Snippet, error)
+		GetBySlug(string) (*models.Snippet, error)
+		View(string) (*models.Snippet, error)
+		List(models.ListOptions) (*models.SnippetPage, error)
+		Search(string, int) (*models.SearchPage, error)
+		ByUser(int) ([]*models.Snippet, error)
+		Delete(int) error
+		Restore(int, int) error
+		Purge(int) error
+		Trash(int) ([]*models.Snippet, error)
+		Deleted() ([]*models.Snippet, error)
+		Expired(time.Time, time.Time) ([]*models.Snippet, error)
+		Update(*models.Snippet, int) error
+		Files(int) ([]*models.File, error)
+		SetExpires(int, time.Time) error
+		Revisions(int) ([]*models.Revision, error)
+		Revision(int, int) (*models.Revision, error)
 	}
 	messages interface {
-		Insert(string, string, string) (int, error)
+		Insert(int, int, string) (int, error)
 		Get(int) (*models.Message, error)
-		Latest() ([]*models.Message, error)
+		Latest(int, int, int) (*models.MessagePage, error)
+	}
+	rooms interface {
+		Insert(int, string, string) (int, error)
+		Direct(int, int) (int, error)
+		Get(int, int) (*models.Room, error)
+		ForUser(int) ([]*models.Room, error)
+		Public(int) ([]*models.Room, error)
+		AddMember(int, int) error
+		Members(int) ([]int, error)
+		MarkRead(int, int, int) error
+	}
+	expiryLimits interface {
+		Get(string) (time.Duration, error)
+		Set(string, time.Duration) error
+		All() (map[string]time.Duration, error)
+	}
+	tags interface {
+		Cloud(int) ([]*models.Tag, error)
 	}
+	tokens interface {
+		Insert(int, string, []string, time.Time) (string, error)
+		Authenticate(string) (*models.Token, error)
+		ByUser(int) ([]*models.Token, error)
+		Delete(int, int) error
+	}
+	reaper        *reaper
+	chat          *hub
+	events        *eventBroker
 	templateCache map[string]*template.Template
 	users         interface {
 		Insert(string, string, string) error
 		Authenticate(string, string) (int, error)
 		Get(int) (*models.User, error)
+		GetByEmail(string) (*models.User, error)
 	}
 }
 
@@ -70,6 +123,12 @@ func main() {
 	// and authenticate session cookies). It should be 32 bytes long.
 	secret := flag.String("secret", "i334343g3+g3gk3@i3g335+35g4589gj", "Secret key")
 
+	// Define command-line flags controlling how often the reaper looks for expired snippets, how long
+	// after expiry they are kept before being purged, and how many are purged by a single statement.
+	reapInterval := flag.Duration("reap-interval", 10*time.Minute, "Interval between purges of expired snippets")
+	reapGrace := flag.Duration("reap-grace", 24*time.Hour, "How long expired snippets are kept before being purged")
+	reapBatch := flag.Int("reap-batch", 500, "Maximum number of snippets purged by a single statement")
+
 	// Importantly, we use the flag.Parse() function to parse the command-line flag.
 	// This readr in the command-line flag value and assigns it to the addr variable.
 	// You need to call it *before* you use the addr variable. Otherwise it will always
@@ -125,15 +184,37 @@ func main() {
 	// session.SameSite = http.SameSiteStrictMode // Default value is SameSite=Lax
 
 	// Initialize an instance of application struct containing the dependencies.
+	snippets := &mysql.SnippetModel{DB: db}
 	app := &application{
-		errorLog:      errorLog,
-		infoLog:       infoLog,
-		session:       session,
-		snippets:      &mysql.SnippetModel{DB: db},
+		errorLog:     errorLog,
+		infoLog:      infoLog,
+		session:      session,
+		snippets:     snippets,
+		expiryLimits: &mysql.ExpiryLimitModel{DB: db},
+		messages:     &mysql.MessageModel{DB: db},
+		rooms:        &mysql.RoomModel{DB: db},
+		tags:         &mysql.TagModel{DB: db},
+		tokens:       &mysql.TokenModel{DB: db},
+		reaper: &reaper{
+			snippets:  snippets,
+			errorLog:  errorLog,
+			infoLog:   infoLog,
+			interval:  *reapInterval,
+			grace:     *reapGrace,
+			batchSize: *reapBatch,
+		},
+		chat:          newHub(),
+		events:        newEventBroker(),
 		templateCache: templateCache,
 		users:         &mysql.UserModel{DB: db},
 	}
 
+	// Start the reaper in the background. It stops when the context is cancelled as main() returns.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go app.reaper.run(ctx)
+	go app.watchExpiry(ctx, expiryCheckPeriod)
+
 	// Initialize a tls.Config struct to hold the non-default LTS settings we want server to use.
 	tlsConfig := &tls.Config{
 		PreferServerCipherSuites: true,
@@ -153,12 +234,41 @@ func main() {
 		WriteTimeout: 10 * time.Second,
 	}
 
+	// Shut the server down gracefully on SIGINT or SIGTERM. The chat`s WebSocket connections have been
+	// hijacked from the http.Server, so srv.Shutdown() doesn`t know about them and the hub closes them
+	// itself, telling the clients the server is going away.
+	shutdownErr := make(chan error)
+	go func() {
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+		s := <-quit
+		infoLog.Printf("Shutting down server (%s)", s)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+		defer cancel()
+		err := app.chat.shutdown(ctx)
+		if err != nil {
+			errorLog.Printf("closing chat connections: %s", err)
+		}
+		// Event streams never finish by themselves, so srv.Shutdown() would wait for them forever.
+		app.events.shutdown()
+		shutdownErr <- srv.Shutdown(ctx)
+	}()
+
 	// Write messages using two loggers.
 	infoLog.Printf("Starting server on %s", *addr)
 	// Use yhe ListenAndServeTLS() method to start the HTTPS server. We pass in the paths
 	// to the TLS certificates and corresponding private key as the two parameters.
 	err = srv.ListenAndServeTLS("./tls/cert.pem", "./tls/key.pem")
-	errorLog.Fatal(err)
+	if !errors.Is(err, http.ErrServerClosed) {
+		errorLog.Fatal(err)
+	}
+
+	err = <-shutdownErr
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+	infoLog.Print("Stopped server")
 }
 
 // The openDB() function wraps sql.Open() and returns a sql.DB connection pull for a given DSN.
@@ -7,6 +7,7 @@ import (
 	"github.com/justinas/nosurf"
 	"net/http"
 	"sabiraliyev.net/snippetbox/pkg/models"
+	"strings"
 )
 
 func secureHeaders(next http.Handler) http.Handler {
@@ -60,6 +61,20 @@ func (app *application) requireAuthentication(next http.Handler) http.Handler {
 	})
 }
 
+// The requireAdministrator middleware only lets administrators through. Everybody else gets a
+// 403 Forbidden response.
+func (app *application) requireAdministrator(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.isAdministrator(r) {
+			app.clientError(w, http.StatusForbidden)
+			return
+		}
+
+		w.Header().Add("Cache-Control", "no-store")
+		next.ServeHTTP(w, r)
+	})
+}
+
 func noSurf(next http.Handler) http.Handler {
 	csrfHandler := nosurf.New(next)
 	csrfHandler.SetBaseCookie(http.Cookie{
@@ -101,6 +116,36 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 	})
 }
 
+// The readSession middleware loads the session for handlers which take over the connection, like the
+// chat`s WebSocket. app.session.Enable can`t be used for them: it buffers the response and writes it
+// out once the handler returns, by which time the connection has been hijacked. Instead the session
+// is loaded by running app.session.Enable over a response which is thrown away, and the handler gets
+// the real ResponseWriter. Changes made to the session aren`t saved.
+func (app *application) readSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var loaded *http.Request
+		app.session.Enable(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+			loaded = r
+		})).ServeHTTP(discardResponse{http.Header{}}, r)
+
+		// The session middleware only skips the handler if the session couldn`t be loaded.
+		if loaded == nil {
+			app.serverError(w, errors.New("the session couldn`t be loaded"))
+			return
+		}
+		next.ServeHTTP(w, loaded)
+	})
+}
+
+// The discardResponse type is a ResponseWriter which throws away everything written to it.
+type discardResponse struct {
+	header http.Header
+}
+
+func (d discardResponse) Header() http.Header         { return d.header }
+func (d discardResponse) Write(b []byte) (int, error) { return len(b), nil }
+func (d discardResponse) WriteHeader(int)             {}
+
 func (app *application) authenticateAsAdmin(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var isAdmin = false
@@ -117,3 +162,92 @@ func (app *application) authenticateAsAdmin(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// The authenticateToken middleware authenticates requests carrying a personal API token in an
+// "Authorization: Bearer" header, for clients like curl which don`t have a session cookie. It works
+// alongside authenticate(): the user and the scopes of their token are put into the request context,
+// and administrators are only treated as such if their token has the admin scope. Requests without
+// the header carry on unauthenticated, but a token which isn`t valid (or has expired) is refused
+// outright, so that a mistake isn`t silently treated as an anonymous request.
+func (app *application) authenticateToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		if header == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		text := strings.TrimPrefix(header, "Bearer ")
+		if text == header {
+			app.unauthorized(w, r)
+			return
+		}
+
+		token, err := app.tokens.Authenticate(text)
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			app.unauthorized(w, r)
+			return
+		} else if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		// Tokens of users who have since been deactivated don`t work either.
+		user, err := app.users.Get(token.UserID)
+		if errors.Is(err, models.ErrNoRecord) || (err == nil && !user.Active) {
+			app.unauthorized(w, r)
+			return
+		} else if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), contextKeyIsAuthenticated, true)
+		ctx = context.WithValue(ctx, contextKeyIsAdministrator, user.Administrator && hasScope(token.Scopes, models.ScopeAdmin))
+		ctx = context.WithValue(ctx, contextKeyTokenUser, user)
+		ctx = context.WithValue(ctx, contextKeyTokenScopes, token.Scopes)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// The requireToken middleware only lets requests through which were authenticated by an API token,
+// whatever its scopes. Other requests get a 401 Unauthorized response.
+func (app *application) requireToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := tokenScopes(r); !ok {
+			app.unauthorized(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// The requireScope middleware only lets requests through which were authenticated by an API token
+// granted the given scope. Requests without a token get a 401 Unauthorized response, and those with
+// a token lacking the scope get a 403 Forbidden response.
+func (app *application) requireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			app.requireToken(app.checkScope(scope)(next)).ServeHTTP(w, r)
+		})
+	}
+}
+
+// The checkScope middleware is like requireScope, but also lets anonymous requests through. It is
+// for routes anybody can use, which a token without the scope still shouldn`t be used for.
+func (app *application) checkScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if scopes, ok := tokenScopes(r); ok && !hasScope(scopes, scope) {
+				message := fmt.Sprintf("this token doesn`t have the %s scope", scope)
+				if isAPI(r) {
+					app.apiError(w, http.StatusForbidden, message)
+				} else {
+					http.Error(w, message, http.StatusForbidden)
+				}
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
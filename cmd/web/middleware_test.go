@@ -58,3 +58,25 @@ func TestSecureHeader(t *testing.T) {
 		t.Errorf("want body to equal %q", "OK")
 	}
 }
+
+func TestReadSession(t *testing.T) {
+	app := newTestApplication(t)
+	rr := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/chat/ws", nil)
+
+	// The handler gets the real ResponseWriter, so nothing is written to it once it returns, and
+	// changes to the session aren`t saved.
+	var got http.ResponseWriter
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = w
+		app.session.Put(r, "flash", "Hello")
+	})
+	app.readSession(next).ServeHTTP(rr, r)
+
+	if got != http.ResponseWriter(rr) {
+		t.Error("want the real ResponseWriter passed on")
+	}
+	if len(rr.Header()) != 0 || rr.Body.Len() != 0 {
+		t.Errorf("want nothing written; got headers %v and body %q", rr.Header(), rr.Body)
+	}
+}
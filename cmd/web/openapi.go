@@ -0,0 +1,596 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"sabiraliyev.net/snippetbox/pkg/models"
+)
+
+// The openAPIDocument type is the subset of an OpenAPI 3 document the application needs to describe
+// itself. The document is built in code, and the schemas are derived from the Go types the handlers
+// actually send and receive, so they can`t drift from the JSON on the wire.
+type openAPIDocument struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       openAPIInfo                      `json:"info"`
+	Servers    []openAPIServer                  `json:"servers"`
+	Paths      map[string]map[string]*operation `json:"paths"`
+	Components openAPIComponents                `json:"components"`
+}
+
+type openAPIInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Version     string `json:"version"`
+}
+
+type openAPIServer struct {
+	URL string `json:"url"`
+}
+
+type openAPIComponents struct {
+	Schemas         map[string]*schema         `json:"schemas"`
+	SecuritySchemes map[string]*securityScheme `json:"securitySchemes"`
+}
+
+type securityScheme struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme,omitempty"`
+	In     string `json:"in,omitempty"`
+	Name   string `json:"name,omitempty"`
+}
+
+// An operation describes what one method does at one path.
+type operation struct {
+	Summary     string                `json:"summary"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags"`
+	Parameters  []*parameter          `json:"parameters,omitempty"`
+	RequestBody *requestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+type parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *schema `json:"schema"`
+}
+
+type requestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*mediaType `json:"content"`
+}
+
+type response struct {
+	Description string                `json:"description"`
+	Content     map[string]*mediaType `json:"content,omitempty"`
+}
+
+type mediaType struct {
+	Schema *schema `json:"schema"`
+}
+
+// A schema is a JSON schema, as used by OpenAPI 3.0.
+type schema struct {
+	Ref        string             `json:"$ref,omitempty"`
+	Type       string             `json:"type,omitempty"`
+	Format     string             `json:"format,omitempty"`
+	Nullable   bool               `json:"nullable,omitempty"`
+	Enum       []string           `json:"enum,omitempty"`
+	Items      *schema            `json:"items,omitempty"`
+	Properties map[string]*schema `json:"properties,omitempty"`
+	Required   []string           `json:"required,omitempty"`
+
+	AdditionalProperties *schema `json:"additionalProperties,omitempty"`
+}
+
+// The openAPISchemas slice lists the Go types which get a named schema in the document. Input types
+// have no required properties, as the validation of the create and edit forms decides what is missing.
+// A Snippet is described by apiSnippet, which embeds models.Snippet and adds the URL of its page.
+var openAPISchemas = []struct {
+	name  string
+	value interface{}
+	input bool
+}{
+	{"Snippet", apiSnippet{}, false},
+	{"File", models.File{}, false},
+	{"User", models.User{}, false},
+	{"SnippetList", apiSnippetList{}, false},
+	{"SnippetInput", apiSnippetInput{}, true},
+	{"Error", apiError{}, false},
+	{"ErrorDetail", apiErrorDetail{}, false},
+}
+
+// The openAPI handler serves the OpenAPI document describing every route of the application.
+func (app *application) openAPI(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, newOpenAPIDocument(absoluteURL(r, "")))
+}
+
+// The newOpenAPIDocument function builds the OpenAPI document for a server at the given URL.
+func newOpenAPIDocument(serverURL string) *openAPIDocument {
+	doc := &openAPIDocument{
+		OpenAPI: "3.0.3",
+		Info: openAPIInfo{
+			Title:       "Snippetbox",
+			Description: "Snippetbox stores and shares snippets of code. The JSON API lives under /api/v1; the other paths are the HTML pages and forms of the site itself.",
+			Version:     "1.0.0",
+		},
+		Servers: []openAPIServer{{URL: serverURL}},
+		Paths:   map[string]map[string]*operation{},
+		Components: openAPIComponents{
+			Schemas: map[string]*schema{},
+			SecuritySchemes: map[string]*securityScheme{
+				"bearerAuth":    {Type: "http", Scheme: "bearer"},
+				"sessionCookie": {Type: "apiKey", In: "cookie", Name: "session"},
+			},
+		},
+	}
+
+	names := map[reflect.Type]string{}
+	for _, s := range openAPISchemas {
+		names[reflect.TypeOf(s.value)] = s.name
+	}
+	for _, s := range openAPISchemas {
+		doc.Components.Schemas[s.name] = structSchema(reflect.TypeOf(s.value), names, !s.input)
+	}
+
+	for _, route := range openAPIRoutes() {
+		path := openAPIPath(route.pattern)
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = map[string]*operation{}
+		}
+		route.op.Parameters = append(pathParameters(route.pattern), route.op.Parameters...)
+		doc.Paths[path][strings.ToLower(route.method)] = route.op
+	}
+	return doc
+}
+
+// The openAPIPath function turns a pat pattern into an OpenAPI path: named parameters like ":id"
+// become "{id}", and patterns ending in a slash, which match everything below them, get a trailing
+// "{path}" parameter.
+func openAPIPath(pattern string) string {
+	segments := strings.Split(pattern, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") {
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	path := strings.Join(segments, "/")
+	if path != "/" && strings.HasSuffix(path, "/") {
+		path += "{path}"
+	}
+	return path
+}
+
+// The pathParameters function describes the parameters in a pat pattern. IDs and version numbers
+// are integers; everything else is a string.
+func pathParameters(pattern string) []*parameter {
+	var params []*parameter
+	for _, segment := range strings.Split(openAPIPath(pattern), "/") {
+		if !strings.HasPrefix(segment, "{") {
+			continue
+		}
+		name := strings.Trim(segment, "{}")
+		s := &schema{Type: "string"}
+		if name == "id" || name == "version" {
+			s.Type = "integer"
+		}
+		params = append(params, &parameter{Name: name, In: "path", Required: true, Schema: s})
+	}
+	return params
+}
+
+// The structSchema function derives the schema of a struct type from its fields and their json
+// tags, the same way encoding/json decides what to send. The fields of embedded structs are
+// promoted, unless a field of the outer struct has the same name. Fields other than omitempty ones
+// are listed as required if required is set.
+func structSchema(t reflect.Type, names map[reflect.Type]string, required bool) *schema {
+	s := &schema{Type: "object", Properties: map[string]*schema{}}
+	var promoted []*schema
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" || (f.PkgPath != "" && !f.Anonymous) {
+			continue
+		}
+		name, opts := tag, ""
+		if i := strings.Index(tag, ","); i >= 0 {
+			name, opts = tag[:i], tag[i:]
+		}
+
+		ft := f.Type
+		if f.Anonymous && name == "" {
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				promoted = append(promoted, structSchema(ft, names, required))
+				continue
+			}
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+		s.Properties[name] = typeSchema(ft, names)
+		if required && !strings.Contains(opts, ",omitempty") {
+			s.Required = append(s.Required, name)
+		}
+	}
+
+	for _, p := range promoted {
+		for name, ps := range p.Properties {
+			if _, ok := s.Properties[name]; !ok {
+				s.Properties[name] = ps
+			}
+		}
+		for _, name := range p.Required {
+			if !containsString(s.Required, name) {
+				s.Required = append(s.Required, name)
+			}
+		}
+	}
+	sort.Strings(s.Required)
+	return s
+}
+
+// The typeSchema function derives the schema of a field`s type. Structs with a named schema are
+// referred to by name, and pointers are nullable.
+func typeSchema(t reflect.Type, names map[reflect.Type]string) *schema {
+	if t == reflect.TypeOf(time.Time{}) {
+		return &schema{Type: "string", Format: "date-time"}
+	}
+	if name, ok := names[t]; ok {
+		return &schema{Ref: "#/components/schemas/" + name}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		s := typeSchema(t.Elem(), names)
+		if s.Ref != "" {
+			// A $ref can`t have siblings in OpenAPI 3.0, so pointers to named schemas stay as they are.
+			return s
+		}
+		s.Nullable = true
+		return s
+	case reflect.Bool:
+		return &schema{Type: "boolean"}
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return &schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &schema{Type: "number"}
+	case reflect.String:
+		return &schema{Type: "string"}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &schema{Type: "string", Format: "byte"}
+		}
+		return &schema{Type: "array", Items: typeSchema(t.Elem(), names)}
+	case reflect.Map:
+		return &schema{Type: "object", AdditionalProperties: typeSchema(t.Elem(), names)}
+	case reflect.Struct:
+		return structSchema(t, names, true)
+	}
+	return &schema{}
+}
+
+// The containsString function reports whether a list of strings includes s.
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// The openAPIRoute type describes the route registered for one method and pat pattern.
+type openAPIRoute struct {
+	method  string
+	pattern string
+	op      *operation
+}
+
+// The openAPIRoutes function describes every route registered by router(). A new route has to be
+// added here too, or TestOpenAPIRoutes fails.
+func openAPIRoutes() []openAPIRoute {
+	// Snippets the API sends and accepts.
+	snippet := jsonContent(&schema{Ref: "#/components/schemas/Snippet"})
+	snippetInput := &requestBody{Required: true, Content: jsonContent(&schema{Ref: "#/components/schemas/SnippetInput"})}
+	notFound := errorResponse("There is no such snippet, or it can`t be seen by its ID.")
+
+	return []openAPIRoute{
+		// Snippet pages.
+		{http.MethodGet, "/", pageOp("Home page", "Lists the latest public snippets.", "snippets",
+			query("sort", "The order of the list: newest, oldest, expiring or title."),
+			query("after", "The cursor of the page after which to start."),
+			query("before", "The cursor of the page before which to end."))},
+		{http.MethodGet, "/search", pageOp("Search snippets", "", "snippets",
+			query("q", "The words to search for."),
+			query("page", "The page of results, numbered from 1."))},
+		{http.MethodGet, "/tag/:name", pageOp("List the public snippets with a tag", "", "snippets")},
+		{http.MethodGet, "/snippet/create", signedIn(pageOp("Form to create a snippet", "", "snippets"))},
+		{http.MethodPost, "/snippet/create", signedIn(formOp("Create a snippet", "snippets",
+			"title", "filename", "content", "language", "tags", "visibility", "expires", "expires_amount",
+			"expires_unit", "max_views", "file_name", "file_language", "file_content"))},
+		{http.MethodGet, "/snippet/admin", signedIn(pageOp("Administration page", "Only for administrators.", "admin"))},
+		{http.MethodPost, "/snippet/admin/limits", signedIn(formOp("Change the expiry limits", "admin", "user", "administrator"))},
+		{http.MethodGet, "/snippet/chat", pageOp("Chat page", "Shows the latest messages in the general room.", "chat",
+			query("before", "Show the messages posted before the one with this ID."))},
+		{http.MethodPost, "/snippet/chat", signedIn(formOp("Post a message to a chat room", "chat", "content", "room"))},
+		{http.MethodPost, "/snippet/chat/rooms", signedIn(formOp("Create a public or private chat room", "chat", "name", "kind"))},
+		{http.MethodPost, "/snippet/chat/direct", signedIn(formOp("Open the direct room with another user", "chat", "email"))},
+		{http.MethodGet, "/snippet/chat/:id", pageOp("Chat room page", "Shows the latest messages in a room. Only members can read rooms which aren`t public.", "chat",
+			query("before", "Show the messages posted before the one with this ID."))},
+		{http.MethodPost, "/snippet/chat/:id/join", signedIn(formOp("Join a public chat room", "chat"))},
+		{http.MethodPost, "/snippet/chat/:id/invite", signedIn(formOp("Invite somebody to a private chat room", "chat", "invitee"))},
+		{http.MethodPost, "/snippet/delete", signedIn(formOp("Move a snippet to the trash", "snippets", "id"))},
+		{http.MethodPost, "/snippet/restore", signedIn(formOp("Restore a snippet from the trash", "snippets", "id"))},
+		{http.MethodPost, "/snippet/purge", signedIn(formOp("Delete a snippet for good", "admin", "id"))},
+		{http.MethodGet, "/snippet/:id", pageOp("Show a snippet", "", "snippets")},
+		{http.MethodGet, "/s/:slug", pageOp("Show a snippet by its slug", "", "snippets")},
+		{http.MethodPost, "/s/:slug", formOp("Use up a view of a snippet with a view limit", "snippets")},
+		{http.MethodGet, "/s/:slug/raw", textOp("The content of a snippet`s first file, by its slug", "snippets")},
+		{http.MethodGet, "/s/:slug/download", textOp("Download a snippet`s first file, by its slug", "snippets")},
+		{http.MethodGet, "/s/:slug/raw/:name", textOp("The content of one of a snippet`s files, by its slug", "snippets")},
+		{http.MethodGet, "/s/:slug/zip", binaryOp("Download every file of a snippet as a zip archive, by its slug", "snippets", "application/zip")},
+		{http.MethodGet, "/snippet/:id/edit", signedIn(pageOp("Form to edit a snippet", "", "snippets"))},
+		{http.MethodPost, "/snippet/:id/edit", signedIn(formOp("Edit a snippet", "snippets",
+			"title", "filename", "content", "file_name", "file_language", "file_content"))},
+		{http.MethodPost, "/snippet/:id/expiry", signedIn(formOp("Change when a snippet expires", "snippets",
+			"expires", "expires_amount", "expires_unit", "expires_at"))},
+		{http.MethodGet, "/snippet/:id/raw", textOp("The content of a snippet`s first file", "snippets")},
+		{http.MethodGet, "/snippet/:id/download", textOp("Download a snippet`s first file", "snippets")},
+		{http.MethodGet, "/snippet/:id/raw/:name", textOp("The content of one of a snippet`s files", "snippets")},
+		{http.MethodGet, "/snippet/:id/zip", binaryOp("Download every file of a snippet as a zip archive", "snippets", "application/zip")},
+		{http.MethodGet, "/snippet/:id/history", pageOp("List the revisions of a snippet", "", "snippets")},
+		{http.MethodGet, "/snippet/:id/revision/:version", pageOp("Show a revision of a snippet", "", "snippets")},
+		{http.MethodGet, "/snippet/:id/diff", pageOp("Compare two revisions of a snippet", "", "snippets",
+			query("from", "The version to compare from."),
+			query("to", "The version to compare to."),
+			query("view", "Set to unified for a unified diff."))},
+		{http.MethodGet, "/snippet/:id/diff/raw", textOp("A unified diff between two revisions of a snippet", "snippets",
+			query("from", "The version to compare from."),
+			query("to", "The version to compare to."))},
+
+		// The chat`s WebSocket.
+		{http.MethodGet, "/chat/ws", &operation{
+			Summary:     "Connect to the chat over a WebSocket",
+			Description: "New messages in the rooms the user can see arrive as JSON events like {\"type\": \"message\", \"message\": {...}}. Messages can be posted by sending {\"room\": 1, \"content\": \"...\"}, and a room marked read up to a message by sending {\"type\": \"read\", \"room\": 1, \"message\": 42}. Problems with a message sent over the socket come back as {\"type\": \"error\", \"error\": \"...\"}.",
+			Tags:        []string{"chat"},
+			Responses: map[string]*response{
+				"101": {Description: "The connection was upgraded to a WebSocket."},
+				"303": {Description: "The user isn`t signed in, and is sent to the login page."},
+				"400": {Description: "The request isn`t a WebSocket handshake."},
+				"403": {Description: "The request came from a page on another site."},
+			},
+			Security: sessionSecurity,
+		}},
+
+		// Snippet events.
+		{http.MethodGet, "/events", &operation{
+			Summary:     "Follow snippet activity as Server-Sent Events",
+			Description: "A text/event-stream of snippet-created, snippet-deleted and snippet-expired events, whose data is a JSON object with the snippet`s id, slug, title, visibility, user_id and path. Events about snippets which aren`t public only go to their owner, signed in or using a token with the snippets:read scope. Clients reconnecting with a Last-Event-ID header get the recent events they missed.",
+			Tags:        []string{"events"},
+			Parameters: []*parameter{
+				{Name: "Last-Event-ID", In: "header", Description: "The ID of the last event received.", Schema: &schema{Type: "integer"}},
+			},
+			Responses: map[string]*response{
+				"200": {Description: "The event stream.", Content: map[string]*mediaType{"text/event-stream": {Schema: &schema{Type: "string"}}}},
+				"401": {Description: "The API token isn`t valid."},
+				"403": {Description: "The API token doesn`t have the snippets:read scope."},
+			},
+		}},
+
+		// Scripts.
+		{http.MethodPost, "/paste", &operation{
+			Summary:     "Create a snippet from a raw request body",
+			Description: "The body is the content of the snippet, or a multipart form with a \"file\" part. The other fields can also be given as X- headers, like X-Title. Needs a token with the snippets:write scope.",
+			Tags:        []string{"scripts"},
+			Parameters: []*parameter{
+				query("title", "The title of the snippet; the name of the uploaded file by default."),
+				query("expires", "When the snippet expires, like 30m, 12h or 7d; a week by default."),
+				query("language", "The language of the snippet."),
+				query("visibility", "public, unlisted (the default) or private."),
+				query("tags", "A comma separated list of tags."),
+				query("max_views", "How many times the snippet can be viewed."),
+			},
+			RequestBody: &requestBody{Required: true, Content: map[string]*mediaType{
+				"text/plain":          {Schema: &schema{Type: "string"}},
+				"multipart/form-data": {Schema: &schema{Type: "object", Properties: map[string]*schema{"file": {Type: "string", Format: "binary"}}}},
+			}},
+			Responses: map[string]*response{
+				"201": {Description: "The URL of the new snippet.", Content: map[string]*mediaType{"text/plain": {Schema: &schema{Type: "string"}}}},
+				"400": {Description: "The snippet is invalid; the problems are listed one per line."},
+				"401": {Description: "The request has no valid API token."},
+				"403": {Description: "The token doesn`t have the snippets:write scope."},
+				"413": {Description: "The snippet is too large."},
+			},
+			Security: bearerSecurity,
+		}},
+
+		// The JSON API.
+		{http.MethodGet, "/api/v1/snippets", &operation{
+			Summary:     "List public snippets",
+			Description: "Tokens need the snippets:read scope; anonymous requests are allowed.",
+			Tags:        []string{"api"},
+			Parameters: []*parameter{
+				query("sort", "The order of the list: newest (the default), oldest, expiring or title."),
+				query("after", "The next cursor of the previous page."),
+				query("before", "The prev cursor of the following page."),
+				{Name: "limit", In: "query", Description: "The size of the page, up to 100.", Schema: &schema{Type: "integer"}},
+				query("tag", "Only list snippets with this tag."),
+			},
+			Responses: map[string]*response{
+				"200": {Description: "A page of snippets.", Content: jsonContent(&schema{Ref: "#/components/schemas/SnippetList"})},
+				"400": errorResponse("The limit, sort order or cursor is invalid."),
+			},
+		}},
+		{http.MethodPost, "/api/v1/snippets", &operation{
+			Summary:     "Create a snippet",
+			Description: "Needs a token with the snippets:write scope.",
+			Tags:        []string{"api"},
+			RequestBody: snippetInput,
+			Responses: map[string]*response{
+				"201": {Description: "The new snippet. Its API address is in the Location header.", Content: snippet},
+				"400": errorResponse("The body isn`t valid JSON."),
+				"401": errorResponse("The request has no valid API token."),
+				"403": errorResponse("The token doesn`t have the snippets:write scope."),
+				"422": errorResponse("The snippet is invalid; the problems with each field are listed."),
+			},
+			Security: bearerSecurity,
+		}},
+		{http.MethodGet, "/api/v1/snippets/:id", &operation{
+			Summary:     "Get a snippet with all of its files",
+			Description: "Tokens need the snippets:read scope; anonymous requests are allowed.",
+			Tags:        []string{"api"},
+			Responses: map[string]*response{
+				"200": {Description: "The snippet.", Content: snippet},
+				"404": notFound,
+			},
+		}},
+		{http.MethodPut, "/api/v1/snippets/:id", &operation{
+			Summary:     "Update one of your snippets",
+			Description: "Only the title, filename, content and files can be changed. Needs a token with the snippets:write scope.",
+			Tags:        []string{"api"},
+			RequestBody: snippetInput,
+			Responses: map[string]*response{
+				"200": {Description: "The updated snippet.", Content: snippet},
+				"401": errorResponse("The request has no valid API token."),
+				"403": errorResponse("The snippet isn`t yours, or the token doesn`t have the snippets:write scope."),
+				"404": notFound,
+				"422": errorResponse("The snippet is invalid; the problems with each field are listed."),
+			},
+			Security: bearerSecurity,
+		}},
+		{http.MethodDelete, "/api/v1/snippets/:id", &operation{
+			Summary:     "Move one of your snippets to the trash",
+			Description: "Needs a token with the snippets:write scope.",
+			Tags:        []string{"api"},
+			Responses: map[string]*response{
+				"204": {Description: "The snippet was deleted."},
+				"401": errorResponse("The request has no valid API token."),
+				"403": errorResponse("The snippet isn`t yours, or the token doesn`t have the snippets:write scope."),
+				"404": notFound,
+			},
+			Security: bearerSecurity,
+		}},
+		{http.MethodGet, "/api/v1/user", &operation{
+			Summary: "Get the account the API token belongs to",
+			Tags:    []string{"api"},
+			Responses: map[string]*response{
+				"200": {Description: "The user.", Content: jsonContent(&schema{Ref: "#/components/schemas/User"})},
+				"401": errorResponse("The request has no valid API token."),
+			},
+			Security: bearerSecurity,
+		}},
+		{http.MethodGet, "/api/openapi.json", &operation{
+			Summary: "This document",
+			Tags:    []string{"api"},
+			Responses: map[string]*response{
+				"200": {Description: "The OpenAPI document.", Content: jsonContent(&schema{Type: "object"})},
+			},
+		}},
+
+		// User pages.
+		{http.MethodGet, "/user/signup", pageOp("Form to sign up", "", "users")},
+		{http.MethodPost, "/user/signup", formOp("Sign up", "users", "name", "email", "password")},
+		{http.MethodGet, "/user/login", pageOp("Form to log in", "", "users")},
+		{http.MethodPost, "/user/login", formOp("Log in", "users", "email", "password")},
+		{http.MethodPost, "/user/logout", signedIn(formOp("Log out", "users"))},
+		{http.MethodGet, "/user/snippets", signedIn(pageOp("List your snippets", "", "users"))},
+		{http.MethodGet, "/user/trash", signedIn(pageOp("List your deleted snippets", "", "users"))},
+		{http.MethodGet, "/user/tokens", signedIn(pageOp("List your API tokens", "", "users"))},
+		{http.MethodPost, "/user/tokens", signedIn(formOp("Create an API token", "users", "name", "scopes", "expires"))},
+		{http.MethodPost, "/user/tokens/revoke", signedIn(formOp("Revoke an API token", "users", "id"))},
+
+		// Everything else.
+		{http.MethodGet, "/ping", textOp("Check that the server is up", "misc")},
+		{http.MethodGet, "/static/", binaryOp("Static files: stylesheets, scripts and images", "misc", "application/octet-stream")},
+	}
+}
+
+// The security requirements of routes authenticated by an API token or by the session cookie.
+var (
+	bearerSecurity  = []map[string][]string{{"bearerAuth": {}}}
+	sessionSecurity = []map[string][]string{{"sessionCookie": {}}}
+)
+
+// The pageOp function describes a route which responds with an HTML page.
+func pageOp(summary, description, tag string, params ...*parameter) *operation {
+	return &operation{
+		Summary:     summary,
+		Description: description,
+		Tags:        []string{tag},
+		Parameters:  params,
+		Responses: map[string]*response{
+			"200": {Description: "An HTML page.", Content: map[string]*mediaType{"text/html": {Schema: &schema{Type: "string"}}}},
+		},
+	}
+}
+
+// The formOp function describes a route which handles an HTML form with the named fields. Every form
+// needs the CSRF token of the page it is on, as well.
+func formOp(summary, tag string, fields ...string) *operation {
+	s := &schema{Type: "object", Properties: map[string]*schema{}, Required: []string{"csrf_token"}}
+	for _, field := range append([]string{"csrf_token"}, fields...) {
+		s.Properties[field] = &schema{Type: "string"}
+	}
+	return &operation{
+		Summary: summary,
+		Tags:    []string{tag},
+		RequestBody: &requestBody{Required: true, Content: map[string]*mediaType{
+			"application/x-www-form-urlencoded": {Schema: s},
+		}},
+		Responses: map[string]*response{
+			"200": {Description: "The form again, with the problems to fix.", Content: map[string]*mediaType{"text/html": {Schema: &schema{Type: "string"}}}},
+			"303": {Description: "The form was handled; see the Location header for where to go next."},
+			"400": {Description: "The CSRF token is missing or wrong."},
+		},
+	}
+}
+
+// The textOp function describes a route which responds with plain text.
+func textOp(summary, tag string, params ...*parameter) *operation {
+	return binaryOp(summary, tag, "text/plain", params...)
+}
+
+// The binaryOp function describes a route which responds with a file of the given media type.
+func binaryOp(summary, tag, mediaTypeName string, params ...*parameter) *operation {
+	return &operation{
+		Summary:    summary,
+		Tags:       []string{tag},
+		Parameters: params,
+		Responses: map[string]*response{
+			"200": {Description: "The file.", Content: map[string]*mediaType{mediaTypeName: {Schema: &schema{Type: "string"}}}},
+			"404": {Description: "There is no such file."},
+		},
+	}
+}
+
+// The signedIn function marks a route as needing the session cookie of a signed in user.
+func signedIn(op *operation) *operation {
+	op.Security = sessionSecurity
+	return op
+}
+
+// The query function describes an optional string query parameter.
+func query(name, description string) *parameter {
+	return &parameter{Name: name, In: "query", Description: description, Schema: &schema{Type: "string"}}
+}
+
+// The jsonContent function describes a JSON body with the given schema.
+func jsonContent(s *schema) map[string]*mediaType {
+	return map[string]*mediaType{"application/json": {Schema: s}}
+}
+
+// The errorResponse function describes a JSON error response from the API.
+func errorResponse(description string) *response {
+	return &response{Description: description, Content: jsonContent(&schema{Ref: "#/components/schemas/Error"})}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// The registeredRoutes function lists the method and pattern of every route registered with a pat
+// servemux. The servemux doesn`t export its routes, so they are read with reflection. The HEAD routes
+// pat adds for every GET route, and the redirects it adds for patterns ending in a slash, are left out.
+func registeredRoutes(t *testing.T, mux interface{}) map[string][]string {
+	handlers := reflect.ValueOf(mux).Elem().FieldByName("handlers")
+	if handlers.Kind() != reflect.Map {
+		t.Fatal("can`t find the routes of the pat servemux")
+	}
+
+	routes := map[string][]string{}
+	for _, method := range handlers.MapKeys() {
+		if method.String() == http.MethodHead {
+			continue
+		}
+		list := handlers.MapIndex(method)
+		for i := 0; i < list.Len(); i++ {
+			handler := list.Index(i).Elem()
+			if handler.FieldByName("redirect").Bool() {
+				continue
+			}
+			pattern := handler.FieldByName("pat").String()
+			routes[method.String()] = append(routes[method.String()], pattern)
+		}
+	}
+	return routes
+}
+
+func TestOpenAPIRoutes(t *testing.T) {
+	app := newTestApplication(t)
+	doc := newOpenAPIDocument("http://example.com")
+
+	documented := 0
+	for _, ops := range doc.Paths {
+		documented += len(ops)
+	}
+
+	registered := 0
+	for method, patterns := range registeredRoutes(t, app.router()) {
+		for _, pattern := range patterns {
+			registered++
+			if doc.Paths[openAPIPath(pattern)][strings.ToLower(method)] == nil {
+				t.Errorf("%s %s isn`t described in the OpenAPI document", method, pattern)
+			}
+		}
+	}
+
+	// Routes which have been removed shouldn`t be left in the document either.
+	if documented != registered {
+		t.Errorf("want %d operations in the OpenAPI document; got %d", registered, documented)
+	}
+}
+
+func TestOpenAPI(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, header, body := ts.get(t, "/api/openapi.json")
+	if code != http.StatusOK {
+		t.Fatalf("want %d; got %d", http.StatusOK, code)
+	}
+	if header.Get("Content-Type") != "application/json" {
+		t.Errorf("want a JSON response; got %q", header.Get("Content-Type"))
+	}
+
+	var doc openAPIDocument
+	err := json.Unmarshal(body, &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Servers[0].URL != ts.URL {
+		t.Errorf("want server %q; got %q", ts.URL, doc.Servers[0].URL)
+	}
+
+	user := doc.Components.Schemas["User"]
+	if _, ok := user.Properties["hashed_password"]; ok {
+		t.Error("want the User schema to leave out the hashed password")
+	}
+	if _, ok := user.Properties["HashedPassword"]; ok {
+		t.Error("want the User schema to leave out the hashed password")
+	}
+
+	// The Snippet schema is derived from apiSnippet, which embeds models.Snippet.
+	snippet := doc.Components.Schemas["Snippet"]
+	tests := map[string]string{"id": "integer", "title": "string", "tags": "array", "url": "string", "expires": "string"}
+	for name, want := range tests {
+		if p := snippet.Properties[name]; p == nil || p.Type != want {
+			t.Errorf("want Snippet.%s to be a %s; got %+v", name, want, p)
+		}
+	}
+	if !snippet.Properties["expires"].Nullable {
+		t.Error("want Snippet.expires to be nullable")
+	}
+	if _, ok := snippet.Properties["deleted"]; ok {
+		t.Error("want the Snippet schema to leave out the deleted flag")
+	}
+	if ref := snippet.Properties["files"].Items.Ref; ref != "#/components/schemas/File" {
+		t.Errorf("want Snippet.files to refer to File; got %q", ref)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"sabiraliyev.net/snippetbox/pkg/models"
+)
+
+// The pageSize constant is the number of snippets shown on each page of a listing.
+const pageSize = 20
+
+// The sortOrders slice lists the orders a listing can be sorted in, as offered by the sort links.
+var sortOrders = []string{models.SortNewest, models.SortOldest, models.SortExpiring, models.SortTitle}
+
+// The pagination type holds what the "pagination" template needs to link to other pages and
+// orderings of the listing at Path. Next and Prev are empty when there is no such page.
+type pagination struct {
+	Path  string
+	Sort  string
+	Sorts []string
+	Next  string
+	Prev  string
+}
+
+// The listSnippets helper fetches the page of snippets selected by the ?sort=, ?after= and ?before=
+// query parameters, filling them into opt, whose other fields pick which snippets are listed. If the
+// parameters are invalid, a 400 Bad Request response is sent and ok is false.
+func (app *application) listSnippets(w http.ResponseWriter, r *http.Request, opt models.ListOptions) (*models.SnippetPage, *pagination, bool) {
+	query := r.URL.Query()
+
+	sort := query.Get("sort")
+	if sort == "" {
+		sort = models.SortNewest
+	}
+
+	opt.Sort = sort
+	opt.After = query.Get("after")
+	opt.Before = query.Get("before")
+	opt.Limit = pageSize
+	page, err := app.snippets.List(opt)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCursor) {
+			app.clientError(w, http.StatusBadRequest)
+		} else {
+			app.serverError(w, err)
+		}
+		return nil, nil, false
+	}
+
+	return page, &pagination{
+		Path:  r.URL.Path,
+		Sort:  sort,
+		Sorts: sortOrders,
+		Next:  page.Next,
+		Prev:  page.Prev,
+	}, true
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"sabiraliyev.net/snippetbox/pkg/forms"
+)
+
+// The largest snippet the paste endpoint accepts. Multipart requests may be a little bigger, to
+// make room for the part headers.
+const maxPasteSize = 1 << 20
+
+var errPasteTooLarge = errors.New("paste too large")
+
+// The pasteParams map holds the snippet fields which can be given to the paste endpoint, with the
+// request header each can also be given in. Query parameters win over headers.
+var pasteParams = map[string]string{
+	"title":      "X-Title",
+	"expires":    "X-Expires",
+	"language":   "X-Language",
+	"visibility": "X-Visibility",
+	"tags":       "X-Tags",
+	"max_views":  "X-Max-Views",
+}
+
+// The shortExpiryRX regular expression matches the short expiry durations accepted by the paste
+// endpoint and the API, like 30m, 12h or 7d.
+var shortExpiryRX = regexp.MustCompile(`^([0-9]+)([mhd])$`)
+
+var shortExpiryUnits = map[string]string{"m": "minutes", "h": "hours", "d": "days"}
+
+// The paste handler creates a snippet from the raw body of a request, or from the "file" part of a
+// multipart form, so snippets can be posted from a shell:
+//
+//	curl -H "Authorization: Bearer $TOKEN" --data-binary @main.go https://host/paste?expires=1d
+//
+// The request is authenticated with an API token instead of the session cookie, so there is no
+// CSRF token to fetch. The other fields come from query parameters or X- headers. The URL of the new
+// snippet is sent back as plain text, and validation problems are listed one per line.
+func (app *application) paste(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 2*maxPasteSize)
+
+	content, filename, err := readPaste(r)
+	if err != nil {
+		if errors.Is(err, errPasteTooLarge) {
+			app.clientError(w, http.StatusRequestEntityTooLarge)
+		} else {
+			app.clientError(w, http.StatusBadRequest)
+		}
+		return
+	}
+	if !utf8.ValidString(content) {
+		http.Error(w, "content: The snippet must be UTF-8 text", http.StatusBadRequest)
+		return
+	}
+
+	maxExpiry, err := app.maxExpiry(r)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	form := forms.New(pasteValues(r, content, filename))
+	s := newSnippet(form, maxExpiry, app.authenticatedUserID(r))
+	if !form.Valid() {
+		http.Error(w, formErrors(form), http.StatusBadRequest)
+		return
+	}
+
+	err = app.snippets.Insert(s)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.events.publish(eventSnippetCreated, s)
+
+	location := absoluteURL(r, "/s/"+s.Slug)
+	w.Header().Set("Location", location)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusCreated)
+	fmt.Fprintln(w, location)
+}
+
+// The readPaste function returns the content of a paste and, if it was uploaded as a file in a
+// multipart form, the name of the file. Any other body is taken as the content itself, whatever its
+// content type: curl sends --data-binary bodies as application/x-www-form-urlencoded.
+func readPaste(r *http.Request) (string, string, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		b, err := ioutil.ReadAll(io.LimitReader(r.Body, maxPasteSize+1))
+		if len(b) > maxPasteSize {
+			return "", "", errPasteTooLarge
+		}
+		return string(b), "", err
+	}
+
+	err := r.ParseMultipartForm(maxPasteSize)
+	if err != nil {
+		return "", "", err
+	}
+	f, header, err := r.FormFile("file")
+	if err != nil {
+		return "", "", err
+	}
+	defer f.Close()
+	if header.Size > maxPasteSize {
+		return "", "", errPasteTooLarge
+	}
+	b, err := ioutil.ReadAll(f)
+	return string(b), header.Filename, err
+}
+
+// The pasteValues function gathers the fields of a pasted snippet into form values, filling in
+// defaults for anything missing. Pastes expire after a week and are unlisted unless asked otherwise,
+// as they are often logs and other things which don`t belong on the home page, and are titled after
+// the uploaded file.
+func pasteValues(r *http.Request, content, filename string) url.Values {
+	values := url.Values{
+		"content":    {content},
+		"filename":   {filename},
+		"title":      {firstNonEmpty(filename, "Untitled paste")},
+		"expires":    {"7"},
+		"visibility": {"unlisted"},
+	}
+	query := r.URL.Query()
+	for name, header := range pasteParams {
+		if v := firstNonEmpty(query.Get(name), r.Header.Get(header)); v != "" {
+			values.Set(name, v)
+		}
+	}
+
+	shortExpiry(values)
+	return values
+}
+
+// The shortExpiry function turns a short expiry duration, like 30m, 12h or 7d, into the custom
+// expiry fields of the create form. Anything else is left to be checked against the presets,
+// "never", "burn" and "views" as usual.
+func shortExpiry(values url.Values) {
+	if m := shortExpiryRX.FindStringSubmatch(values.Get("expires")); m != nil {
+		values.Set("expires", "custom")
+		values.Set("expires_amount", m[1])
+		values.Set("expires_unit", shortExpiryUnits[m[2]])
+	}
+}
+
+// The formErrors function lists the problems with a form as plain text, one "field: message" line
+// per problem, in a stable order.
+func formErrors(form *forms.Form) string {
+	fields := make([]string, 0, len(form.Errors))
+	for field := range form.Errors {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	var b strings.Builder
+	for _, field := range fields {
+		for _, message := range form.Errors[field] {
+			fmt.Fprintf(&b, "%s: %s\n", field, message)
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// The absoluteURL function turns a path into an absolute URL on the host the request was sent to.
+func absoluteURL(r *http.Request, path string) string {
+	scheme := "https"
+	if r.TLS == nil {
+		scheme = "http"
+	}
+	return scheme + "://" + r.Host + path
+}
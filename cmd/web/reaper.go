@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// The reaper permanently removes snippets which expired more than a grace period ago, along with
+// snippets which have used up all their views. It works in bounded batches, so that no single
+// statement holds its locks for long, and remembers the outcome of its last run for the admin page.
+type reaper struct {
+	snippets interface {
+		Reap(time.Duration, int) (int, error)
+	}
+	errorLog  *log.Logger
+	infoLog   *log.Logger
+	interval  time.Duration
+	grace     time.Duration
+	batchSize int
+
+	mu    sync.Mutex
+	stats reaperStats
+}
+
+// The reaperStats type holds the outcome of a single run of the reaper.
+type reaperStats struct {
+	Started  time.Time
+	Duration time.Duration
+	Batches  int
+	Purged   int
+	Err      error
+}
+
+// The run method reaps straight away and then once every interval, until ctx is cancelled.
+func (rp *reaper) run(ctx context.Context) {
+	ticker := time.NewTicker(rp.interval)
+	defer ticker.Stop()
+
+	for {
+		rp.reap(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// The reap method deletes batches of dead snippets until a batch comes back short, meaning there
+// are none left, and records the outcome.
+func (rp *reaper) reap(ctx context.Context) {
+	stats := reaperStats{Started: time.Now()}
+
+	for ctx.Err() == nil {
+		n, err := rp.snippets.Reap(rp.grace, rp.batchSize)
+		if err != nil {
+			stats.Err = err
+			rp.errorLog.Printf("reaper: %v", err)
+			break
+		}
+		stats.Batches++
+		stats.Purged += n
+		if n < rp.batchSize {
+			break
+		}
+	}
+	stats.Duration = time.Since(stats.Started)
+
+	if stats.Purged > 0 {
+		rp.infoLog.Printf("reaper: purged %d snippets in %s", stats.Purged, stats.Duration)
+	}
+
+	rp.mu.Lock()
+	rp.stats = stats
+	rp.mu.Unlock()
+}
+
+// The lastRun method returns the outcome of the most recent run. The Started field is the zero time
+// if the reaper hasn`t run yet.
+func (rp *reaper) lastRun() reaperStats {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	return rp.stats
+}
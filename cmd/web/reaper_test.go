@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"log"
+	"testing"
+	"time"
+)
+
+// The fakeReaper type purges from a pretend table of remaining dead snippets.
+type fakeReaper struct {
+	remaining int
+	calls     int
+	err       error
+}
+
+func (f *fakeReaper) Reap(grace time.Duration, limit int) (int, error) {
+	f.calls++
+	if f.err != nil {
+		return 0, f.err
+	}
+	n := limit
+	if f.remaining < n {
+		n = f.remaining
+	}
+	f.remaining -= n
+	return n, nil
+}
+
+func TestReaperReap(t *testing.T) {
+	tests := []struct {
+		name        string
+		remaining   int
+		err         error
+		wantBatches int
+		wantPurged  int
+	}{
+		{"Nothing to purge", 0, nil, 1, 0},
+		{"Partial batch", 7, nil, 1, 7},
+		{"Several batches", 25, nil, 3, 25},
+		{"Exact batches", 20, nil, 3, 20},
+		{"Error", 5, errors.New("database is down"), 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &fakeReaper{remaining: tt.remaining, err: tt.err}
+			rp := &reaper{
+				snippets:  f,
+				errorLog:  log.New(ioutil.Discard, "", 0),
+				infoLog:   log.New(ioutil.Discard, "", 0),
+				batchSize: 10,
+			}
+			rp.reap(context.Background())
+
+			stats := rp.lastRun()
+			if stats.Started.IsZero() {
+				t.Error("want the run to be recorded")
+			}
+			if stats.Batches != tt.wantBatches {
+				t.Errorf("want %d batches; got %d", tt.wantBatches, stats.Batches)
+			}
+			if stats.Purged != tt.wantPurged {
+				t.Errorf("want %d purged; got %d", tt.wantPurged, stats.Purged)
+			}
+			if stats.Err != tt.err {
+				t.Errorf("want error %v; got %v", tt.err, stats.Err)
+			}
+		})
+	}
+}
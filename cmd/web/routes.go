@@ -3,6 +3,7 @@ package main
 import (
 	"github.com/bmizerany/pat"
 	"net/http"
+	"sabiraliyev.net/snippetbox/pkg/models"
 
 	"github.com/justinas/alice"
 )
@@ -13,19 +14,93 @@ func (app *application) routes() http.Handler {
 	// which will be used for every request our application receives.
 	standardMiddleware := alice.New(app.recoverPanic, app.logRequest, secureHeaders)
 
+	// Return the 'standard' middleware chain followed by servemux.
+	return standardMiddleware.Then(app.router())
+}
+
+// The router method registers every route with a new pat servemux. It is separate from routes() so
+// the tests can look at the registered routes, to check that the OpenAPI document describes them all.
+func (app *application) router() *pat.PatternServeMux {
 	// The middleware chain containing the middleware specific to our dynamic application routes.
 	// Using the noSurf middleware on all 'dynamic' routes with authenticate() and authenticateAsAdmin() middleware.
 	dynamicMiddleware := alice.New(app.session.Enable, noSurf, app.authenticate, app.authenticateAsAdmin)
 
+	// The middleware chain for the chat`s WebSocket. It is authenticated by the session cookie like the
+	// pages are, but needs no CSRF token: the handshake is a GET request, and websocket.Upgrade()
+	// refuses requests from other sites. The session is only read, as the connection is hijacked.
+	socketMiddleware := alice.New(app.readSession, app.authenticate, app.requireAuthentication)
+
+	// The middleware chain for the /events stream, which browsers and scripts can both follow. The
+	// server`s write timeout is lifted first, as the stream stays open indefinitely.
+	streamMiddleware := alice.New(clearWriteDeadline, app.session.Enable, app.authenticate, app.authenticateToken,
+		app.checkScope(models.ScopeSnippetsRead))
+
+	// The middleware chain for routes used by scripts rather than browsers. They are authenticated
+	// with an API token instead of the session cookie, so they don`t need CSRF protection. Public
+	// snippets can be read without a token, but changing anything takes a token with the right scope.
+	tokenMiddleware := alice.New(app.authenticateToken)
+	readMiddleware := tokenMiddleware.Append(app.checkScope(models.ScopeSnippetsRead))
+	writeMiddleware := tokenMiddleware.Append(app.requireScope(models.ScopeSnippetsWrite))
+
 	mux := pat.New()
 	//#region Snippet routes.
 	mux.Get("/", dynamicMiddleware.ThenFunc(app.home))
+	mux.Get("/search", dynamicMiddleware.ThenFunc(app.search))
+	mux.Get("/tag/:name", dynamicMiddleware.ThenFunc(app.showTag))
 	mux.Get("/snippet/create", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.createSnippetForm))
 	mux.Post("/snippet/create", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.createSnippet))
-	mux.Get("/snippet/admin", dynamicMiddleware.ThenFunc(app.showAdminPage))
+	mux.Get("/snippet/admin", dynamicMiddleware.Append(app.requireAdministrator).ThenFunc(app.showAdminPage))
+	mux.Post("/snippet/admin/limits", dynamicMiddleware.Append(app.requireAdministrator).ThenFunc(app.setExpiryLimits))
 	mux.Get("/snippet/chat", dynamicMiddleware.ThenFunc(app.showChatPage))
+	mux.Post("/snippet/chat", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.postMessage))
+	mux.Post("/snippet/chat/rooms", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.createRoom))
+	mux.Post("/snippet/chat/direct", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.startDirect))
+	mux.Get("/snippet/chat/:id", dynamicMiddleware.ThenFunc(app.showChatPage))
+	mux.Post("/snippet/chat/:id/join", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.joinRoom))
+	mux.Post("/snippet/chat/:id/invite", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.inviteToRoom))
 	mux.Post("/snippet/delete", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.deleteSnippet))
+	mux.Post("/snippet/restore", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.restoreSnippet))
+	mux.Post("/snippet/purge", dynamicMiddleware.Append(app.requireAdministrator).ThenFunc(app.purgeSnippet))
 	mux.Get("/snippet/:id", dynamicMiddleware.ThenFunc(app.showSnippet))
+	mux.Get("/s/:slug", dynamicMiddleware.ThenFunc(app.showSnippetBySlug))
+	mux.Post("/s/:slug", dynamicMiddleware.ThenFunc(app.viewSnippet))
+	mux.Get("/s/:slug/raw", dynamicMiddleware.ThenFunc(app.rawSnippetBySlug))
+	mux.Get("/s/:slug/download", dynamicMiddleware.ThenFunc(app.downloadSnippetBySlug))
+	mux.Get("/s/:slug/raw/:name", dynamicMiddleware.ThenFunc(app.rawFileBySlug))
+	mux.Get("/s/:slug/zip", dynamicMiddleware.ThenFunc(app.zipSnippetBySlug))
+	mux.Get("/snippet/:id/edit", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.editSnippetForm))
+	mux.Post("/snippet/:id/edit", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.editSnippet))
+	mux.Post("/snippet/:id/expiry", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.changeExpiry))
+	mux.Get("/snippet/:id/raw", dynamicMiddleware.ThenFunc(app.rawSnippet))
+	mux.Get("/snippet/:id/download", dynamicMiddleware.ThenFunc(app.downloadSnippet))
+	mux.Get("/snippet/:id/raw/:name", dynamicMiddleware.ThenFunc(app.rawFile))
+	mux.Get("/snippet/:id/zip", dynamicMiddleware.ThenFunc(app.zipSnippet))
+	mux.Get("/snippet/:id/history", dynamicMiddleware.ThenFunc(app.snippetHistory))
+	mux.Get("/snippet/:id/revision/:version", dynamicMiddleware.ThenFunc(app.showRevision))
+	mux.Get("/snippet/:id/diff", dynamicMiddleware.ThenFunc(app.snippetDiff))
+	mux.Get("/snippet/:id/diff/raw", dynamicMiddleware.ThenFunc(app.rawSnippetDiff))
+	//#endregion
+
+	//#region Chat routes.
+	mux.Get("/chat/ws", socketMiddleware.ThenFunc(app.chatSocket))
+	//#endregion
+
+	//#region Event routes.
+	mux.Get("/events", streamMiddleware.ThenFunc(app.streamEvents))
+	//#endregion
+
+	//#region Script routes.
+	mux.Post("/paste", writeMiddleware.ThenFunc(app.paste))
+	//#endregion
+
+	//#region JSON API routes.
+	mux.Get("/api/v1/snippets", readMiddleware.ThenFunc(app.apiListSnippets))
+	mux.Post("/api/v1/snippets", writeMiddleware.ThenFunc(app.apiCreateSnippet))
+	mux.Get("/api/v1/snippets/:id", readMiddleware.ThenFunc(app.apiShowSnippet))
+	mux.Put("/api/v1/snippets/:id", writeMiddleware.ThenFunc(app.apiUpdateSnippet))
+	mux.Del("/api/v1/snippets/:id", writeMiddleware.ThenFunc(app.apiDeleteSnippet))
+	mux.Get("/api/v1/user", tokenMiddleware.Append(app.requireToken).ThenFunc(app.apiCurrentUser))
+	mux.Get("/api/openapi.json", http.HandlerFunc(app.openAPI))
 	//#endregion
 
 	//#region User session routes.
@@ -34,6 +109,11 @@ func (app *application) routes() http.Handler {
 	mux.Get("/user/login", dynamicMiddleware.ThenFunc(app.loginUserForm))
 	mux.Post("/user/login", dynamicMiddleware.ThenFunc(app.loginUser))
 	mux.Post("/user/logout", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.logoutUser))
+	mux.Get("/user/snippets", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.userSnippets))
+	mux.Get("/user/trash", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.userTrash))
+	mux.Get("/user/tokens", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.userTokens))
+	mux.Post("/user/tokens", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.createToken))
+	mux.Post("/user/tokens/revoke", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.revokeToken))
 	//#endregion
 
 	//#region Test rotes
@@ -48,6 +128,8 @@ func (app *application) routes() http.Handler {
 	// "/static/". For matching paths, we strip  the "/static" prefix before the request reaches the file server.
 	mux.Get("/static/", http.StripPrefix("/static", fileServer))
 
-	// Return the 'standard' middleware chain followed by servemux.
-	return standardMiddleware.Then(mux)
+	// Requests which don`t match any route get a JSON error if they were meant for the API.
+	mux.NotFound = http.HandlerFunc(app.routeNotFound)
+
+	return mux
 }
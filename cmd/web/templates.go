@@ -1,27 +1,73 @@
 package main
 
 import (
+	"fmt"
 	"html/template"
+	"net/url"
 	"path/filepath"
+	"strings"
 	"time"
 
+	"sabiraliyev.net/snippetbox/pkg/diff"
 	"sabiraliyev.net/snippetbox/pkg/forms"
+	"sabiraliyev.net/snippetbox/pkg/highlight"
+	"sabiraliyev.net/snippetbox/pkg/markdown"
 	"sabiraliyev.net/snippetbox/pkg/models"
 )
 
 // Define a templateData type to act as the holding structure for any dynamic data we want to pass
 // to our HTML templates.
 type templateData struct {
-	CSRFToken       string
-	CurrentYear     int
-	Flash           string
-	Form            *forms.Form
-	IsAuthenticated bool
-	IsAdministrator bool
-	Snippet         *models.Snippet
-	Snippets        []*models.Snippet
-	Message         *models.Message
-	Messages        []*models.Message
+	AuthenticatedUserID int
+	CSRFToken           string
+	CurrentYear         int
+	Flash               string
+	Form                *forms.Form
+	IsAuthenticated     bool
+	IsAdministrator     bool
+	Snippet             *models.Snippet
+	Snippets            []*models.Snippet
+	// Files holds every file of the snippet or revision being shown, or the further files in the
+	// create and edit forms.
+	Files           []*models.File
+	DeletedSnippets []*models.Snippet
+	ExpiryLimits    map[string]int
+	Pagination      *pagination
+	Query           string
+	SearchResults   *models.SearchPage
+	Tag             string
+	Tags            []*models.Tag
+	Reaper          *reaperStats
+	Tokens          []*models.Token
+	// NewToken is the text of an API token which has just been created.
+	NewToken    string
+	Revision    *models.Revision
+	Revisions   []*models.Revision
+	Diff        *diffData
+	Messages    *models.MessagePage
+	Room        *models.Room
+	Rooms       []*models.Room
+	PublicRooms []*models.Room
+}
+
+// The diffData type holds two revisions of a snippet and the differences between their files.
+type diffData struct {
+	From    *models.Revision
+	To      *models.Revision
+	Changed bool
+	Files   []*fileDiff
+	Unified bool
+}
+
+// The fileDiff type holds the difference between one file of two revisions, both as side-by-side
+// rows and as unified hunks. OldName or NewName is empty if the file was added or removed.
+type fileDiff struct {
+	OldName string
+	NewName string
+	Changed bool
+	Lines   []diff.Line
+	Rows    []diff.Row
+	Hunks   []diff.Hunk
 }
 
 // Create a humanDate function which returns a nicely formatted string representation of a time.Time object.
@@ -35,10 +81,112 @@ func humanDate(t time.Time) string {
 	return t.UTC().Format("02 Jan 2006 at 15:04")
 }
 
+// The diffClass function returns the CSS class used to highlight a line of a diff.
+func diffClass(op diff.Op) string {
+	switch op {
+	case diff.Insert:
+		return "add"
+	case diff.Delete:
+		return "del"
+	default:
+		return ""
+	}
+}
+
+// The diffSign function returns the character which prefixes a line in a unified diff.
+func diffSign(op diff.Op) string {
+	switch op {
+	case diff.Insert:
+		return "+"
+	case diff.Delete:
+		return "-"
+	default:
+		return " "
+	}
+}
+
+// The headline function turns a search result headline into HTML, escaping the snippet content and
+// wrapping the matching words in <mark> elements.
+func headline(s string) template.HTML {
+	s = template.HTMLEscapeString(s)
+	s = strings.ReplaceAll(s, models.HeadlineStart, "<mark>")
+	s = strings.ReplaceAll(s, models.HeadlineStop, "</mark>")
+	return template.HTML(s)
+}
+
+// The highlightLines function highlights code as the named language and returns it line by line.
+// The highlight package escapes the code, so the lines are safe to use as HTML.
+func highlightLines(language, src string) []template.HTML {
+	lines := highlight.Lines(language, src)
+	html := make([]template.HTML, len(lines))
+	for i, line := range lines {
+		html[i] = template.HTML(line)
+	}
+	return html
+}
+
+// The languageNames function returns the names of the languages snippets can be highlighted as.
+func languageNames() []string {
+	names := make([]string, len(highlight.Languages))
+	for i, l := range highlight.Languages {
+		names[i] = l.Name
+	}
+	return names
+}
+
+// The syntaxError function returns the first syntax error in the content of a Go snippet, or the
+// empty string if it parses (or isn`t Go). The check is cheap, so it is done each time the snippet
+// is shown and is never out of date after an edit.
+func syntaxError(language, src string) string {
+	if language != "go" {
+		return ""
+	}
+	if err := highlight.CheckGo(src); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+// The renderMarkdown function renders a Markdown snippet to HTML. The result is passed through the
+// allowlist sanitizer before being trusted as template.HTML.
+func renderMarkdown(src string) template.HTML {
+	return template.HTML(markdown.Sanitize(markdown.Render(src)))
+}
+
+// The percent function formats a fraction between 0 and 1 as a whole percentage.
+func percent(f float64) string {
+	return fmt.Sprintf("%.0f%%", f*100)
+}
+
+// The add function adds two numbers, for working out neighbouring page numbers in templates.
+func add(a, b int) int {
+	return a + b
+}
+
+// The expired function reports whether an expiry time has passed. The zero time never expires.
+func expired(t time.Time) bool {
+	return !t.IsZero() && t.Before(time.Now())
+}
+
 // Initialize a template.FuncMap object and store it in global variable. This is essentially a string-keyed
 // map which acts as a lookup between the names of our custom template functions and the functions themselves.
 var functions = template.FuncMap{
-	"humanDate": humanDate,
+	"humanDate":   humanDate,
+	"diffClass":   diffClass,
+	"diffSign":    diffSign,
+	"headline":    headline,
+	"add":         add,
+	"highlight":   highlightLines,
+	"languages":   func() []highlight.Language { return highlight.Languages },
+	"language":    highlight.Label,
+	"syntaxError": syntaxError,
+	"percent":     percent,
+	"markdown":    renderMarkdown,
+	"pathEscape":  url.PathEscape,
+	"scopes":      func() []string { return models.Scopes },
+	"contains":    hasScope,
+	"expired":     expired,
+	"chatPath":    chatPath,
 }
 
 func newTemplateCache(dir string) (map[string]*template.Template, error) {
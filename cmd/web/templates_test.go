@@ -44,3 +44,33 @@ func TestHumanDate(t *testing.T) {
 		})
 	}
 }
+
+func TestSyntaxError(t *testing.T) {
+	tests := []struct {
+		name     string
+		language string
+		src      string
+		want     string
+	}{
+		{"Valid Go", "go", "x := 1", ""},
+		{"Invalid Go", "go", "x := (1", "1:8: expected ')', found newline"},
+		{"Not Go", "python", "x := (1", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := syntaxError(tt.language, tt.src)
+			if got != tt.want {
+				t.Errorf("want %q; got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRenderMarkdown(t *testing.T) {
+	got := string(renderMarkdown("# Runbook\n\n[click](javascript:alert(1)) <script>x</script>"))
+	want := "<h1>Runbook</h1>\n<p><a rel=\"nofollow noopener\">click</a> &lt;script&gt;x&lt;/script&gt;</p>\n"
+	if got != want {
+		t.Errorf("want %q; got %q", want, got)
+	}
+}
@@ -44,11 +44,22 @@ func newTestApplication(t *testing.T) *application {
 	session.Secure = true
 
 	// Initialize the dependencies, using the mocks for the logger and database models.
+	errorLog := log.New(ioutil.Discard, "", 0)
+	infoLog := log.New(ioutil.Discard, "", 0)
+	snippets := &mock.SnippetModel{}
 	return &application{
-		errorLog:      log.New(ioutil.Discard, "", 0),
-		infoLog:       log.New(ioutil.Discard, "", 0),
+		errorLog:      errorLog,
+		infoLog:       infoLog,
 		session:       session,
-		snippets:      &mock.SnippetModel{},
+		snippets:      snippets,
+		expiryLimits:  &mock.ExpiryLimitModel{},
+		messages:      &mock.MessageModel{},
+		rooms:         &mock.RoomModel{},
+		tags:          &mock.TagModel{},
+		tokens:        &mock.TokenModel{},
+		reaper:        &reaper{snippets: snippets, errorLog: errorLog, infoLog: infoLog, batchSize: 100},
+		chat:          newHub(),
+		events:        newEventBroker(),
 		templateCache: templateCache,
 		users:         &mock.UserModel{},
 	}
@@ -118,3 +129,22 @@ func (ts *testServer) get(t *testing.T, urlPath string) (int, http.Header, []byt
 
 	return rs.StatusCode, rs.Header, body
 }
+
+// The login method signs in to the test server as the mock user with the given email address (Alice
+// is alice@example.com, and the administrator Dave admin@example.com), and returns a CSRF token to
+// post forms with afterwards. The session cookie is kept in the client`s cookie jar.
+func (ts *testServer) login(t *testing.T, email string) string {
+	_, _, body := ts.get(t, "/user/login")
+	form := url.Values{}
+	form.Add("csrf_token", extractSCRFToken(t, body))
+	form.Add("email", email)
+	form.Add("password", "pa$$word")
+	code, _, _ := ts.postForm(t, "/user/login", form)
+	if code != http.StatusSeeOther {
+		t.Fatalf("want %d logging in; got %d", http.StatusSeeOther, code)
+	}
+
+	// The CSRF token is renewed when the user signs in.
+	_, _, body = ts.get(t, "/snippet/create")
+	return extractSCRFToken(t, body)
+}
@@ -1,6 +1,6 @@
 module sabiraliyev.net/snippetbox
 
-go 1.15
+go 1.20
 
 require (
 	github.com/bmizerany/pat v0.0.0-20170815010413-6226ea591a40
@@ -0,0 +1,4 @@
+-- Record which user created each snippet. Snippets created before ownership
+-- existed keep a NULL owner and only show up for administrators.
+ALTER TABLE snippets ADD COLUMN user_id INTEGER REFERENCES users(id);
+CREATE INDEX idx_snippets_user_id ON snippets(user_id);
@@ -0,0 +1,3 @@
+-- Deleting a snippet only flags it, so the owner can restore it from the trash.
+ALTER TABLE snippets ADD COLUMN IF NOT EXISTS deleted BOOLEAN NOT NULL DEFAULT FALSE;
+CREATE INDEX idx_snippets_deleted ON snippets(user_id) WHERE deleted = TRUE;
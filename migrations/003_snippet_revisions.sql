@@ -0,0 +1,15 @@
+-- Every save of a snippet is kept as an immutable, numbered revision.
+CREATE TABLE snippet_revisions (
+    id SERIAL PRIMARY KEY,
+    snippet_id INTEGER NOT NULL REFERENCES snippets(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    user_id INTEGER REFERENCES users(id),
+    title VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    created TIMESTAMP NOT NULL,
+    CONSTRAINT snippet_revisions_uc_version UNIQUE (snippet_id, version)
+);
+
+-- Existing snippets start out with their current state as revision 1.
+INSERT INTO snippet_revisions (snippet_id, version, user_id, title, content, created)
+SELECT id, 1, user_id, title, content, created FROM snippets;
@@ -0,0 +1,5 @@
+-- Public snippets are listed, unlisted ones are reachable by link only and private ones are
+-- restricted to their owner and administrators.
+ALTER TABLE snippets ADD COLUMN visibility VARCHAR(10) NOT NULL DEFAULT 'public'
+    CONSTRAINT snippets_ck_visibility CHECK (visibility IN ('public', 'unlisted', 'private'));
+CREATE INDEX idx_snippets_public_created ON snippets(created) WHERE visibility = 'public';
@@ -0,0 +1,6 @@
+-- Snippets are addressed by a random slug instead of their sequential ID. The application generates
+-- slugs with crypto/rand; existing snippets, which were all public, are given a random hex slug.
+ALTER TABLE snippets ADD COLUMN slug VARCHAR(16);
+UPDATE snippets SET slug = substr(md5(random()::text || id::text), 1, 10) WHERE slug IS NULL;
+ALTER TABLE snippets ALTER COLUMN slug SET NOT NULL;
+ALTER TABLE snippets ADD CONSTRAINT snippets_uc_slug UNIQUE (slug);
@@ -0,0 +1,3 @@
+-- Snippets can be limited to a number of views; max_views = 0 means there is no limit.
+ALTER TABLE snippets ADD COLUMN max_views INTEGER NOT NULL DEFAULT 0 CONSTRAINT snippets_ck_max_views CHECK (max_views >= 0);
+ALTER TABLE snippets ADD COLUMN views INTEGER NOT NULL DEFAULT 0;
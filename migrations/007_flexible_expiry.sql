@@ -0,0 +1,10 @@
+-- Snippets which never expire have a NULL expiry time.
+ALTER TABLE snippets ALTER COLUMN expires DROP NOT NULL;
+
+-- The longest time a snippet created by a user with each role may live for, in seconds.
+-- Zero means there is no limit, so the role may create snippets which never expire.
+CREATE TABLE expiry_limits (
+    role VARCHAR(20) PRIMARY KEY,
+    max_seconds BIGINT NOT NULL CONSTRAINT expiry_limits_ck_max_seconds CHECK (max_seconds >= 0)
+);
+INSERT INTO expiry_limits (role, max_seconds) VALUES ('user', 365 * 24 * 60 * 60), ('administrator', 0);
@@ -0,0 +1,2 @@
+-- The reaper looks for snippets which expired a while ago, so it needs an index on the expiry time.
+CREATE INDEX snippets_idx_expires ON snippets (expires);
@@ -0,0 +1,4 @@
+-- Keyset pagination walks these indexes in order for each of the listing sort orders.
+CREATE INDEX snippets_idx_created_id ON snippets (created, id);
+CREATE INDEX snippets_idx_title_id ON snippets (title, id);
+CREATE INDEX snippets_idx_expires_id ON snippets (COALESCE(expires, 'infinity'::timestamp), id);
@@ -0,0 +1,6 @@
+-- Full-text search document of each snippet, kept up to date by PostgreSQL. Matches in the title
+-- rank above matches in the content.
+ALTER TABLE snippets ADD COLUMN search tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', title), 'A') || setweight(to_tsvector('english', content), 'B')
+) STORED;
+CREATE INDEX snippets_idx_search ON snippets USING GIN (search);
@@ -0,0 +1,13 @@
+-- Tags are shared between snippets, which can each have any number of them.
+CREATE TABLE tags (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(32) NOT NULL,
+    CONSTRAINT tags_uc_name UNIQUE (name)
+);
+
+CREATE TABLE snippet_tags (
+    snippet_id INTEGER NOT NULL REFERENCES snippets(id) ON DELETE CASCADE,
+    tag_id INTEGER NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    PRIMARY KEY (snippet_id, tag_id)
+);
+CREATE INDEX snippet_tags_idx_tag_id ON snippet_tags (tag_id);
@@ -0,0 +1,2 @@
+-- The language a snippet is highlighted as. The empty string means plain text.
+ALTER TABLE snippets ADD COLUMN language VARCHAR(20) NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+-- How sure we are of a snippet`s language: 1 when the author chose it, lower when it was guessed.
+ALTER TABLE snippets ADD COLUMN language_confidence REAL NOT NULL DEFAULT 1;
@@ -0,0 +1,26 @@
+-- A snippet can be a bundle of named files. The snippet`s own content is its first file, named by
+-- the filename column (empty if the author didn`t name it), and any further files are kept in order
+-- in snippet_files.
+ALTER TABLE snippets ADD COLUMN filename VARCHAR(100) NOT NULL DEFAULT '';
+
+CREATE TABLE snippet_files (
+    snippet_id INTEGER NOT NULL REFERENCES snippets(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    language VARCHAR(20) NOT NULL DEFAULT '',
+    content TEXT NOT NULL,
+    PRIMARY KEY (snippet_id, position),
+    CONSTRAINT snippet_files_uc_name UNIQUE (snippet_id, name)
+);
+
+-- Revisions keep every file, so that history shows what each save looked like as a whole.
+ALTER TABLE snippet_revisions ADD COLUMN filename VARCHAR(100) NOT NULL DEFAULT '';
+
+CREATE TABLE snippet_revision_files (
+    revision_id INTEGER NOT NULL REFERENCES snippet_revisions(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    language VARCHAR(20) NOT NULL DEFAULT '',
+    content TEXT NOT NULL,
+    PRIMARY KEY (revision_id, position)
+);
@@ -0,0 +1,15 @@
+-- Personal API tokens let scripts act on behalf of a user without a session cookie. Only the SHA-256
+-- hash of each token is kept, so the tokens themselves can`t be read back out of the database.
+-- Tokens are limited to the scopes they were granted, can expire, and record when they were last used.
+CREATE TABLE api_tokens (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    hash BYTEA NOT NULL,
+    scopes TEXT[] NOT NULL,
+    created TIMESTAMP NOT NULL,
+    expires TIMESTAMP,
+    last_used TIMESTAMP,
+    CONSTRAINT api_tokens_uc_hash UNIQUE (hash)
+);
+CREATE INDEX api_tokens_idx_user_id ON api_tokens (user_id);
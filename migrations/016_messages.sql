@@ -0,0 +1,8 @@
+-- Messages posted to the chat. Pages of older messages are fetched by ID, so the primary key is the
+-- only index needed.
+CREATE TABLE messages (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    content TEXT NOT NULL,
+    created TIMESTAMP NOT NULL
+);
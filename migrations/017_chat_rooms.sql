@@ -0,0 +1,34 @@
+-- Chat rooms. Public rooms can be joined by anyone, private rooms only by invitation, and direct
+-- rooms hold the conversation between two users. Direct rooms have no name, and are found by their
+-- direct_key, the IDs of the two users in ascending order, so each pair of users only ever has one.
+CREATE TABLE rooms (
+    id SERIAL PRIMARY KEY,
+    kind VARCHAR(10) NOT NULL CHECK (kind IN ('public', 'private', 'direct')),
+    name VARCHAR(50),
+    direct_key VARCHAR(30),
+    created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created TIMESTAMP NOT NULL,
+    CONSTRAINT rooms_uc_direct_key UNIQUE (direct_key)
+);
+
+-- The members of each room. last_read is the ID of the newest message the member has seen, so the
+-- messages after it are the ones they haven`t read.
+CREATE TABLE room_members (
+    room_id INTEGER NOT NULL REFERENCES rooms(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    last_read INTEGER NOT NULL DEFAULT 0,
+    joined TIMESTAMP NOT NULL,
+    PRIMARY KEY (room_id, user_id)
+);
+CREATE INDEX room_members_idx_user_id ON room_members (user_id);
+
+-- The messages posted so far were all in the one chat, which becomes the "general" room. Everybody
+-- is a member of it, and has read what is already there.
+INSERT INTO rooms (id, kind, name, created) VALUES (1, 'public', 'general', NOW());
+SELECT setval('rooms_id_seq', 1);
+INSERT INTO room_members (room_id, user_id, last_read, joined)
+    SELECT 1, id, (SELECT COALESCE(MAX(id), 0) FROM messages), NOW() FROM users;
+
+ALTER TABLE messages ADD COLUMN room_id INTEGER NOT NULL DEFAULT 1 REFERENCES rooms(id) ON DELETE CASCADE;
+ALTER TABLE messages ALTER COLUMN room_id DROP DEFAULT;
+CREATE INDEX messages_idx_room_id ON messages (room_id, id);
@@ -0,0 +1,321 @@
+// Package diff computes line-based differences between two texts using Myers` O(ND) algorithm,
+// and formats them as unified diffs or side-by-side rows.
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Op identifies what happened to a line between the old and the new text.
+type Op int
+
+const (
+	Equal Op = iota
+	Delete
+	Insert
+)
+
+// A Line is one line of a diff. Old and New are the 1-based line numbers in the old and new
+// texts; a deleted line has no New number and an inserted line has no Old number (both are 0).
+type Line struct {
+	Op   Op
+	Text string
+	Old  int
+	New  int
+}
+
+// A Hunk is a run of changed lines together with the unchanged lines surrounding them.
+type Hunk struct {
+	OldStart, OldLines int
+	NewStart, NewLines int
+	Lines              []Line
+}
+
+// Header returns the "@@ -l,s +l,s @@" line which introduces the hunk in a unified diff.
+func (h Hunk) Header() string {
+	return fmt.Sprintf("@@ -%d,%d +%d,%d @@", h.OldStart, h.OldLines, h.NewStart, h.NewLines)
+}
+
+// A Row is one row of a side-by-side diff. Either side may be nil when a line only exists in
+// one of the two texts.
+type Row struct {
+	Left, Right *Line
+}
+
+// Split breaks a text into lines. Windows line endings are normalised and a single trailing
+// newline doesn`t produce an extra empty line.
+func Split(text string) []string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.TrimSuffix(text, "\n")
+	if text == "" {
+		return []string{}
+	}
+	return strings.Split(text, "\n")
+}
+
+// Lines returns the line-by-line difference between the old and new texts.
+func Lines(old, new string) []Line {
+	return Compute(Split(old), Split(new))
+}
+
+// Compute returns the shortest edit script turning a into b, as a sequence of equal, deleted
+// and inserted lines.
+func Compute(a, b []string) []Line {
+	// Lines shared at the start and the end of both texts are trimmed before running the
+	// algorithm. Typical edits touch a small part of a snippet, so this keeps the search small.
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	lines := make([]Line, 0, len(a)+len(b))
+	for i := 0; i < prefix; i++ {
+		lines = append(lines, Line{Op: Equal, Text: a[i], Old: i + 1, New: i + 1})
+	}
+	for _, l := range myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]) {
+		if l.Old > 0 {
+			l.Old += prefix
+		}
+		if l.New > 0 {
+			l.New += prefix
+		}
+		lines = append(lines, l)
+	}
+	for i := suffix; i > 0; i-- {
+		lines = append(lines, Line{Op: Equal, Text: a[len(a)-i], Old: len(a) - i + 1, New: len(b) - i + 1})
+	}
+	return lines
+}
+
+// The myers function finds the shortest edit script with the greedy algorithm from Eugene Myers`
+// "An O(ND) Difference Algorithm and Its Variations". For every edit distance d it records the
+// furthest reaching path on each diagonal k, then walks those records backwards from the end of
+// both texts to recover the edits.
+func myers(a, b []string) []Line {
+	n, m := len(a), len(b)
+	max := n + m
+	if max == 0 {
+		return nil
+	}
+
+	// v[k+max] holds the furthest x reached on diagonal k. The trace keeps a copy of the
+	// diagonals -d..d as they were before each round d.
+	v := make([]int, 2*max+2)
+	var trace [][]int
+
+search:
+	for d := 0; d <= max; d++ {
+		trace = append(trace, append([]int(nil), v[max-d:max+d+2]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[max+k-1] < v[max+k+1]) {
+				x = v[max+k+1]
+			} else {
+				x = v[max+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[max+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	// Walk back from (n, m), collecting the edits in reverse.
+	var reversed []Line
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		// The trace for round d starts at diagonal -d, so diagonal k lives at index k+d.
+		prev := func(k int) int { return trace[d][k+d] }
+
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && prev(k-1) < prev(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := 0
+		if d > 0 {
+			prevX = prev(prevK)
+		}
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			reversed = append(reversed, Line{Op: Equal, Text: a[x-1], Old: x, New: y})
+			x--
+			y--
+		}
+		if d > 0 {
+			if x == prevX {
+				reversed = append(reversed, Line{Op: Insert, Text: b[y-1], New: y})
+			} else {
+				reversed = append(reversed, Line{Op: Delete, Text: a[x-1], Old: x})
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	lines := make([]Line, len(reversed))
+	for i, l := range reversed {
+		lines[len(reversed)-1-i] = l
+	}
+	return lines
+}
+
+// Changed reports whether a diff contains any inserted or deleted lines.
+func Changed(lines []Line) bool {
+	for _, l := range lines {
+		if l.Op != Equal {
+			return true
+		}
+	}
+	return false
+}
+
+// Hunks groups the changes of a diff into hunks, keeping up to context unchanged lines around
+// every change. Changes separated by no more than 2*context unchanged lines share a hunk.
+func Hunks(lines []Line, context int) []Hunk {
+	var hunks []Hunk
+	for i := 0; i < len(lines); {
+		if lines[i].Op == Equal {
+			i++
+			continue
+		}
+
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+		// Extend the hunk until we find a run of more than 2*context unchanged lines,
+		// or reach the end of the diff.
+		end := i
+		for end < len(lines) {
+			if lines[end].Op != Equal {
+				end++
+				continue
+			}
+			run := end
+			for run < len(lines) && lines[run].Op == Equal {
+				run++
+			}
+			if run == len(lines) || run-end > 2*context {
+				end += min(context, run-end)
+				break
+			}
+			end = run
+		}
+
+		hunks = append(hunks, newHunk(lines, start, end))
+		i = end
+	}
+	return hunks
+}
+
+func newHunk(lines []Line, start, end int) Hunk {
+	h := Hunk{Lines: lines[start:end]}
+	for _, l := range h.Lines {
+		if l.Op != Insert {
+			h.OldLines++
+			if h.OldStart == 0 {
+				h.OldStart = l.Old
+			}
+		}
+		if l.Op != Delete {
+			h.NewLines++
+			if h.NewStart == 0 {
+				h.NewStart = l.New
+			}
+		}
+	}
+	// A hunk which only adds (or only removes) lines is anchored after the last line
+	// preceding it, which is line 0 at the start of the text.
+	if h.OldLines == 0 {
+		h.OldStart = lastLine(lines[:start], func(l Line) int { return l.Old })
+	}
+	if h.NewLines == 0 {
+		h.NewStart = lastLine(lines[:start], func(l Line) int { return l.New })
+	}
+	return h
+}
+
+func lastLine(lines []Line, number func(Line) int) int {
+	for i := len(lines) - 1; i >= 0; i-- {
+		if n := number(lines[i]); n > 0 {
+			return n
+		}
+	}
+	return 0
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// Unified formats a diff in the unified format understood by patch(1) and git apply.
+func Unified(oldName, newName string, lines []Line, context int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", oldName, newName)
+	for _, h := range Hunks(lines, context) {
+		b.WriteString(h.Header())
+		b.WriteByte('\n')
+		for _, l := range h.Lines {
+			switch l.Op {
+			case Equal:
+				b.WriteByte(' ')
+			case Delete:
+				b.WriteByte('-')
+			case Insert:
+				b.WriteByte('+')
+			}
+			b.WriteString(l.Text)
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
+
+// SideBySide pairs the lines of a diff into rows. Deleted lines are shown next to the lines
+// which replaced them.
+func SideBySide(lines []Line) []Row {
+	var rows []Row
+	for i := 0; i < len(lines); {
+		if lines[i].Op == Equal {
+			rows = append(rows, Row{Left: &lines[i], Right: &lines[i]})
+			i++
+			continue
+		}
+
+		var deleted, inserted []*Line
+		for ; i < len(lines) && lines[i].Op != Equal; i++ {
+			if lines[i].Op == Delete {
+				deleted = append(deleted, &lines[i])
+			} else {
+				inserted = append(inserted, &lines[i])
+			}
+		}
+		for j := 0; j < len(deleted) || j < len(inserted); j++ {
+			var row Row
+			if j < len(deleted) {
+				row.Left = deleted[j]
+			}
+			if j < len(inserted) {
+				row.Right = inserted[j]
+			}
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
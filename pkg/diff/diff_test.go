@@ -0,0 +1,105 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name string
+		old  string
+		new  string
+		want string
+	}{
+		{"Identical", "a\nb\nc", "a\nb\nc", " a| b| c"},
+		{"Empty to text", "", "a\nb", "+a|+b"},
+		{"Text to empty", "a\nb", "", "-a|-b"},
+		{"Changed middle", "a\nb\nc", "a\nx\nc", " a|-b|+x| c"},
+		{"Inserted line", "a\nc", "a\nb\nc", " a|+b| c"},
+		{"Deleted line", "a\nb\nc", "a\nc", " a|-b| c"},
+		{"Trailing newline", "a\nb\n", "a\nb", " a| b"},
+		{"CRLF", "a\r\nb", "a\nb", " a| b"},
+		{"Myers example", "A\nB\nC\nA\nB\nB\nA", "C\nB\nA\nB\nA\nC", "-A|-B| C|+B| A| B|-B| A|+C"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, l := range Lines(tt.old, tt.new) {
+				got = append(got, [...]string{" ", "-", "+"}[l.Op]+l.Text)
+			}
+			if strings.Join(got, "|") != tt.want {
+				t.Errorf("want %q; got %q", tt.want, strings.Join(got, "|"))
+			}
+		})
+	}
+}
+
+func TestLineNumbers(t *testing.T) {
+	lines := Lines("a\nb\nc\nd", "a\nc\nd\ne")
+
+	want := []Line{
+		{Equal, "a", 1, 1},
+		{Delete, "b", 2, 0},
+		{Equal, "c", 3, 2},
+		{Equal, "d", 4, 3},
+		{Insert, "e", 0, 4},
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("want %d lines; got %d", len(want), len(lines))
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Errorf("line %d: want %+v; got %+v", i, want[i], lines[i])
+		}
+	}
+}
+
+func TestUnified(t *testing.T) {
+	old := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12"
+	new := "1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13"
+
+	want := `--- a
++++ b
+@@ -1,6 +1,6 @@
+ 1
+ 2
+-3
++three
+ 4
+ 5
+ 6
+@@ -10,3 +10,4 @@
+ 10
+ 11
+ 12
++13
+`
+	got := Unified("a", "b", Lines(old, new), 3)
+	if got != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestUnifiedInsertAtStart(t *testing.T) {
+	got := Unified("a", "b", Lines("x", "new\nx"), 0)
+	want := "--- a\n+++ b\n@@ -0,0 +1,1 @@\n+new\n"
+	if got != want {
+		t.Errorf("want %q; got %q", want, got)
+	}
+}
+
+func TestSideBySide(t *testing.T) {
+	rows := SideBySide(Lines("a\nb\nc", "a\nx\ny\nc"))
+
+	if len(rows) != 4 {
+		t.Fatalf("want 4 rows; got %d", len(rows))
+	}
+	if rows[1].Left == nil || rows[1].Left.Text != "b" || rows[1].Right == nil || rows[1].Right.Text != "x" {
+		t.Errorf("want b replaced by x; got %+v", rows[1])
+	}
+	if rows[2].Left != nil || rows[2].Right == nil || rows[2].Right.Text != "y" {
+		t.Errorf("want y on the right only; got %+v", rows[2])
+	}
+}
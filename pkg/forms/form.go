@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"net/url"
 	"regexp"
+	"strconv"
 	"strings"
+	"unicode"
 	"unicode/utf8"
 )
 
@@ -15,6 +17,10 @@ import (
 // with every request.
 var EmailRX = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+\\/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
 
+// The TagRX regular expression matches a valid tag: up to 32 lower case letters, digits and dashes,
+// starting with a letter or digit.
+var TagRX = regexp.MustCompile("^[a-z0-9][a-z0-9-]{0,31}$")
+
 // Custom form struct, which anonymously embeds a urlValues object (to hold the form data)
 // and an Errors field to hold any validation errors for the form data.
 type Form struct {
@@ -65,6 +71,7 @@ func (f *Form) PermittedValues(field string, opts ...string) {
 			return
 		}
 	}
+	f.Errors.Add(field, "This field is invalid")
 }
 
 // Check that specific field in the form contains a minimum number of characters. If the check fails
@@ -79,6 +86,19 @@ func (f *Form) MinLength(field string, d int) {
 	}
 }
 
+// Check that a specific field in the form contains a whole number between min and max (inclusive).
+// If the check fails then add the appropriate message to the form errors.
+func (f *Form) IntRange(field string, min, max int) {
+	value := f.Get(field)
+	if value == "" {
+		return
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < min || n > max {
+		f.Errors.Add(field, fmt.Sprintf("This field must be a number between %d and %d", min, max))
+	}
+}
+
 // Check that a specific field in the form matches a regular expression. If the check fails
 // then add the appropriate message to form errors.
 func (f *Form) MatchesPattern(field string, pattern *regexp.Regexp) {
@@ -91,6 +111,34 @@ func (f *Form) MatchesPattern(field string, pattern *regexp.Regexp) {
 	}
 }
 
+// Split a field holding a list of words, separated by commas and/or spaces, dropping any duplicates.
+// Check that there are no more than max words and that each of them matches a regular expression.
+// If the check fails then add the appropriate message to the form errors.
+func (f *Form) List(field string, max int, pattern *regexp.Regexp) []string {
+	words := []string{}
+	seen := map[string]bool{}
+	for _, word := range strings.FieldsFunc(f.Get(field), func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	}) {
+		if !seen[word] {
+			seen[word] = true
+			words = append(words, word)
+		}
+	}
+
+	if len(words) > max {
+		f.Errors.Add(field, fmt.Sprintf("This field has too many entries (maximum is %d)", max))
+		return words
+	}
+	for _, word := range words {
+		if !pattern.MatchString(word) {
+			f.Errors.Add(field, fmt.Sprintf("%q is invalid", word))
+			return words
+		}
+	}
+	return words
+}
+
 // Method which returns true if there are no errors.
 func (f *Form) Valid() bool {
 	return len(f.Errors) == 0
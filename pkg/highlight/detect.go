@@ -0,0 +1,175 @@
+package highlight
+
+import (
+	"encoding/json"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// The extensions map holds the file extensions which give away the language of a snippet
+// whose title is a file name.
+var extensions = map[string]string{
+	".go":   "go",
+	".js":   "javascript",
+	".mjs":  "javascript",
+	".cjs":  "javascript",
+	".json": "json",
+	".md":   "markdown",
+	".py":   "python",
+	".sh":   "shell",
+	".bash": "shell",
+	".zsh":  "shell",
+	".sql":  "sql",
+	".yaml": "yaml",
+	".yml":  "yaml",
+}
+
+// The filenames map holds the names of files, without an extension, which give away their language.
+var filenames = map[string]string{
+	"dockerfile":    "dockerfile",
+	"containerfile": "dockerfile",
+}
+
+// The interpreters map holds the programs named by shebang lines, and the languages they run.
+var interpreters = map[string]string{
+	"sh":      "shell",
+	"bash":    "shell",
+	"zsh":     "shell",
+	"dash":    "shell",
+	"ksh":     "shell",
+	"python":  "python",
+	"python2": "python",
+	"python3": "python",
+	"node":    "javascript",
+	"nodejs":  "javascript",
+}
+
+// A clue is a pattern which suggests code is written in a language. The more clues match, and the
+// heavier they are, the more likely the language.
+type clue struct {
+	language string
+	weight   float64
+	pattern  *regexp.Regexp
+}
+
+func newClue(language string, weight float64, pattern string) clue {
+	return clue{language, weight, regexp.MustCompile(pattern)}
+}
+
+var clues = []clue{
+	newClue("go", 3, `(?m)^package \w+$`),
+	newClue("go", 2, `(?m)^func (\(\w+ \*?\w+\) )?\w+\(`),
+	newClue("go", 1, `:= `),
+	newClue("go", 1, `\bfmt\.\w+\(`),
+	newClue("go", 1, `(?m)^import \($`),
+	newClue("go", 1, `\berr != nil\b`),
+	newClue("go", 1, `\b(chan|struct|interface) ?\{`),
+
+	newClue("python", 3, `(?m)^\s*def \w+\(.*\):\s*$`),
+	newClue("python", 2, `(?m)^\s*class \w+(\(.*\))?:\s*$`),
+	newClue("python", 2, `(?m)^\s*(from [\w.]+ )?import [\w.]+( as \w+)?$`),
+	newClue("python", 1, `\bself\.`),
+	newClue("python", 1, `(?m)^\s*(elif|except|with) .*:\s*$`),
+	newClue("python", 1, `\bprint\(`),
+	newClue("python", 1, `\b(True|False|None)\b`),
+
+	newClue("javascript", 2, `\bfunction\s*\w*\s*\(`),
+	newClue("javascript", 2, `\b(const|let) \w+ = `),
+	newClue("javascript", 1, `=> `),
+	newClue("javascript", 2, `\bconsole\.log\(`),
+	newClue("javascript", 1, `\b(require|import)\(`),
+	newClue("javascript", 1, `;\s*$`),
+	newClue("javascript", 1, `===`),
+
+	newClue("sql", 3, `(?is)\bSELECT\b.+\bFROM\b`),
+	newClue("sql", 3, `(?i)\b(CREATE|ALTER|DROP) (TABLE|INDEX|VIEW)\b`),
+	newClue("sql", 3, `(?i)\bINSERT INTO\b`),
+	newClue("sql", 2, `(?i)\bUPDATE \w+ SET\b`),
+	newClue("sql", 1, `(?i)\b(WHERE|JOIN|GROUP BY|ORDER BY)\b`),
+
+	newClue("markdown", 1, `(?m)^#{1,6} \S`),
+	newClue("markdown", 2, "(?m)^```"),
+	newClue("markdown", 2, `\[[^\]\n]+\]\([^)\s]+\)`),
+	newClue("markdown", 1, `\*\*\S[^*\n]*\*\*`),
+
+	newClue("dockerfile", 3, `(?m)^FROM ([\w.-]+[:/@][\w./:@-]+|scratch)( [aA][sS] \w+)?\s*$`),
+	newClue("dockerfile", 2, `(?m)^(RUN|CMD|ENTRYPOINT|WORKDIR|EXPOSE|COPY) `),
+
+	newClue("yaml", 2, `(?m)^---\s*$`),
+	newClue("yaml", 1, `(?m)^[\w.-]+:( |$)`),
+	newClue("yaml", 1, `(?m)^\s+[\w.-]+: \S`),
+	newClue("yaml", 1, `(?m)^\s*- [\w.-]+:? `),
+
+	newClue("shell", 2, `(?m)^\s*(echo|export|cd|sudo|apt-get|apt|yum|brew|curl|wget|chmod|mkdir) `),
+	newClue("shell", 1, `\$\{?\w+\}?`),
+	newClue("shell", 2, `(?m)^\s*(fi|done|esac)\s*$`),
+	newClue("shell", 1, `(?m)^\s*if \[\[? `),
+	newClue("shell", 1, ` (&&|\|\|) `),
+	newClue("shell", 1, `(?m)^\$ `),
+}
+
+// The minScore constant is the total weight of clues a language needs before it is guessed.
+const minScore = 2
+
+// Detect guesses the language of a snippet from its title and content, and returns the name of the
+// language with a confidence between 0 and 1. A shebang line, or a file name or extension in the title, settle
+// the question; otherwise the language whose clues match best wins, with a confidence reflecting how
+// clear the win was. If nothing is recognised, Detect returns the empty string (plain text) and 0.
+func Detect(title, content string) (string, float64) {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+
+	if strings.HasPrefix(content, "#!") {
+		line := strings.SplitN(content, "\n", 2)[0]
+		fields := strings.Fields(strings.TrimPrefix(line, "#!"))
+		// Look past "/usr/bin/env" to the program it runs.
+		if len(fields) > 1 && path.Base(fields[0]) == "env" {
+			fields = fields[1:]
+		}
+		if len(fields) > 0 {
+			if language, ok := interpreters[path.Base(fields[0])]; ok {
+				return language, 1
+			}
+		}
+	}
+
+	name := strings.ToLower(path.Base(strings.TrimSpace(title)))
+	if language, ok := filenames[name]; ok {
+		return language, 0.9
+	}
+	if language, ok := extensions[path.Ext(name)]; ok {
+		return language, 0.9
+	}
+
+	trimmed := strings.TrimSpace(content)
+	if (strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[")) && json.Valid([]byte(trimmed)) {
+		return "json", 0.95
+	}
+
+	scores := map[string]float64{}
+	total := 0.0
+	for _, c := range clues {
+		if c.pattern.MatchString(content) {
+			scores[c.language] += c.weight
+			total += c.weight
+		}
+	}
+
+	best, bestScore := "", 0.0
+	for _, l := range Languages {
+		if scores[l.Name] > bestScore {
+			best, bestScore = l.Name, scores[l.Name]
+		}
+	}
+	if bestScore < minScore {
+		return "", 0
+	}
+
+	// Content matching only one language`s clues is a safer bet than content matching several,
+	// and more evidence makes any guess safer. A guess from clues alone is never certain.
+	confidence := bestScore / total * bestScore / (bestScore + 2)
+	if confidence > 0.85 {
+		confidence = 0.85
+	}
+	return best, confidence
+}
@@ -0,0 +1,69 @@
+package highlight
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name    string
+		title   string
+		content string
+		want    string
+		minConf float64
+		maxConf float64
+	}{
+		{"Shebang", "deploy", "#!/usr/bin/env bash\nset -e\n", "shell", 1, 1},
+		{"Python shebang", "tool", "#!/usr/bin/python3\nprint(1)", "python", 1, 1},
+		{"Extension", "main.go", "x", "go", 0.9, 0.9},
+		{"File name", "Dockerfile", "x", "dockerfile", 0.9, 0.9},
+		{"Dockerfile", "image", "FROM golang:1.20 AS build\nWORKDIR /src\nRUN go build ./cmd/web\n", "dockerfile", 0.4, 0.85},
+		{"JSON", "config", `{"port": 4000, "debug": true}`, "json", 0.95, 0.95},
+		{"Go", "handler", "package main\n\nfunc main() {\n\tx := 1\n\tfmt.Println(x)\n}\n", "go", 0.5, 0.85},
+		{"Python", "script", "import os\n\ndef main():\n    if True:\n        print(os.name)\n", "python", 0.4, 0.85},
+		{"SQL", "query", "SELECT id, title\nFROM snippets\nWHERE deleted = FALSE\nORDER BY id;", "sql", 0.4, 0.85},
+		{"YAML", "deployment", "---\napiVersion: v1\nkind: Pod\nmetadata:\n  name: web\n", "yaml", 0.4, 0.85},
+		{"JavaScript", "app", "const x = require('x');\nfunction f(a) {\n  console.log(a === 1);\n}\n", "javascript", 0.4, 0.85},
+		{"Shell", "setup", "export PATH=$HOME/bin\nsudo apt-get install git\nif [ -f x ]; then\n  echo ok\nfi\n", "shell", 0.4, 0.85},
+		{"Prose", "shopping", "Milk, eggs and bread.", "", 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, conf := Detect(tt.title, tt.content)
+			if got != tt.want {
+				t.Errorf("want %q; got %q", tt.want, got)
+			}
+			if conf < tt.minConf || conf > tt.maxConf {
+				t.Errorf("want confidence between %v and %v; got %v", tt.minConf, tt.maxConf, conf)
+			}
+		})
+	}
+}
+
+func TestCheckGo(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		wantErr string
+	}{
+		{"File", "package main\n\nfunc main() {}\n", ""},
+		{"Declarations", "func add(a, b int) int {\n\treturn a + b\n}\n", ""},
+		{"Statements", "x := 1\nfmt.Println(x)\n", ""},
+		{"Broken file", "package main\n\nfunc main() {\n\tx :=\n}\n", "5:1:"},
+		{"Broken statements", "x := (1\ny := 2\n", "1:8:"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckGo(tt.src)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("want no error; got %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.HasPrefix(err.Error(), tt.wantErr)):
+				t.Errorf("want error starting %q; got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package highlight
+
+import (
+	"errors"
+	"fmt"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"strings"
+)
+
+// The goWrappers are put in front of Go code without a package clause, so that fragments of a file
+// (a few declarations) or of a function body (a few statements) can be parsed too. They don`t contain
+// a newline, so the line numbers of any errors stay right.
+var goWrappers = []struct {
+	prefix, suffix string
+}{
+	{"package snippet; ", ""},
+	{"package snippet; func _() { ", "\n}"},
+}
+
+// CheckGo parses Go code with go/parser and returns the first syntax error, or nil if the code
+// parses. Code without a package clause is accepted if it parses as a list of declarations or as
+// a list of statements. The error message starts with the line and column of the problem.
+func CheckGo(src string) error {
+	if strings.HasPrefix(strings.TrimSpace(src), "package ") {
+		return parseGo(src, 0)
+	}
+
+	// If the code doesn`t parse either way, report the error from the attempt which got furthest,
+	// as that is the one most likely to point at the actual mistake.
+	var furthest error
+	var furthestPos token.Position
+	for _, w := range goWrappers {
+		err := parseGo(w.prefix+src+w.suffix, len(w.prefix))
+		if err == nil {
+			return nil
+		}
+		var e *goSyntaxError
+		if errors.As(err, &e) && (furthest == nil || e.pos.Offset > furthestPos.Offset) {
+			furthest, furthestPos = err, e.pos
+		}
+	}
+	return furthest
+}
+
+// The goSyntaxError type is a syntax error found by CheckGo.
+type goSyntaxError struct {
+	pos token.Position
+	msg string
+}
+
+func (e *goSyntaxError) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.pos.Line, e.pos.Column, e.msg)
+}
+
+// The parseGo function parses a Go file whose first line starts with prefixLen bytes which aren`t
+// part of the user`s code, and converts the first error into a goSyntaxError with positions in
+// terms of the user`s code.
+func parseGo(src string, prefixLen int) error {
+	_, err := parser.ParseFile(token.NewFileSet(), "", src, parser.AllErrors)
+	if err == nil {
+		return nil
+	}
+
+	var list scanner.ErrorList
+	if !errors.As(err, &list) || len(list) == 0 {
+		return err
+	}
+	pos := list[0].Pos
+	pos.Offset -= prefixLen
+	if pos.Line == 1 {
+		pos.Column -= prefixLen
+	}
+	return &goSyntaxError{pos, list[0].Msg}
+}
@@ -0,0 +1,324 @@
+// Package highlight colours source code on the server. A small tokenizer, configured separately for
+// each supported language, splits the code into tokens, which are written out as HTML with a
+// classed <span> around every token worth colouring.
+package highlight
+
+import (
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Kind identifies what sort of token a piece of code is.
+type Kind int
+
+const (
+	Plain Kind = iota
+	Keyword
+	Builtin
+	Literal
+	String
+	Number
+	Comment
+	Key
+	Variable
+)
+
+// Class returns the CSS class of tokens of the kind, or the empty string for plain text.
+func (k Kind) Class() string {
+	switch k {
+	case Keyword:
+		return "kw"
+	case Builtin:
+		return "bi"
+	case Literal:
+		return "lit"
+	case String:
+		return "str"
+	case Number:
+		return "num"
+	case Comment:
+		return "com"
+	case Key:
+		return "key"
+	case Variable:
+		return "var"
+	default:
+		return ""
+	}
+}
+
+// A Token is a piece of code of a single kind. Adjacent plain characters are merged into one token.
+type Token struct {
+	Kind Kind
+	Text string
+}
+
+// Tokenize splits src into tokens according to the rules of the named language. The texts of the
+// tokens always add up to src. Code in an unknown language comes back as a single plain token.
+func Tokenize(language, src string) []Token {
+	l, ok := lexers[language]
+	if !ok {
+		if src == "" {
+			return nil
+		}
+		return []Token{{Plain, src}}
+	}
+	return l.tokenize(src)
+}
+
+// Lines highlights src as the named language and returns it as HTML, one string per line, with
+// all of the code escaped. Tokens which span several lines, like block comments, are closed at
+// the end of each line and reopened on the next, so every line stands on its own. Windows line
+// endings are normalised and a single trailing newline doesn`t produce an extra empty line.
+func Lines(language, src string) []string {
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	src = strings.TrimSuffix(src, "\n")
+
+	lines := []string{}
+	var b strings.Builder
+	for _, t := range Tokenize(language, src) {
+		for i, part := range strings.Split(t.Text, "\n") {
+			if i > 0 {
+				lines = append(lines, b.String())
+				b.Reset()
+			}
+			if part == "" {
+				continue
+			}
+			if class := t.Kind.Class(); class != "" {
+				b.WriteString(`<span class="` + class + `">` + html.EscapeString(part) + `</span>`)
+			} else {
+				b.WriteString(html.EscapeString(part))
+			}
+		}
+	}
+	return append(lines, b.String())
+}
+
+// A lexer holds the rules for tokenizing one language.
+type lexer struct {
+	keywords map[string]bool
+	builtins map[string]bool
+	literals map[string]bool
+	// foldCase makes keywords, builtins and literals case insensitive, as in SQL.
+	foldCase bool
+	// identExtra holds characters, besides letters, digits and underscores, which can appear
+	// in the middle of a word.
+	identExtra string
+
+	lineComments []string
+	blockComment [2]string
+
+	// quotes holds the characters which start and end a string. Strings in rawQuotes have no
+	// backslash escapes, and strings can only span lines if multiline is set.
+	quotes    string
+	rawQuotes string
+	multiline bool
+	// tripleQuotes allows Python style """ and ''' strings, which can span lines.
+	tripleQuotes bool
+	// noBackslash is set for languages, like SQL, where backslash isn`t an escape character.
+	noBackslash bool
+
+	// variables highlights shell style $NAME, ${...} and $1 variables.
+	variables bool
+	// keys highlights strings and words followed by a colon, as in JSON and YAML.
+	keys bool
+}
+
+func (l *lexer) tokenize(src string) []Token {
+	var tokens []Token
+	emit := func(kind Kind, text string) {
+		if text == "" {
+			return
+		}
+		// Merge adjacent plain text, so it isn`t split into a token per character.
+		if n := len(tokens); n > 0 && kind == Plain && tokens[n-1].Kind == Plain {
+			tokens[n-1].Text += text
+			return
+		}
+		tokens = append(tokens, Token{kind, text})
+	}
+
+	for i := 0; i < len(src); {
+		rest := src[i:]
+		r, size := utf8.DecodeRuneInString(rest)
+
+		if n := l.comment(rest); n > 0 {
+			emit(Comment, rest[:n])
+			i += n
+			continue
+		}
+
+		if n := l.str(rest); n > 0 {
+			kind := String
+			if l.keys && strings.HasPrefix(strings.TrimLeft(rest[n:], " \t"), ":") {
+				kind = Key
+			}
+			emit(kind, rest[:n])
+			i += n
+			continue
+		}
+
+		if l.variables && r == '$' {
+			if n := variable(rest); n > 0 {
+				emit(Variable, rest[:n])
+				i += n
+				continue
+			}
+		}
+
+		if isDigit(r) || (r == '.' && len(rest) > 1 && isDigit(rune(rest[1]))) {
+			n := l.word(rest)
+			// Don`t colour digits which are really the middle of a word.
+			if i == 0 || !l.isIdent(lastRune(src[:i])) {
+				emit(Number, rest[:n])
+			} else {
+				emit(Plain, rest[:n])
+			}
+			i += n
+			continue
+		}
+
+		if unicode.IsLetter(r) || r == '_' {
+			n := l.word(rest)
+			emit(l.wordKind(rest[:n], rest[n:]), rest[:n])
+			i += n
+			continue
+		}
+
+		emit(Plain, rest[:size])
+		i += size
+	}
+	return tokens
+}
+
+// The comment method returns the length of the comment at the start of s, or 0 if there isn`t one.
+func (l *lexer) comment(s string) int {
+	for _, start := range l.lineComments {
+		if strings.HasPrefix(s, start) {
+			if n := strings.IndexByte(s, '\n'); n >= 0 {
+				return n
+			}
+			return len(s)
+		}
+	}
+	if start, end := l.blockComment[0], l.blockComment[1]; start != "" && strings.HasPrefix(s, start) {
+		if n := strings.Index(s[len(start):], end); n >= 0 {
+			return len(start) + n + len(end)
+		}
+		return len(s)
+	}
+	return 0
+}
+
+// The str method returns the length of the string literal at the start of s, or 0 if there isn`t
+// one. An unterminated string runs to the end of the line (or of s, for multiline strings).
+func (l *lexer) str(s string) int {
+	if s == "" || !strings.ContainsRune(l.quotes, rune(s[0])) {
+		return 0
+	}
+	q := s[0]
+
+	if l.tripleQuotes && len(s) >= 3 && s[1] == q && s[2] == q {
+		delim := s[:3]
+		if n := strings.Index(s[3:], delim); n >= 0 {
+			return 3 + n + 3
+		}
+		return len(s)
+	}
+
+	raw := strings.IndexByte(l.rawQuotes, q) >= 0
+	for i := 1; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && !raw && !l.noBackslash:
+			i++
+		case s[i] == q:
+			return i + 1
+		case s[i] == '\n' && !raw && !l.multiline:
+			return i
+		}
+	}
+	return len(s)
+}
+
+// The variable function returns the length of the shell variable at the start of s, or 0 if the
+// dollar sign doesn`t start one.
+func variable(s string) int {
+	if len(s) < 2 {
+		return 0
+	}
+	switch c := s[1]; {
+	case c == '{':
+		if n := strings.IndexByte(s, '}'); n >= 0 {
+			return n + 1
+		}
+		return 0
+	case isDigit(rune(c)) || strings.IndexByte("@*#?$!-", c) >= 0:
+		return 2
+	}
+	n := 1
+	for n < len(s) && (s[n] == '_' || isDigit(rune(s[n])) || unicode.IsLetter(rune(s[n]))) {
+		n++
+	}
+	if n == 1 {
+		return 0
+	}
+	return n
+}
+
+// The word method returns the length of the word (or number) at the start of s.
+func (l *lexer) word(s string) int {
+	number := isDigit(rune(s[0])) || s[0] == '.'
+	n := 0
+	for n < len(s) {
+		r, size := utf8.DecodeRuneInString(s[n:])
+		// Let numbers like 1.5 run on, but not words into a method call like x.y.
+		if !l.isIdent(r) && !(r == '.' && number) {
+			break
+		}
+		n += size
+	}
+	return n
+}
+
+// The wordKind method returns what sort of token word is; rest is the code which follows it.
+func (l *lexer) wordKind(word, rest string) Kind {
+	if l.keys && l.followedByColon(rest) {
+		return Key
+	}
+	if l.foldCase {
+		word = strings.ToLower(word)
+	}
+	switch {
+	case l.keywords[word]:
+		return Keyword
+	case l.literals[word]:
+		return Literal
+	case l.builtins[word]:
+		return Builtin
+	default:
+		return Plain
+	}
+}
+
+// The followedByColon method reports whether the next thing on the line is a colon which starts a
+// value, rather than the middle of something like a URL or a time.
+func (l *lexer) followedByColon(rest string) bool {
+	rest = strings.TrimLeft(rest, " \t")
+	return strings.HasPrefix(rest, ":") && (len(rest) == 1 || strings.IndexByte(" \t\n", rest[1]) >= 0)
+}
+
+func (l *lexer) isIdent(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune(l.identExtra, r)
+}
+
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+func lastRune(s string) rune {
+	r, _ := utf8.DecodeLastRuneInString(s)
+	return r
+}
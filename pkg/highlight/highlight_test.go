@@ -0,0 +1,124 @@
+package highlight
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		name     string
+		language string
+		src      string
+		want     []Token
+	}{
+		{
+			name:     "Go",
+			language: "go",
+			src:      "func f() int { return 42 } // done",
+			want: []Token{
+				{Keyword, "func"}, {Plain, " f() "}, {Builtin, "int"}, {Plain, " { "}, {Keyword, "return"},
+				{Plain, " "}, {Number, "42"}, {Plain, " } "}, {Comment, "// done"},
+			},
+		},
+		{
+			name:     "Go raw string",
+			language: "go",
+			src:      "x := `a\\`",
+			want:     []Token{{Plain, "x := "}, {String, "`a\\`"}},
+		},
+		{
+			name:     "SQL",
+			language: "sql",
+			src:      "SELECT 'it''s' FROM t -- x",
+			want: []Token{
+				{Keyword, "SELECT"}, {Plain, " "}, {String, "'it'"}, {String, "'s'"}, {Plain, " "},
+				{Keyword, "FROM"}, {Plain, " t "}, {Comment, "-- x"},
+			},
+		},
+		{
+			name:     "JSON",
+			language: "json",
+			src:      `{"a":1.5,"b":null}`,
+			want: []Token{
+				{Plain, "{"}, {Key, `"a"`}, {Plain, ":"}, {Number, "1.5"}, {Plain, ","}, {Key, `"b"`},
+				{Plain, ":"}, {Literal, "null"}, {Plain, "}"},
+			},
+		},
+		{
+			name:     "YAML",
+			language: "yaml",
+			src:      "image-name: nginx:1.19 # latest",
+			want:     []Token{{Key, "image-name"}, {Plain, ": nginx:"}, {Number, "1.19"}, {Plain, " "}, {Comment, "# latest"}},
+		},
+		{
+			name:     "Shell",
+			language: "shell",
+			src:      `echo "$HOME" ${PATH} $1`,
+			want: []Token{
+				{Builtin, "echo"}, {Plain, " "}, {String, `"$HOME"`}, {Plain, " "}, {Variable, "${PATH}"},
+				{Plain, " "}, {Variable, "$1"},
+			},
+		},
+		{
+			name:     "Python",
+			language: "python",
+			src:      "def f():\n    '''doc\n    string'''\n    return None",
+			want: []Token{
+				{Keyword, "def"}, {Plain, " f():\n    "}, {String, "'''doc\n    string'''"}, {Plain, "\n    "},
+				{Keyword, "return"}, {Plain, " "}, {Literal, "None"},
+			},
+		},
+		{
+			name:     "JavaScript",
+			language: "javascript",
+			src:      "const x = .5; /* note */",
+			want:     []Token{{Keyword, "const"}, {Plain, " x = "}, {Number, ".5"}, {Plain, "; "}, {Comment, "/* note */"}},
+		},
+		{
+			name:     "Unknown language",
+			language: "cobol",
+			src:      "MOVE 1 TO X",
+			want:     []Token{{Plain, "MOVE 1 TO X"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Tokenize(tt.language, tt.src)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("want %v; got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestLines(t *testing.T) {
+	src := "/* a <b>\nc */ x\r\n"
+	want := []string{
+		`<span class="com">/* a &lt;b&gt;</span>`,
+		`<span class="com">c */</span> x`,
+	}
+
+	got := Lines("go", src)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %q; got %q", want, got)
+	}
+}
+
+// Every token kind must survive being split, so the texts always add up to the source.
+func TestTokenizeKeepsSource(t *testing.T) {
+	srcs := []string{"", "'unterminated", "$", "${", "x.5", "é1 = \"ü\"", "/* open", "a\\"}
+	for _, l := range Languages {
+		for _, src := range srcs {
+			var b strings.Builder
+			for _, tok := range Tokenize(l.Name, src) {
+				b.WriteString(tok.Text)
+			}
+			if b.String() != src {
+				t.Errorf("%s: want %q; got %q", l.Name, src, b.String())
+			}
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package highlight
+
+import (
+	"strings"
+)
+
+// A Language is one of the languages code can be highlighted as. Name is what is stored with a
+// snippet, Label is what is shown to users and Extension is given to files of the language when
+// they are downloaded.
+type Language struct {
+	Name      string
+	Label     string
+	Extension string
+}
+
+// Languages lists the supported languages, in the order they should be offered to users.
+var Languages = []Language{
+	{"dockerfile", "Dockerfile", ""},
+	{"go", "Go", ".go"},
+	{"javascript", "JavaScript", ".js"},
+	{"json", "JSON", ".json"},
+	{"markdown", "Markdown", ".md"},
+	{"python", "Python", ".py"},
+	{"shell", "Shell", ".sh"},
+	{"sql", "SQL", ".sql"},
+	{"yaml", "YAML", ".yaml"},
+}
+
+// Supported reports whether code can be highlighted as the named language. Markdown is offered as a
+// language, but is rendered rather than highlighted, so it isn`t supported here.
+func Supported(name string) bool {
+	_, ok := lexers[name]
+	return ok
+}
+
+// Label returns the label of the named language, or "Plain text" if it isn`t supported.
+func Label(name string) string {
+	for _, l := range Languages {
+		if l.Name == name {
+			return l.Label
+		}
+	}
+	return "Plain text"
+}
+
+// Extension returns the file extension of the named language, or ".txt" if it isn`t supported.
+// Dockerfiles are the exception: they are conventionally named without an extension.
+func Extension(name string) string {
+	for _, l := range Languages {
+		if l.Name == name {
+			return l.Extension
+		}
+	}
+	return ".txt"
+}
+
+// The words function turns a space separated list of words into a set.
+func words(s string) map[string]bool {
+	set := map[string]bool{}
+	for _, w := range strings.Fields(s) {
+		set[w] = true
+	}
+	return set
+}
+
+var lexers = map[string]*lexer{
+	"dockerfile": {
+		keywords: words(`from as run cmd label maintainer expose env add copy entrypoint volume user workdir
+			arg onbuild stopsignal healthcheck shell`),
+		foldCase:     true,
+		identExtra:   "-",
+		lineComments: []string{"#"},
+		quotes:       `"'`,
+		variables:    true,
+	},
+	"go": {
+		keywords: words(`break case chan const continue default defer else fallthrough for func go goto if
+			import interface map package range return select struct switch type var`),
+		builtins: words(`append cap close complex copy delete imag len make new panic print println real
+			recover bool byte complex64 complex128 error float32 float64 int int8 int16 int32 int64 rune
+			string uint uint8 uint16 uint32 uint64 uintptr any comparable`),
+		literals:     words(`true false nil iota`),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       "\"'`",
+		rawQuotes:    "`",
+	},
+	"javascript": {
+		keywords: words(`async await break case catch class const continue debugger default delete do
+			else export extends finally for from function if import in instanceof let new of return
+			static super switch this throw try typeof var void while with yield`),
+		builtins: words(`Array Boolean Date Error JSON Map Math Number Object Promise RegExp Set String
+			Symbol console document window require module exports`),
+		literals:     words(`true false null undefined NaN Infinity`),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       "\"'`",
+		multiline:    true,
+	},
+	"json": {
+		literals: words(`true false null`),
+		quotes:   `"`,
+		keys:     true,
+	},
+	"python": {
+		keywords: words(`and as assert async await break class continue def del elif else except finally
+			for from global if import in is lambda nonlocal not or pass raise return try while with yield`),
+		builtins: words(`abs all any bool bytes dict enumerate filter float format getattr hasattr int
+			isinstance len list map max min object open print range repr reversed set sorted str sum
+			super tuple type zip self cls`),
+		literals:     words(`True False None`),
+		lineComments: []string{"#"},
+		quotes:       `"'`,
+		tripleQuotes: true,
+	},
+	"shell": {
+		keywords: words(`if then else elif fi for while until do done case esac in function select
+			return break continue local export readonly declare unset`),
+		builtins: words(`echo printf cd pwd read test exit set source eval exec trap shift alias
+			cat grep sed awk curl sudo`),
+		literals:     words(`true false`),
+		identExtra:   "-",
+		lineComments: []string{"#"},
+		quotes:       `"'`,
+		rawQuotes:    `'`,
+		multiline:    true,
+		variables:    true,
+	},
+	"sql": {
+		keywords: words(`add all alter and as asc begin between by case check column commit constraint
+			create cross database default delete desc distinct drop else end exists foreign from full
+			grant group having if in index inner insert into is join key left like limit not offset on
+			or order outer primary references returning revoke right rollback select set table then
+			transaction union unique update using values view when where with`),
+		builtins: words(`integer int smallint bigint serial bigserial text varchar char boolean bool
+			timestamp timestamptz date time interval numeric decimal real json jsonb uuid bytea
+			count sum avg min max coalesce now lower upper length array`),
+		literals:     words(`true false null`),
+		foldCase:     true,
+		lineComments: []string{"--"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       `'"`,
+		multiline:    true,
+		noBackslash:  true,
+	},
+	"yaml": {
+		literals:     words(`true false null yes no on off`),
+		identExtra:   "-.",
+		lineComments: []string{"#"},
+		quotes:       `"'`,
+		keys:         true,
+	},
+}
@@ -0,0 +1,293 @@
+package markdown
+
+import (
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The inline function renders the text of a paragraph, heading or table cell: code spans, emphasis,
+// strikethrough, links, images, autolinks, hard line breaks and backslash escapes. Everything else is
+// escaped.
+func inline(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && isPunct(s[i+1]):
+			b.WriteString(html.EscapeString(s[i+1 : i+2]))
+			i += 2
+			continue
+
+		case c == '\\' && i+1 < len(s) && s[i+1] == '\n':
+			b.WriteString("<br>\n")
+			i += 2
+			continue
+
+		case c == ' ' && strings.HasPrefix(s[i:], "  \n"):
+			// Two or more spaces at the end of a line are a hard line break.
+			b.WriteString("<br>\n")
+			i += 3
+			continue
+
+		case c == '`':
+			if n, code := codeSpan(s[i:]); n > 0 {
+				b.WriteString("<code>" + html.EscapeString(code) + "</code>")
+				i += n
+				continue
+			}
+
+		case c == '!' && strings.HasPrefix(s[i+1:], "["):
+			if n, text, url, title := link(s[i+1:]); n > 0 {
+				b.WriteString(`<img src="` + html.EscapeString(url) + `" alt="` + html.EscapeString(plain(text)) + `"`)
+				if title != "" {
+					b.WriteString(` title="` + html.EscapeString(title) + `"`)
+				}
+				b.WriteString(">")
+				i += 1 + n
+				continue
+			}
+
+		case c == '[':
+			if n, text, url, title := link(s[i:]); n > 0 {
+				b.WriteString(`<a href="` + html.EscapeString(url) + `"`)
+				if title != "" {
+					b.WriteString(` title="` + html.EscapeString(title) + `"`)
+				}
+				b.WriteString(">" + inline(text) + "</a>")
+				i += n
+				continue
+			}
+
+		case c == '<':
+			if n, url := autolink(s[i:]); n > 0 {
+				href := url
+				if strings.Contains(url, "@") && !strings.Contains(url, ":") {
+					href = "mailto:" + url
+				}
+				b.WriteString(`<a href="` + html.EscapeString(href) + `">` + html.EscapeString(url) + "</a>")
+				i += n
+				continue
+			}
+
+		case c == '*' || c == '_' || c == '~':
+			if n, tag, inner := emphasis(s, i); n > 0 {
+				b.WriteString("<" + tag + ">" + inline(inner) + "</" + tag + ">")
+				i += n
+				continue
+			}
+		}
+
+		// Copy the run of characters up to the next one which might be special.
+		j := i + 1
+		for j < len(s) && strings.IndexByte("\\ `![<*_~", s[j]) < 0 {
+			j++
+		}
+		b.WriteString(html.EscapeString(s[i:j]))
+		i = j
+	}
+	return b.String()
+}
+
+// The codeSpan function returns the length and contents of the code span at the start of s, or 0
+// if the opening backticks aren`t matched by a run of the same length.
+func codeSpan(s string) (int, string) {
+	open := 0
+	for open < len(s) && s[open] == '`' {
+		open++
+	}
+	for i := open; i < len(s); {
+		if s[i] != '`' {
+			i++
+			continue
+		}
+		j := i
+		for j < len(s) && s[j] == '`' {
+			j++
+		}
+		if j-i == open {
+			code := strings.ReplaceAll(s[open:i], "\n", " ")
+			// A single space either side lets a code span start or end with a backtick.
+			if len(code) > 2 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.Trim(code, " ") != "" {
+				code = code[1 : len(code)-1]
+			}
+			return j, code
+		}
+		i = j
+	}
+	return 0, ""
+}
+
+// The link function parses a [text](url "title") link at the start of s and returns its length
+// and parts, or 0 if there isn`t one.
+func link(s string) (n int, text, url, title string) {
+	// Find the bracket closing the text, allowing nested brackets and skipping code spans.
+	depth := 0
+	end := -1
+	for i := 0; i < len(s) && end < 0; i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '`':
+			if n, _ := codeSpan(s[i:]); n > 0 {
+				i += n - 1
+			}
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				end = i
+			}
+		}
+	}
+	if end < 0 || end+1 >= len(s) || s[end+1] != '(' {
+		return 0, "", "", ""
+	}
+	text = s[1:end]
+
+	// Find the parenthesis closing the destination, allowing balanced parentheses inside it.
+	depth = 0
+	close := -1
+	for i := end + 1; i < len(s) && close < 0; i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				close = i
+			}
+		case '\n':
+			return 0, "", "", ""
+		}
+	}
+	if close < 0 {
+		return 0, "", "", ""
+	}
+
+	dest := strings.TrimSpace(s[end+2 : close])
+	if i := strings.IndexAny(dest, " \t"); i >= 0 {
+		rest := strings.TrimSpace(dest[i:])
+		if len(rest) >= 2 && (rest[0] == '"' || rest[0] == '\'') && rest[len(rest)-1] == rest[0] {
+			title = rest[1 : len(rest)-1]
+			dest = dest[:i]
+		} else {
+			return 0, "", "", ""
+		}
+	}
+	url = strings.TrimSuffix(strings.TrimPrefix(dest, "<"), ">")
+	return close + 1, text, url, title
+}
+
+// The autolink function parses a <scheme:...> or <user@host> autolink at the start of s and returns
+// its length and address, or 0 if there isn`t one.
+func autolink(s string) (int, string) {
+	end := strings.IndexByte(s, '>')
+	if end < 0 {
+		return 0, ""
+	}
+	url := s[1:end]
+	if url == "" || strings.ContainsAny(url, " \t\n<") {
+		return 0, ""
+	}
+	if i := strings.Index(url, "://"); i > 0 || strings.HasPrefix(url, "mailto:") {
+		return end + 1, url
+	}
+	if at := strings.IndexByte(url, '@'); at > 0 && strings.Contains(url[at:], ".") {
+		return end + 1, url
+	}
+	return 0, ""
+}
+
+// The emphasis function parses emphasis starting at s[i]: *em*, _em_, **strong**, __strong__ or
+// ~~del~~. It returns the length of the whole thing, the tag to use and the text inside, or 0 if
+// the delimiters don`t pair up. Underscores inside words, like snake_case, are left alone.
+func emphasis(s string, i int) (int, string, string) {
+	c := s[i]
+	run := 0
+	for i+run < len(s) && s[i+run] == c {
+		run++
+	}
+	if run > 2 {
+		run = 2
+	}
+
+	tag := "em"
+	switch {
+	case c == '~' && run == 2:
+		tag = "del"
+	case c == '~':
+		return 0, "", ""
+	case run == 2:
+		tag = "strong"
+	}
+
+	// An opening delimiter must be followed by text, and an underscore must not be inside a word.
+	start := i + run
+	if start >= len(s) || isSpace(s[start:]) {
+		return 0, "", ""
+	}
+	if c == '_' && i > 0 && isWordChar(lastRune(s[:i])) {
+		return 0, "", ""
+	}
+
+	delim := strings.Repeat(string(c), run)
+	for j := start + 1; j <= len(s)-run; j++ {
+		if s[j] == '`' {
+			// Delimiters inside code spans don`t count.
+			if n, _ := codeSpan(s[j:]); n > 0 {
+				j += n - 1
+				continue
+			}
+		}
+		if s[j] == '\\' {
+			j++
+			continue
+		}
+		if !strings.HasPrefix(s[j:], delim) {
+			continue
+		}
+		// A closing delimiter must follow text, must not be part of a longer run, and an underscore
+		// must not be followed by a word character.
+		if isSpace(s[j-1:j]) || (run == 1 && (s[j-1] == c || (j+1 < len(s) && s[j+1] == c))) {
+			continue
+		}
+		if c == '_' && j+run < len(s) && isWordChar(firstRune(s[j+run:])) {
+			continue
+		}
+		return j + run - i, tag, s[start:j]
+	}
+	return 0, "", ""
+}
+
+// The plain function strips the Markdown from the text of an image, for use as its alt text.
+func plain(s string) string {
+	return strings.NewReplacer("*", "", "_", "", "`", "", "[", "", "]", "").Replace(s)
+}
+
+func isPunct(c byte) bool {
+	return strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", c) >= 0
+}
+
+func isSpace(s string) bool {
+	r, _ := utf8.DecodeRuneInString(s)
+	return unicode.IsSpace(r)
+}
+
+func isWordChar(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func firstRune(s string) rune {
+	r, _ := utf8.DecodeRuneInString(s)
+	return r
+}
+
+func lastRune(s string) rune {
+	r, _ := utf8.DecodeLastRuneInString(s)
+	return r
+}
@@ -0,0 +1,374 @@
+// Package markdown renders the commonly used parts of Markdown to HTML: headings, paragraphs,
+// emphasis, links and images, block quotes, nested lists, tables, horizontal rules and both fenced
+// and indented code blocks. Fenced code blocks naming a supported language are highlighted.
+//
+// Render escapes all of the text it outputs and never passes raw HTML through, but its output should
+// still be cleaned with Sanitize before it is shown to anyone, as the two together are what make it
+// safe.
+package markdown
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+
+	"sabiraliyev.net/snippetbox/pkg/highlight"
+)
+
+var (
+	headingRX   = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	ruleRX      = regexp.MustCompile(`^ {0,3}(?:(?:\*[ \t]*){3,}|(?:-[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	fenceRX     = regexp.MustCompile("^( {0,3})(`{3,}|~{3,})[ \t]*([^`\\s]*)")
+	listItemRX  = regexp.MustCompile(`^( *)([-*+]|\d{1,9}[.)])( +|$)`)
+	setextRX    = regexp.MustCompile(`^ {0,3}(=+|-+)[ \t]*$`)
+	tableSepRX  = regexp.MustCompile(`^ *\|? *:?-+:? *(\| *:?-+:? *)*\|? *$`)
+	quoteRX     = regexp.MustCompile(`^ {0,3}> ?`)
+	indentedRX  = regexp.MustCompile(`^(    |\t)`)
+	orderedRX   = regexp.MustCompile(`^\d`)
+	listStartRX = regexp.MustCompile(`^(\d+)`)
+)
+
+// Render converts Markdown to HTML.
+func Render(src string) string {
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	var b strings.Builder
+	renderBlocks(&b, strings.Split(src, "\n"), false)
+	return b.String()
+}
+
+// The renderBlocks function renders a run of lines as block elements. In a tight list item the
+// paragraphs aren`t wrapped in <p> elements.
+func renderBlocks(b *strings.Builder, lines []string, tight bool) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+
+		switch {
+		case strings.TrimSpace(line) == "":
+			i++
+
+		case fenceRX.MatchString(line):
+			i = renderFence(b, lines, i)
+
+		case headingRX.MatchString(line):
+			m := headingRX.FindStringSubmatch(line)
+			fmt.Fprintf(b, "<h%d>%s</h%d>\n", len(m[1]), inline(m[2]), len(m[1]))
+			i++
+
+		case ruleRX.MatchString(line):
+			b.WriteString("<hr>\n")
+			i++
+
+		case quoteRX.MatchString(line):
+			var quoted []string
+			for ; i < len(lines) && strings.TrimSpace(lines[i]) != ""; i++ {
+				quoted = append(quoted, quoteRX.ReplaceAllString(lines[i], ""))
+			}
+			b.WriteString("<blockquote>\n")
+			renderBlocks(b, quoted, false)
+			b.WriteString("</blockquote>\n")
+
+		case listItemRX.MatchString(line):
+			i = renderList(b, lines, i)
+
+		case indentedRX.MatchString(line):
+			var code []string
+			for ; i < len(lines) && (indentedRX.MatchString(lines[i]) || strings.TrimSpace(lines[i]) == ""); i++ {
+				code = append(code, indentedRX.ReplaceAllString(lines[i], ""))
+			}
+			// Trailing blank lines belong to whatever follows the code block.
+			for len(code) > 0 && strings.TrimSpace(code[len(code)-1]) == "" {
+				code = code[:len(code)-1]
+			}
+			writeCode(b, "", strings.Join(code, "\n"))
+
+		case i+1 < len(lines) && strings.Contains(line, "|") && tableSepRX.MatchString(lines[i+1]):
+			i = renderTable(b, lines, i)
+
+		default:
+			i = renderParagraph(b, lines, i, tight)
+		}
+	}
+}
+
+// The renderFence function renders the fenced code block starting at lines[i] and returns the index
+// of the line after it. A block with no closing fence runs to the end of the document.
+func renderFence(b *strings.Builder, lines []string, i int) int {
+	m := fenceRX.FindStringSubmatch(lines[i])
+	indent, fence, language := len(m[1]), m[2], strings.ToLower(m[3])
+
+	var code []string
+	for i++; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		if strings.HasPrefix(trimmed, fence[:3]) && strings.Trim(trimmed, fence[:1]) == "" && len(trimmed) >= len(fence) {
+			i++
+			break
+		}
+		// Remove up to as much indentation as the opening fence had.
+		line := lines[i]
+		for n := 0; n < indent && strings.HasPrefix(line, " "); n++ {
+			line = line[1:]
+		}
+		code = append(code, line)
+	}
+	writeCode(b, language, strings.Join(code, "\n"))
+	return i
+}
+
+// The writeCode function writes a code block, highlighted if it is in a supported language.
+func writeCode(b *strings.Builder, language, code string) {
+	if !highlight.Supported(language) {
+		fmt.Fprintf(b, "<pre><code>%s</code></pre>\n", html.EscapeString(code))
+		return
+	}
+	fmt.Fprintf(b, "<pre><code class=\"language-%s\">%s</code></pre>\n", language,
+		strings.Join(highlight.Lines(language, code), "\n"))
+}
+
+// The renderParagraph function renders the paragraph starting at lines[i], which runs until a blank
+// line or the start of another block, and returns the index of the line after it. A paragraph
+// underlined with = or - signs is a heading instead.
+func renderParagraph(b *strings.Builder, lines []string, i int, tight bool) int {
+	var text []string
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if strings.TrimSpace(line) == "" {
+			break
+		}
+		if len(text) > 0 {
+			if m := setextRX.FindStringSubmatch(line); m != nil {
+				level := 1
+				if m[1][0] == '-' {
+					level = 2
+				}
+				fmt.Fprintf(b, "<h%d>%s</h%d>\n", level, inline(strings.Join(text, "\n")), level)
+				return i + 1
+			}
+			if startsBlock(line) {
+				break
+			}
+		}
+		// Trailing spaces are kept, as two of them make a hard line break.
+		text = append(text, strings.TrimLeft(line, " \t"))
+	}
+
+	content := inline(strings.TrimRight(strings.Join(text, "\n"), " \t"))
+	if tight {
+		b.WriteString(content + "\n")
+	} else {
+		fmt.Fprintf(b, "<p>%s</p>\n", content)
+	}
+	return i
+}
+
+// The startsBlock function reports whether a line interrupts a paragraph by starting another block.
+func startsBlock(line string) bool {
+	return fenceRX.MatchString(line) || headingRX.MatchString(line) || ruleRX.MatchString(line) ||
+		quoteRX.MatchString(line) || listItemRX.MatchString(line)
+}
+
+// The renderList function renders the list starting at lines[i] and returns the index of the line
+// after it. Lines indented at least as far as an item`s text belong to that item, so items can hold
+// nested lists, code blocks and further paragraphs. A list with blank lines between its items is
+// loose, and its items` paragraphs are wrapped in <p> elements.
+func renderList(b *strings.Builder, lines []string, i int) int {
+	first := listItemRX.FindStringSubmatch(lines[i])
+	ordered := orderedRX.MatchString(first[2])
+	markerIndent := len(first[1])
+
+	var items [][]string
+	loose := false
+	for i < len(lines) {
+		line := lines[i]
+		m := listItemRX.FindStringSubmatch(line)
+		if m == nil || len(m[1]) != markerIndent || orderedRX.MatchString(m[2]) != ordered {
+			break
+		}
+
+		// The item`s text starts after the marker and the space following it.
+		contentIndent := len(m[0])
+		if m[3] == "" {
+			contentIndent++
+		}
+		item := []string{line[len(m[0]):]}
+		for i++; i < len(lines); i++ {
+			line := lines[i]
+			if strings.TrimSpace(line) == "" {
+				// A blank line ends the item unless the item carries on indented below it.
+				if i+1 < len(lines) && indentation(lines[i+1]) >= contentIndent {
+					item = append(item, "")
+					continue
+				}
+				break
+			}
+			if indentation(line) >= contentIndent {
+				item = append(item, dedent(line, contentIndent))
+				continue
+			}
+			// Lazy continuation lines carry on the item`s paragraph.
+			if !startsBlock(line) && strings.TrimSpace(item[len(item)-1]) != "" {
+				item = append(item, strings.TrimSpace(line))
+				continue
+			}
+			break
+		}
+		items = append(items, item)
+
+		// Skip blank lines between items, which make the list loose.
+		j := i
+		for j < len(lines) && strings.TrimSpace(lines[j]) == "" {
+			j++
+		}
+		if j >= len(lines) {
+			break
+		}
+		if m := listItemRX.FindStringSubmatch(lines[j]); m == nil || len(m[1]) != markerIndent {
+			break
+		}
+		if j > i {
+			loose = true
+		}
+		i = j
+	}
+	for _, item := range items {
+		for _, line := range item {
+			if line == "" {
+				loose = true
+			}
+		}
+	}
+
+	if ordered {
+		start := listStartRX.FindString(first[2])
+		if start != "1" {
+			fmt.Fprintf(b, "<ol start=\"%s\">\n", strings.TrimLeft(start, "0"))
+		} else {
+			b.WriteString("<ol>\n")
+		}
+	} else {
+		b.WriteString("<ul>\n")
+	}
+	for _, item := range items {
+		b.WriteString("<li>")
+		renderBlocks(b, item, !loose)
+		b.WriteString("</li>\n")
+	}
+	if ordered {
+		b.WriteString("</ol>\n")
+	} else {
+		b.WriteString("</ul>\n")
+	}
+	return i
+}
+
+// The indentation function returns the number of spaces a line starts with, counting a tab as four.
+func indentation(line string) int {
+	n := 0
+	for _, c := range line {
+		switch c {
+		case ' ':
+			n++
+		case '\t':
+			n += 4
+		default:
+			return n
+		}
+	}
+	return n
+}
+
+// The dedent function removes n columns of indentation from the start of a line.
+func dedent(line string, n int) string {
+	for n > 0 && line != "" {
+		switch line[0] {
+		case ' ':
+			n--
+		case '\t':
+			// A tab which only partly fits is replaced by the spaces left over.
+			if n < 4 {
+				return strings.Repeat(" ", 4-n) + line[1:]
+			}
+			n -= 4
+		default:
+			return line
+		}
+		line = line[1:]
+	}
+	return line
+}
+
+// The renderTable function renders the table whose header row is lines[i] and returns the index of
+// the line after it. The delimiter row below the header sets the alignment of each column.
+func renderTable(b *strings.Builder, lines []string, i int) int {
+	header := splitRow(lines[i])
+	var aligns []string
+	for _, cell := range splitRow(lines[i+1]) {
+		left, right := strings.HasPrefix(cell, ":"), strings.HasSuffix(cell, ":")
+		switch {
+		case left && right:
+			aligns = append(aligns, "center")
+		case right:
+			aligns = append(aligns, "right")
+		case left:
+			aligns = append(aligns, "left")
+		default:
+			aligns = append(aligns, "")
+		}
+	}
+
+	writeRow := func(cells []string, tag string) {
+		b.WriteString("<tr>")
+		for n := range aligns {
+			cell := ""
+			if n < len(cells) {
+				cell = cells[n]
+			}
+			if aligns[n] != "" {
+				fmt.Fprintf(b, "<%s class=\"align-%s\">%s</%s>", tag, aligns[n], inline(cell), tag)
+			} else {
+				fmt.Fprintf(b, "<%s>%s</%s>", tag, inline(cell), tag)
+			}
+		}
+		b.WriteString("</tr>\n")
+	}
+
+	b.WriteString("<table>\n<thead>\n")
+	writeRow(header, "th")
+	b.WriteString("</thead>\n<tbody>\n")
+	for i += 2; i < len(lines) && strings.TrimSpace(lines[i]) != "" && strings.Contains(lines[i], "|"); i++ {
+		writeRow(splitRow(lines[i]), "td")
+	}
+	b.WriteString("</tbody>\n</table>\n")
+	return i
+}
+
+// The splitRow function splits a table row into its cells. Pipes escaped with a backslash, or
+// inside code spans, don`t separate cells.
+func splitRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, `\|`) {
+		line = line[:len(line)-1]
+	}
+
+	var cells []string
+	var cell strings.Builder
+	inCode := false
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == '\\' && i+1 < len(line) && line[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+		case c == '`':
+			inCode = !inCode
+			cell.WriteByte(c)
+		case c == '|' && !inCode:
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(c)
+		}
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
@@ -0,0 +1,81 @@
+package markdown
+
+import (
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"Heading", "# Deploy *now*", "<h1>Deploy <em>now</em></h1>\n"},
+		{"Setext heading", "Runbook\n=======", "<h1>Runbook</h1>\n"},
+		{"Paragraphs", "one\ntwo\n\nthree", "<p>one\ntwo</p>\n<p>three</p>\n"},
+		{"Escaping", "a <b> & `<c>`", "<p>a &lt;b&gt; &amp; <code>&lt;c&gt;</code></p>\n"},
+		{"Emphasis", "**bold** _em_ ~~gone~~ snake_case_name", "<p><strong>bold</strong> <em>em</em> <del>gone</del> snake_case_name</p>\n"},
+		{"Nested emphasis", "*a **b** c*", "<p><em>a <strong>b</strong> c</em></p>\n"},
+		{"Link", `[the *docs*](https://example.com/a_(b) "Docs")`, "<p><a href=\"https://example.com/a_(b)\" title=\"Docs\">the <em>docs</em></a></p>\n"},
+		{"Image", "![a *cat*](/cat.png)", "<p><img src=\"/cat.png\" alt=\"a cat\"></p>\n"},
+		{"Autolink", "<https://example.com> <ops@example.com>", "<p><a href=\"https://example.com\">https://example.com</a> <a href=\"mailto:ops@example.com\">ops@example.com</a></p>\n"},
+		{"Hard break", "a  \nb", "<p>a<br>\nb</p>\n"},
+		{"Rule", "a\n\n---\n", "<p>a</p>\n<hr>\n"},
+		{"Quote", "> quoted\n> text", "<blockquote>\n<p>quoted\ntext</p>\n</blockquote>\n"},
+		{"Tight list", "- one\n- two\n  - nested\n- three", "<ul>\n<li>one\n</li>\n<li>two\n<ul>\n<li>nested\n</li>\n</ul>\n</li>\n<li>three\n</li>\n</ul>\n"},
+		{"Loose list", "1. one\n\n2. two", "<ol>\n<li><p>one</p>\n</li>\n<li><p>two</p>\n</li>\n</ol>\n"},
+		{"Ordered start", "3) three\n4) four", "<ol start=\"3\">\n<li>three\n</li>\n<li>four\n</li>\n</ol>\n"},
+		{"Indented code", "    x := 1\n    y := 2", "<pre><code>x := 1\ny := 2</code></pre>\n"},
+		{"Fence", "```\n<tag>\n```", "<pre><code>&lt;tag&gt;</code></pre>\n"},
+		{"Highlighted fence", "```go\nreturn nil\n```", "<pre><code class=\"language-go\"><span class=\"kw\">return</span> <span class=\"lit\">nil</span></code></pre>\n"},
+		{"Unclosed fence", "~~~\ncode", "<pre><code>code</code></pre>\n"},
+		{
+			"Table",
+			"| Name | Port |\n|:-----|-----:|\n| web | 80 |\n| `a\\|b` |",
+			"<table>\n<thead>\n<tr><th class=\"align-left\">Name</th><th class=\"align-right\">Port</th></tr>\n</thead>\n<tbody>\n" +
+				"<tr><td class=\"align-left\">web</td><td class=\"align-right\">80</td></tr>\n" +
+				"<tr><td class=\"align-left\"><code>a|b</code></td><td class=\"align-right\"></td></tr>\n</tbody>\n</table>\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Render(tt.src)
+			if got != tt.want {
+				t.Errorf("want %q; got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{"Allowed", "<p><em>hi</em></p>", "<p><em>hi</em></p>"},
+		{"Script", "a<script>alert(1)</script>b", "ab"},
+		{"Unknown element", "<div onclick=\"x()\">text</div>", "text"},
+		{"Event handler", "<p onmouseover=\"x()\">a</p>", "<p>a</p>"},
+		{"Javascript link", "<a href=\"javascript:alert(1)\">a</a>", "<a rel=\"nofollow noopener\">a</a>"},
+		{"Obfuscated link", "<a href=\"jav&#x09;ascript:alert(1)\">a</a>", "<a rel=\"nofollow noopener\">a</a>"},
+		{"Safe link", "<a href=\"/s/abc?x=1&amp;y=2\">a</a>", "<a href=\"/s/abc?x=1&amp;y=2\" rel=\"nofollow noopener\">a</a>"},
+		{"Mailto image", "<img src=\"mailto:x@example.com\" alt=\"x\">", "<img alt=\"x\">"},
+		{"Bad class", "<span class=\"a\" style=\"color:red\" class=\"b\">x</span>", "<span class=\"a\">x</span>"},
+		{"Unclosed", "<ul><li>one", "<ul><li>one</li></ul>"},
+		{"Stray closing", "a</p></em>b", "ab"},
+		{"Stray brackets", "1 < 2 > 0 & 3", "1 &lt; 2 &gt; 0 &amp; 3"},
+		{"Entities", "&lt;&#60;&amp;", "&lt;&#60;&amp;"},
+		{"Unterminated script", "a<script>alert(1)", "a"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Sanitize(tt.html)
+			if got != tt.want {
+				t.Errorf("want %q; got %q", tt.want, got)
+			}
+		})
+	}
+}
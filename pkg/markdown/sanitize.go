@@ -0,0 +1,197 @@
+package markdown
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// The allowed map is the allowlist of elements Sanitize keeps, with the attributes each may have.
+var allowed = map[string]map[string]bool{
+	"h1": {}, "h2": {}, "h3": {}, "h4": {}, "h5": {}, "h6": {},
+	"p": {}, "br": {}, "hr": {}, "blockquote": {},
+	"em": {}, "strong": {}, "del": {},
+	"pre": {}, "code": {"class": true}, "span": {"class": true},
+	"ul": {}, "ol": {"start": true}, "li": {},
+	"a":     {"href": true, "title": true},
+	"img":   {"src": true, "alt": true, "title": true},
+	"table": {}, "thead": {}, "tbody": {}, "tr": {},
+	"th": {"class": true}, "td": {"class": true},
+}
+
+// The void elements have no closing tag.
+var void = map[string]bool{"br": true, "hr": true, "img": true}
+
+// The content of these elements is dropped along with the elements themselves.
+var dropContent = map[string]bool{"script": true, "style": true, "iframe": true, "object": true, "textarea": true}
+
+var (
+	tagRX    = regexp.MustCompile(`^<(/?)([a-zA-Z][a-zA-Z0-9]*)((?:\s+[a-zA-Z_:][-a-zA-Z0-9_:.]*(?:\s*=\s*(?:"[^"]*"|'[^']*'|[^\s"'=<>` + "`" + `]+))?)*)\s*/?>`)
+	attrRX   = regexp.MustCompile(`([a-zA-Z_:][-a-zA-Z0-9_:.]*)(?:\s*=\s*("[^"]*"|'[^']*'|[^\s"'=<>` + "`" + `]+))?`)
+	entityRX = regexp.MustCompile(`^&(?:#[0-9]{1,7}|#[xX][0-9a-fA-F]{1,6}|[a-zA-Z][a-zA-Z0-9]{1,31});`)
+	classRX  = regexp.MustCompile(`^[a-z][a-z0-9 -]*$`)
+	numberRX = regexp.MustCompile(`^[0-9]{1,9}$`)
+)
+
+// Sanitize cleans HTML against a strict allowlist. Only the elements produced by Render are kept, with
+// only their expected attributes; everything else is removed, and the contents of elements like
+// <script> go with them. Links and images may only point at http, https or relative URLs (and links at
+// mailto addresses too), and links get rel="nofollow noopener". Stray angle brackets and ampersands in
+// the text are escaped, and any elements left open are closed, so the result can`t affect the page
+// around it.
+func Sanitize(s string) string {
+	var b strings.Builder
+	var open []string
+
+	for len(s) > 0 {
+		switch s[0] {
+		case '<':
+			m := tagRX.FindStringSubmatch(s)
+			if m == nil {
+				b.WriteString("&lt;")
+				s = s[1:]
+				continue
+			}
+			s = s[len(m[0]):]
+			closing, name := m[1] == "/", strings.ToLower(m[2])
+
+			if dropContent[name] && !closing {
+				// Skip everything up to and including the matching closing tag.
+				end := strings.Index(strings.ToLower(s), "</"+name)
+				if end < 0 {
+					return closeAll(&b, open)
+				}
+				s = s[end:]
+				if gt := strings.IndexByte(s, '>'); gt >= 0 {
+					s = s[gt+1:]
+				} else {
+					s = ""
+				}
+				continue
+			}
+
+			attrs, ok := allowed[name]
+			switch {
+			case !ok:
+				// Drop elements which aren`t allowed, but keep their text.
+			case closing:
+				// Close the element, along with any left open inside it. A closing tag with nothing
+				// to match is dropped.
+				for i := len(open) - 1; i >= 0; i-- {
+					if open[i] == name {
+						for j := len(open) - 1; j >= i; j-- {
+							b.WriteString("</" + open[j] + ">")
+						}
+						open = open[:i]
+						break
+					}
+				}
+			default:
+				b.WriteString("<" + name + cleanAttrs(name, attrs, m[3]) + ">")
+				if !void[name] {
+					open = append(open, name)
+				}
+			}
+
+		case '>':
+			b.WriteString("&gt;")
+			s = s[1:]
+
+		case '&':
+			if e := entityRX.FindString(s); e != "" {
+				b.WriteString(e)
+				s = s[len(e):]
+			} else {
+				b.WriteString("&amp;")
+				s = s[1:]
+			}
+
+		default:
+			n := strings.IndexAny(s, "<>&")
+			if n < 0 {
+				n = len(s)
+			}
+			b.WriteString(s[:n])
+			s = s[n:]
+		}
+	}
+	return closeAll(&b, open)
+}
+
+// The closeAll function closes any elements left open and returns the sanitized HTML.
+func closeAll(b *strings.Builder, open []string) string {
+	for i := len(open) - 1; i >= 0; i-- {
+		b.WriteString("</" + open[i] + ">")
+	}
+	return b.String()
+}
+
+// The cleanAttrs function returns the allowed attributes of an element, with their values checked
+// and escaped again, ready to go into the start tag.
+func cleanAttrs(tag string, allowed map[string]bool, s string) string {
+	var b strings.Builder
+	seen := map[string]bool{}
+	for _, m := range attrRX.FindAllStringSubmatch(s, -1) {
+		name := strings.ToLower(m[1])
+		if !allowed[name] || seen[name] {
+			continue
+		}
+		value := m[2]
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') {
+			value = value[1 : len(value)-1]
+		}
+		value = html.UnescapeString(value)
+
+		switch name {
+		case "href":
+			if !safeURL(value, true) {
+				continue
+			}
+		case "src":
+			if !safeURL(value, false) {
+				continue
+			}
+		case "class":
+			if !classRX.MatchString(value) {
+				continue
+			}
+		case "start":
+			if !numberRX.MatchString(value) {
+				continue
+			}
+		}
+		seen[name] = true
+		b.WriteString(" " + name + `="` + html.EscapeString(value) + `"`)
+	}
+	if tag == "a" {
+		b.WriteString(` rel="nofollow noopener"`)
+	}
+	return b.String()
+}
+
+// The safeURL function reports whether a URL is relative or uses the http or https scheme (or mailto,
+// if mailto is set). Control characters and backslashes, which browsers handle inconsistently, are
+// never allowed.
+func safeURL(s string, mailto bool) bool {
+	for _, r := range s {
+		if r < 0x20 || r == 0x7f || r == '\\' {
+			return false
+		}
+	}
+	u, err := url.Parse(strings.TrimSpace(s))
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "":
+		// A relative URL can`t have a colon before its first slash, or it would be read as a scheme.
+		return !strings.Contains(strings.SplitN(s, "/", 2)[0], ":")
+	case "http", "https":
+		return true
+	case "mailto":
+		return mailto
+	default:
+		return false
+	}
+}
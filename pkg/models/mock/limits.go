@@ -0,0 +1,37 @@
+package mock
+
+import (
+	"sabiraliyev.net/snippetbox/pkg/models"
+	"time"
+)
+
+// The ExpiryLimitModel mock keeps the limits set, so tests can check what was saved.
+type ExpiryLimitModel struct {
+	Updated map[string]time.Duration
+}
+
+func (m *ExpiryLimitModel) Get(role string) (time.Duration, error) {
+	switch role {
+	case models.RoleUser:
+		return 365 * 24 * time.Hour, nil
+	case models.RoleAdministrator:
+		return 0, nil
+	default:
+		return 0, models.ErrNoRecord
+	}
+}
+
+func (m *ExpiryLimitModel) Set(role string, max time.Duration) error {
+	if m.Updated == nil {
+		m.Updated = map[string]time.Duration{}
+	}
+	m.Updated[role] = max
+	return nil
+}
+
+func (m *ExpiryLimitModel) All() (map[string]time.Duration, error) {
+	return map[string]time.Duration{
+		models.RoleUser:          365 * 24 * time.Hour,
+		models.RoleAdministrator: 0,
+	}, nil
+}
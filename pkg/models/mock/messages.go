@@ -0,0 +1,36 @@
+package mock
+
+import (
+	"sabiraliyev.net/snippetbox/pkg/models"
+	"time"
+)
+
+var mockMessage = &models.Message{
+	ID:      1,
+	RoomID:  models.GeneralRoom,
+	UserId:  1,
+	Content: "Has anybody seen my frog?",
+	Created: time.Now(),
+}
+
+type MessageModel struct{}
+
+func (m *MessageModel) Insert(roomID, userID int, content string) (int, error) {
+	return 1, nil
+}
+
+func (m *MessageModel) Get(id int) (*models.Message, error) {
+	switch id {
+	case 1:
+		return mockMessage, nil
+	default:
+		return nil, models.ErrNoRecord
+	}
+}
+
+func (m *MessageModel) Latest(roomID, before, limit int) (*models.MessagePage, error) {
+	if roomID != models.GeneralRoom || before != 0 {
+		return &models.MessagePage{Messages: []*models.Message{}}, nil
+	}
+	return &models.MessagePage{Messages: []*models.Message{mockMessage}}, nil
+}
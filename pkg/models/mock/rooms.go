@@ -0,0 +1,103 @@
+package mock
+
+import (
+	"sabiraliyev.net/snippetbox/pkg/models"
+	"time"
+)
+
+// The mock rooms, and the IDs of their members. Alice (user 1) is in the general room, a private
+// room and a direct room with Bob, but not in the lounge or the hideout.
+var mockRooms = []struct {
+	room    models.Room
+	members []int
+}{
+	{models.Room{ID: models.GeneralRoom, Kind: models.RoomPublic, Name: "general"}, []int{1}},
+	{models.Room{ID: 2, Kind: models.RoomPublic, Name: "lounge"}, []int{2}},
+	{models.Room{ID: 3, Kind: models.RoomPrivate, Name: "secret", Unread: 2}, []int{1}},
+	{models.Room{ID: 4, Kind: models.RoomPrivate, Name: "hideout"}, []int{2}},
+	{models.Room{ID: 5, Kind: models.RoomDirect}, []int{1, 2}},
+}
+
+type RoomModel struct{}
+
+func (m *RoomModel) Insert(userID int, kind, name string) (int, error) {
+	return 6, nil
+}
+
+func (m *RoomModel) Direct(userID, otherID int) (int, error) {
+	return 5, nil
+}
+
+func (m *RoomModel) Get(id, userID int) (*models.Room, error) {
+	for _, r := range mockRooms {
+		if r.room.ID == id {
+			return mockRoom(r.room, r.members, userID), nil
+		}
+	}
+	return nil, models.ErrNoRecord
+}
+
+func (m *RoomModel) ForUser(userID int) ([]*models.Room, error) {
+	rooms := []*models.Room{}
+	for _, r := range mockRooms {
+		if room := mockRoom(r.room, r.members, userID); room.Member {
+			rooms = append(rooms, room)
+		}
+	}
+	return rooms, nil
+}
+
+func (m *RoomModel) Public(userID int) ([]*models.Room, error) {
+	rooms := []*models.Room{}
+	for _, r := range mockRooms {
+		if room := mockRoom(r.room, r.members, userID); room.Kind == models.RoomPublic && !room.Member {
+			rooms = append(rooms, room)
+		}
+	}
+	return rooms, nil
+}
+
+func (m *RoomModel) AddMember(roomID, userID int) error {
+	return nil
+}
+
+func (m *RoomModel) Members(roomID int) ([]int, error) {
+	for _, r := range mockRooms {
+		if r.room.ID == roomID {
+			return r.members, nil
+		}
+	}
+	return []int{}, nil
+}
+
+// The only mock message is in the general room.
+func (m *RoomModel) MarkRead(roomID, userID, messageID int) error {
+	room, err := m.Get(roomID, userID)
+	if err != nil {
+		return err
+	}
+	if !room.Member || messageID != mockMessage.ID || roomID != mockMessage.RoomID {
+		return models.ErrNoRecord
+	}
+	return nil
+}
+
+// The mockRoom function returns a copy of a room as the given user sees it.
+func mockRoom(room models.Room, members []int, userID int) *models.Room {
+	room.Created = time.Now()
+	for _, id := range members {
+		if id == userID {
+			room.Member = true
+		}
+	}
+	if !room.Member {
+		room.Unread = 0
+	}
+	if room.Kind == models.RoomDirect {
+		room.Name = "Bob"
+		if userID == 2 {
+			room.Name = "Alice"
+		}
+	}
+	return &room
+}
@@ -2,33 +2,327 @@ package mock
 
 import (
 	"sabiraliyev.net/snippetbox/pkg/models"
+	"strings"
 	"time"
 )
 
+var mockRevision = &models.Revision{
+	ID:        1,
+	SnippetID: 1,
+	Version:   1,
+	UserID:    1,
+	UserName:  "Alice",
+	Title:     "An old silent pond",
+	Content:   "An old silent pond...",
+	Created:   time.Now(),
+}
+
 var mockSnippet = &models.Snippet{
-	ID:      1,
-	Title:   "An old silent pond",
-	Content: "An old silent pond...",
-	Created: time.Now(),
-	Expires: time.Now(),
+	ID:         1,
+	Slug:       "pondpondpo",
+	UserID:     1,
+	Title:      "An old silent pond",
+	Content:    "An old silent pond...",
+	Created:    time.Now(),
+	Expires:    time.Now(),
+	Visibility: models.VisibilityPublic,
+	Tags:       []string{"poetry"},
+}
+
+var mockPrivateSnippet = &models.Snippet{
+	ID:         3,
+	Slug:       "tracetrace",
+	UserID:     2,
+	Title:      "Internal stack trace",
+	Content:    "panic: runtime error...",
+	Created:    time.Now(),
+	Expires:    time.Now(),
+	Visibility: models.VisibilityPrivate,
+	Tags:       []string{"poetry"},
+}
+
+var mockBurnSnippet = &models.Snippet{
+	ID:         4,
+	Slug:       "burnburnbu",
+	UserID:     2,
+	Title:      "Database password",
+	Content:    "correct horse battery staple",
+	Created:    time.Now(),
+	Expires:    time.Now(),
+	Visibility: models.VisibilityPublic,
+	MaxViews:   1,
+	Tags:       []string{"poetry"},
 }
 
+var mockUnlistedSnippet = &models.Snippet{
+	ID:         5,
+	Slug:       "hiddenhidd",
+	UserID:     2,
+	Title:      "Draft haiku",
+	Content:    "A hidden frog leaps...",
+	Created:    time.Now(),
+	Expires:    time.Now(),
+	Visibility: models.VisibilityUnlisted,
+	Tags:       []string{"poetry"},
+}
+
+// A snippet of Alice`s which has expired. It is no longer shown, but can still be moved to the trash.
+var mockExpiredSnippet = &models.Snippet{
+	ID:         7,
+	Slug:       "weatherwea",
+	UserID:     1,
+	Title:      "Yesterday`s weather",
+	Content:    "Rain, then more rain...",
+	Created:    time.Now().Add(-48 * time.Hour),
+	Expires:    time.Now().Add(-24 * time.Hour),
+	Visibility: models.VisibilityPublic,
+}
+
+// A snippet Alice has moved to her trash. It can be restored by her, or purged by an administrator.
+var mockDeletedSnippet = &models.Snippet{
+	ID:         6,
+	Slug:       "limericklm",
+	UserID:     1,
+	Title:      "Forgotten limerick",
+	Content:    "There once was a frog from Nantucket...",
+	Created:    time.Now(),
+	Expires:    time.Now(),
+	Deleted:    true,
+	Visibility: models.VisibilityPublic,
+}
+
+// Every live mock snippet, in the order listings return them. The private, view-limited and unlisted
+// snippets are tagged and searchable like the public one, so that listings and search results which
+// leak them are caught.
+var mockSnippets = []*models.Snippet{mockSnippet, mockPrivateSnippet, mockBurnSnippet, mockUnlistedSnippet}
+
+// The listed function reports whether a snippet may appear in public listings and search results:
+// only public snippets without a view limit may, as the others would give their content away.
+func listed(s *models.Snippet) bool {
+	return s.Visibility == models.VisibilityPublic && s.MaxViews == 0
+}
+
+var mockFile = &models.File{
+	Name:     "frog.sh",
+	Language: "shell",
+	Content:  "#!/bin/sh\necho splash",
+}
+
+// The SnippetModel mock keeps the last snippet inserted and the last expiry time set, so tests can
+// check what was saved. Every save of snippet 1 is recorded in Revised as a new revision, numbered
+// after mockRevision.
 type SnippetModel struct {
+	Inserted  *models.Snippet
+	Revised   []*models.Revision
+	NewExpiry *time.Time
 }
 
-func (m *SnippetModel) Insert(title, content, expires string) (int, error) {
-	return 2, nil
+func (m *SnippetModel) Insert(s *models.Snippet) error {
+	m.Inserted = s
+	s.ID = 2
+	s.Slug = "newsnippet"
+	s.Created = time.Now()
+	return nil
 }
 
 func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
+	for _, s := range mockSnippets {
+		if s.ID == id {
+			return s, nil
+		}
+	}
+	return nil, models.ErrNoRecord
+}
+
+func (m *SnippetModel) Find(id int) (*models.Snippet, error) {
+	if id == mockExpiredSnippet.ID {
+		return mockExpiredSnippet, nil
+	}
+	return m.Get(id)
+}
+
+func (m *SnippetModel) GetBySlug(slug string) (*models.Snippet, error) {
+	for _, s := range mockSnippets {
+		if s.Slug == slug {
+			return s, nil
+		}
+	}
+	return nil, models.ErrNoRecord
+}
+
+func (m *SnippetModel) View(slug string) (*models.Snippet, error) {
+	s, err := m.GetBySlug(slug)
+	if err != nil || s.MaxViews == 0 {
+		return s, err
+	}
+	viewed := *s
+	viewed.Views++
+	return &viewed, nil
+}
+
+// The first page of the listing has a next page, which is empty. Any other cursor is invalid.
+func (m *SnippetModel) List(opt models.ListOptions) (*models.SnippetPage, error) {
+	switch {
+	case opt.After == "bmV4dA":
+		return &models.SnippetPage{Snippets: []*models.Snippet{}, Prev: "cHJldg"}, nil
+	case opt.After != "" || opt.Before != "":
+		return nil, models.ErrInvalidCursor
+	}
+
+	page := &models.SnippetPage{Snippets: []*models.Snippet{}}
+	for _, s := range mockSnippets {
+		if !opt.All && !listed(s) {
+			continue
+		}
+		if opt.Tag != "" && !hasTag(s, opt.Tag) {
+			continue
+		}
+		page.Snippets = append(page.Snippets, s)
+	}
+	if len(page.Snippets) > 0 {
+		page.Next = "bmV4dA"
+	}
+	return page, nil
+}
+
+func hasTag(s *models.Snippet, tag string) bool {
+	for _, t := range s.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *SnippetModel) Search(query string, page int) (*models.SearchPage, error) {
+	results := []*models.SearchResult{}
+	for _, s := range mockSnippets {
+		if !listed(s) || !strings.Contains(strings.ToLower(s.Content), strings.ToLower(query)) {
+			continue
+		}
+		headline := s.Content
+		if s == mockSnippet {
+			headline = "An old " + models.HeadlineStart + "silent" + models.HeadlineStop + " pond <3"
+		}
+		results = append(results, &models.SearchResult{Snippet: s, Rank: 0.1, Headline: headline})
+	}
+	return &models.SearchPage{Results: results, Page: page}, nil
+}
+
+func (m *SnippetModel) ByUser(userID int) ([]*models.Snippet, error) {
+	switch userID {
+	case 1:
+		return []*models.Snippet{mockSnippet}, nil
+	default:
+		return []*models.Snippet{}, nil
+	}
+}
+
+func (m *SnippetModel) Delete(id int) error {
+	_, err := m.Find(id)
+	return err
+}
+
+func (m *SnippetModel) Restore(id, userID int) error {
+	if id == mockDeletedSnippet.ID && userID == mockDeletedSnippet.UserID {
+		return nil
+	}
+	return models.ErrNoRecord
+}
+
+func (m *SnippetModel) Purge(id int) error {
+	if id == mockDeletedSnippet.ID {
+		return nil
+	}
+	return models.ErrNoRecord
+}
+
+func (m *SnippetModel) Reap(grace time.Duration, limit int) (int, error) {
+	return 0, nil
+}
+
+func (m *SnippetModel) Trash(userID int) ([]*models.Snippet, error) {
+	if userID == mockDeletedSnippet.UserID {
+		return []*models.Snippet{mockDeletedSnippet}, nil
+	}
+	return []*models.Snippet{}, nil
+}
+
+func (m *SnippetModel) Deleted() ([]*models.Snippet, error) {
+	return []*models.Snippet{mockDeletedSnippet}, nil
+}
+
+func (m *SnippetModel) Expired(from, to time.Time) ([]*models.Snippet, error) {
+	expired := []*models.Snippet{}
+	for _, s := range []*models.Snippet{mockSnippet, mockPrivateSnippet, mockBurnSnippet} {
+		if s.Expires.After(from) && !s.Expires.After(to) {
+			expired = append(expired, s)
+		}
+	}
+	return expired, nil
+}
+
+func (m *SnippetModel) Update(s *models.Snippet, userID int) error {
+	switch s.ID {
+	case 1:
+		m.Revised = append(m.Revised, &models.Revision{
+			ID:        len(m.Revised) + 2,
+			SnippetID: s.ID,
+			Version:   len(m.Revised) + 2,
+			UserID:    userID,
+			Title:     s.Title,
+			Filename:  s.Filename,
+			Content:   s.Content,
+			Files:     s.Files,
+			Created:   time.Now(),
+		})
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
+}
+
+func (m *SnippetModel) Revisions(id int) ([]*models.Revision, error) {
 	switch id {
 	case 1:
-		return mockSnippet, nil
+		revisions := []*models.Revision{}
+		for i := len(m.Revised) - 1; i >= 0; i-- {
+			revisions = append(revisions, m.Revised[i])
+		}
+		return append(revisions, mockRevision), nil
 	default:
+		return []*models.Revision{}, nil
+	}
+}
+
+func (m *SnippetModel) Revision(id, version int) (*models.Revision, error) {
+	if id != 1 {
 		return nil, models.ErrNoRecord
 	}
+	if version == 1 {
+		return mockRevision, nil
+	}
+	if version >= 2 && version-2 < len(m.Revised) {
+		return m.Revised[version-2], nil
+	}
+	return nil, models.ErrNoRecord
+}
+
+func (m *SnippetModel) SetExpires(id int, expires time.Time) error {
+	switch id {
+	case 1:
+		m.NewExpiry = &expires
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
 }
 
-func (m *SnippetModel) Latest() ([]*models.Snippet, error) {
-	return []*models.Snippet{mockSnippet}, nil
+func (m *SnippetModel) Files(snippetID int) ([]*models.File, error) {
+	switch snippetID {
+	case 1:
+		return []*models.File{mockFile}, nil
+	default:
+		return []*models.File{}, nil
+	}
 }
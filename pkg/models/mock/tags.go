@@ -0,0 +1,11 @@
+package mock
+
+import (
+	"sabiraliyev.net/snippetbox/pkg/models"
+)
+
+type TagModel struct{}
+
+func (m *TagModel) Cloud(limit int) ([]*models.Tag, error) {
+	return []*models.Tag{{Name: "poetry", Count: 1}}, nil
+}
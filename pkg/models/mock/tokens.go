@@ -0,0 +1,98 @@
+package mock
+
+import (
+	"sync"
+	"time"
+
+	"sabiraliyev.net/snippetbox/pkg/models"
+)
+
+var mockToken = &models.Token{
+	ID:       1,
+	UserID:   1,
+	Name:     "laptop",
+	Scopes:   []string{models.ScopeSnippetsRead, models.ScopeSnippetsWrite},
+	Created:  time.Now(),
+	LastUsed: time.Now(),
+}
+
+var mockReadOnlyToken = &models.Token{
+	ID:      2,
+	UserID:  1,
+	Name:    "dashboard",
+	Scopes:  []string{models.ScopeSnippetsRead},
+	Created: time.Now(),
+	Expires: time.Now().Add(24 * time.Hour),
+}
+
+var mockExpiredToken = &models.Token{
+	ID:      3,
+	UserID:  1,
+	Name:    "old laptop",
+	Scopes:  []string{models.ScopeSnippetsRead, models.ScopeSnippetsWrite},
+	Created: time.Now().Add(-48 * time.Hour),
+	Expires: time.Now().Add(-24 * time.Hour),
+}
+
+// The text of each mock token.
+var mockTokens = map[string]*models.Token{
+	"sb_alice":    mockToken,
+	"sb_readonly": mockReadOnlyToken,
+	"sb_expired":  mockExpiredToken,
+}
+
+// TokenModel works like the real one: expired and revoked tokens don`t authenticate, and the time
+// each token was last used is recorded.
+type TokenModel struct {
+	mu       sync.Mutex
+	revoked  map[int]bool
+	lastUsed map[int]time.Time
+}
+
+func (m *TokenModel) Insert(userID int, name string, scopes []string, expires time.Time) (string, error) {
+	return "sb_newtoken", nil
+}
+
+func (m *TokenModel) Authenticate(token string) (*models.Token, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, ok := mockTokens[token]
+	if !ok || m.revoked[t.ID] || (!t.Expires.IsZero() && t.Expires.Before(time.Now())) {
+		return nil, models.ErrInvalidCredentials
+	}
+	if m.lastUsed == nil {
+		m.lastUsed = map[int]time.Time{}
+	}
+	m.lastUsed[t.ID] = time.Now()
+	return t, nil
+}
+
+// LastUsed returns when the token with the given ID last authenticated a request, or the zero time
+// if it hasn`t.
+func (m *TokenModel) LastUsed(id int) time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lastUsed[id]
+}
+
+func (m *TokenModel) ByUser(userID int) ([]*models.Token, error) {
+	if userID == 1 {
+		return []*models.Token{mockToken, mockReadOnlyToken}, nil
+	}
+	return []*models.Token{}, nil
+}
+
+func (m *TokenModel) Delete(id, userID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if (id == 1 || id == 2) && userID == 1 && !m.revoked[id] {
+		if m.revoked == nil {
+			m.revoked = map[int]bool{}
+		}
+		m.revoked[id] = true
+		return nil
+	}
+	return models.ErrNoRecord
+}
@@ -13,6 +13,23 @@ var mockUser = &models.User{
 	Active:  true,
 }
 
+var mockFriend = &models.User{
+	ID:      2,
+	Name:    "Bob",
+	Email:   "bob@example.com",
+	Created: time.Now(),
+	Active:  true,
+}
+
+var mockAdministrator = &models.User{
+	ID:            3,
+	Name:          "Dave",
+	Email:         "admin@example.com",
+	Created:       time.Now(),
+	Active:        true,
+	Administrator: true,
+}
+
 type UserModel struct {
 }
 
@@ -29,6 +46,8 @@ func (m *UserModel) Authenticate(email, password string) (int, error) {
 	switch email {
 	case "alice@example.com":
 		return 1, nil
+	case "admin@example.com":
+		return 3, nil
 	default:
 		return 0, models.ErrInvalidCredentials
 	}
@@ -38,6 +57,23 @@ func (m *UserModel) Get(id int) (*models.User, error) {
 	switch id {
 	case 1:
 		return mockUser, nil
+	case 2:
+		return mockFriend, nil
+	case 3:
+		return mockAdministrator, nil
+	default:
+		return nil, models.ErrNoRecord
+	}
+}
+
+func (m *UserModel) GetByEmail(email string) (*models.User, error) {
+	switch email {
+	case "alice@example.com":
+		return mockUser, nil
+	case "bob@example.com":
+		return mockFriend, nil
+	case "admin@example.com":
+		return mockAdministrator, nil
 	default:
 		return nil, models.ErrNoRecord
 	}
@@ -11,29 +11,205 @@ var (
 	ErrInvalidCredentials = errors.New("models: invalid credentials")
 	// The error about duplicate emails.
 	ErrDuplicvateEmail = errors.New("models: duplicate email")
+	// The error about a malformed page cursor or sort order.
+	ErrInvalidCursor = errors.New("models: invalid cursor")
+)
+
+// Snippet visibility levels. Public snippets are listed on the home page, unlisted snippets can only
+// be reached by someone who has the link, and private snippets are only visible to their owner
+// and administrators.
+const (
+	VisibilityPublic   = "public"
+	VisibilityUnlisted = "unlisted"
+	VisibilityPrivate  = "private"
 )
 
 type Snippet struct {
-	ID      int
-	Title   string
-	Content string
+	ID     int    `json:"id"`
+	Slug   string `json:"slug"`
+	UserID int    `json:"user_id"`
+	Title  string `json:"title"`
+	// Filename is the name of the snippet`s first file, whose content is Content, or empty if the
+	// author didn`t name it.
+	Filename string    `json:"filename"`
+	Content  string    `json:"content"`
+	Created  time.Time `json:"created"`
+	// Expires is the zero time for snippets which never expire.
+	Expires    time.Time `json:"expires"`
+	Deleted    bool      `json:"-"`
+	Visibility string    `json:"visibility"`
+	// MaxViews is the number of times the snippet can be viewed before it is gone for good,
+	// or 0 if it can be viewed any number of times. Views counts the views used up so far.
+	MaxViews int `json:"max_views"`
+	Views    int `json:"views"`
+	// Tags are kept in alphabetical order.
+	Tags []string `json:"tags"`
+	// Language is the name of the language the content is highlighted as, or empty for plain text.
+	// LanguageConfidence is 1 if the author chose the language, or between 0 and 1 if it was guessed.
+	Language           string  `json:"language"`
+	LanguageConfidence float64 `json:"language_confidence"`
+	// Files are the snippet`s further files, in order. They are stored by Insert and Update, but
+	// aren`t loaded with the snippet; use Files to get them.
+	Files []*File `json:"files,omitempty"`
+}
+
+// A File is one of the named files of a multi-file snippet, with the language it is highlighted as.
+type File struct {
+	Name     string `json:"name"`
+	Language string `json:"language"`
+	Content  string `json:"content"`
+}
+
+// A Tag is the name of a tag together with the number of live public snippets which have it.
+type Tag struct {
+	Name  string
+	Count int
+}
+
+// Sort orders for snippet listings.
+const (
+	SortNewest   = "newest"
+	SortOldest   = "oldest"
+	SortExpiring = "expiring"
+	SortTitle    = "title"
+)
+
+// ListOptions selects one page of a snippet listing. After and Before are cursors taken from
+// the Next and Prev fields of a previous SnippetPage; at most one of them should be set.
+type ListOptions struct {
+	Sort   string
+	After  string
+	Before string
+	Limit  int
+	// All includes unlisted, private and view-limited snippets, which are otherwise left out of listings.
+	All bool
+	// Tag, if set, only includes snippets with that tag.
+	Tag string
+}
+
+// A SnippetPage is one page of a snippet listing. Next and Prev are the cursors of the
+// neighbouring pages, or empty if there is no such page.
+type SnippetPage struct {
+	Snippets []*Snippet
+	Next     string
+	Prev     string
+}
+
+// The markers placed around the matching words in the Headline of a SearchResult. They are control
+// characters, so they can`t be confused with anything in the snippet itself.
+const (
+	HeadlineStart = "\x02"
+	HeadlineStop  = "\x03"
+)
+
+// A SearchResult is a snippet matching a search query. Headline holds excerpts of the snippet`s
+// content with the matching words wrapped in HeadlineStart and HeadlineStop; it isn`t HTML escaped.
+type SearchResult struct {
+	Snippet  *Snippet
+	Rank     float64
+	Headline string
+}
+
+// A SearchPage is one page of search results, numbered from 1. More is set if there are further pages.
+type SearchPage struct {
+	Results []*SearchResult
+	Page    int
+	More    bool
+}
+
+// User roles, used to look up the limits which apply to a user.
+const (
+	RoleUser          = "user"
+	RoleAdministrator = "administrator"
+)
+
+// A Revision is an immutable copy of a snippet`s title and files, stored every time the snippet is saved.
+type Revision struct {
+	ID        int
+	SnippetID int
+	Version   int
+	UserID    int
+	UserName  string
+	Title     string
+	Filename  string
+	Content   string
+	// Files are the further files as they were at this revision. They are loaded by Revision,
+	// but not by Revisions.
+	Files   []*File
 	Created time.Time
-	Expires time.Time
 }
 
+// The scopes an API token can be granted. Tokens can only do what their scopes allow, and the
+// admin scope only gives administrators` tokens their administrator powers.
+const (
+	ScopeSnippetsRead  = "snippets:read"
+	ScopeSnippetsWrite = "snippets:write"
+	ScopeAdmin         = "admin"
+)
+
+// Scopes lists every scope, in the order they should be offered to users.
+var Scopes = []string{ScopeSnippetsRead, ScopeSnippetsWrite, ScopeAdmin}
+
+// A Token is a personal API token. The token itself is only shown once, when it is created; just
+// its hash is stored. Expires is the zero time for tokens which never expire, and LastUsed is the
+// zero time for tokens which haven`t been used yet.
+type Token struct {
+	ID       int
+	UserID   int
+	Name     string
+	Scopes   []string
+	Created  time.Time
+	Expires  time.Time
+	LastUsed time.Time
+}
+
+// A Message is a message posted to the chat. UserName, the name of its author, isn`t stored with the
+// message; it is filled in from the users table when the message is shown.
 type Message struct {
-	ID      int
-	UserId  int
-	Content string
-	Created time.Time
+	ID       int       `json:"id"`
+	RoomID   int       `json:"room_id"`
+	UserId   int       `json:"user_id"`
+	UserName string    `json:"user_name"`
+	Content  string    `json:"content"`
+	Created  time.Time `json:"created"`
+}
+
+// Chat room kinds. Anybody can read and join a public room, private rooms are only open to the
+// members invited to them, and a direct room is the conversation between two users.
+const (
+	RoomPublic  = "public"
+	RoomPrivate = "private"
+	RoomDirect  = "direct"
+)
+
+// GeneralRoom is the ID of the public room everybody starts out in. It holds the messages posted
+// before the chat had rooms.
+const GeneralRoom = 1
+
+// A Room is a chat room. Direct rooms have no name of their own, so Name holds the name of the
+// other user when rooms are listed for a user, and Unread the number of messages they haven`t read.
+type Room struct {
+	ID      int       `json:"id"`
+	Kind    string    `json:"kind"`
+	Name    string    `json:"name"`
+	Created time.Time `json:"created"`
+	Member  bool      `json:"member"`
+	Unread  int       `json:"unread"`
+}
+
+// A MessagePage is a page of chat messages, oldest first. Before is the ID to pass back to fetch the
+// page of older messages, or 0 if there are none.
+type MessagePage struct {
+	Messages []*Message
+	Before   int
 }
 
 type User struct {
-	ID             int
-	Name           string
-	Email          string
-	HashedPassword []byte
-	Created        time.Time
-	Active         bool
-	Administrator  bool
+	ID             int       `json:"id"`
+	Name           string    `json:"name"`
+	Email          string    `json:"email"`
+	HashedPassword []byte    `json:"-"`
+	Created        time.Time `json:"created"`
+	Active         bool      `json:"active"`
+	Administrator  bool      `json:"administrator"`
 }
@@ -0,0 +1,53 @@
+package mysql
+
+import (
+	"database/sql"
+
+	"sabiraliyev.net/snippetbox/pkg/models"
+)
+
+// Store the further files of a snippet, numbering their positions from 1 in the given order.
+func insertFiles(tx *sql.Tx, snippetID int, files []*models.File) error {
+	stmt := `INSERT INTO snippet_files (snippet_id, position, name, language, content) VALUES($1, $2, $3, $4, $5)`
+	for i, f := range files {
+		_, err := tx.Exec(stmt, snippetID, i+1, f.Name, f.Language, f.Content)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Return the further files of a snippet in order. A snippet with just the one file has none.
+func (m *SnippetModel) Files(snippetID int) ([]*models.File, error) {
+	stmt := `SELECT name, language, content FROM snippet_files WHERE snippet_id = $1 ORDER BY position`
+	return m.queryFiles(stmt, snippetID)
+}
+
+// Return the further files of a snippet as they were at the given revision, in order.
+func (m *SnippetModel) revisionFiles(revisionID int) ([]*models.File, error) {
+	stmt := `SELECT name, language, content FROM snippet_revision_files WHERE revision_id = $1 ORDER BY position`
+	return m.queryFiles(stmt, revisionID)
+}
+
+func (m *SnippetModel) queryFiles(stmt string, args ...interface{}) ([]*models.File, error) {
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	files := []*models.File{}
+	for rows.Next() {
+		f := &models.File{}
+		err = rows.Scan(&f.Name, &f.Language, &f.Content)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
@@ -0,0 +1,62 @@
+package mysql
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"sabiraliyev.net/snippetbox/pkg/models"
+)
+
+// ExpiryLimitModel wraps a sql.DB connection pool. It stores, for every user role, the longest time
+// a snippet created by a user with that role may live for.
+type ExpiryLimitModel struct {
+	DB *sql.DB
+}
+
+// Get returns the expiry limit of a role. A zero duration means there is no limit, so users with
+// the role may create snippets which never expire.
+func (m *ExpiryLimitModel) Get(role string) (time.Duration, error) {
+	var seconds int64
+	stmt := `SELECT max_seconds FROM expiry_limits WHERE role = $1`
+	err := m.DB.QueryRow(stmt, role).Scan(&seconds)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, models.ErrNoRecord
+		}
+		return 0, err
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// Set changes the expiry limit of a role, creating it if the role doesn`t have one yet.
+func (m *ExpiryLimitModel) Set(role string, max time.Duration) error {
+	stmt := `INSERT INTO expiry_limits (role, max_seconds) VALUES ($1, $2)
+	ON CONFLICT (role) DO UPDATE SET max_seconds = EXCLUDED.max_seconds`
+	_, err := m.DB.Exec(stmt, role, int64(max/time.Second))
+	return err
+}
+
+// All returns the expiry limits of every role which has one.
+func (m *ExpiryLimitModel) All() (map[string]time.Duration, error) {
+	rows, err := m.DB.Query(`SELECT role, max_seconds FROM expiry_limits`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	limits := map[string]time.Duration{}
+	for rows.Next() {
+		var role string
+		var seconds int64
+		err = rows.Scan(&role, &seconds)
+		if err != nil {
+			return nil, err
+		}
+		limits[role] = time.Duration(seconds) * time.Second
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return limits, nil
+}
@@ -0,0 +1,82 @@
+package mysql
+
+import (
+	"database/sql"
+	"errors"
+
+	"sabiraliyev.net/snippetbox/pkg/models"
+)
+
+// MessageModel wraps a sql.DB connection pool and manages the messages posted to the chat.
+type MessageModel struct {
+	DB *sql.DB
+}
+
+// Insert stores a new message from a user in a room and returns its ID.
+func (m *MessageModel) Insert(roomID, userID int, content string) (int, error) {
+	stmt := `INSERT INTO messages (room_id, user_id, content, created) VALUES($1, $2, $3, NOW()) RETURNING id`
+
+	var id int
+	err := m.DB.QueryRow(stmt, roomID, userID, content).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// Get returns the message with the given ID, or ErrNoRecord if there is no such message.
+func (m *MessageModel) Get(id int) (*models.Message, error) {
+	stmt := `SELECT id, room_id, user_id, content, created FROM messages WHERE id = $1`
+
+	msg := &models.Message{}
+	err := m.DB.QueryRow(stmt, id).Scan(&msg.ID, &msg.RoomID, &msg.UserId, &msg.Content, &msg.Created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, err
+	}
+	return msg, nil
+}
+
+// Latest returns a page of up to limit messages from a room, oldest first. If before is 0 the page holds the
+// latest messages, otherwise the messages posted just before the one with that ID. Like snippet
+// listings, pages are found by key rather than OFFSET, so new messages don`t shift older pages.
+func (m *MessageModel) Latest(roomID, before, limit int) (*models.MessagePage, error) {
+	// One extra message is fetched to find out whether there is an older page.
+	stmt := `SELECT id, room_id, user_id, content, created FROM messages
+	WHERE room_id = $1 AND ($2 = 0 OR id < $2)
+	ORDER BY id DESC LIMIT $3`
+
+	rows, err := m.DB.Query(stmt, roomID, before, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := []*models.Message{}
+	for rows.Next() {
+		msg := &models.Message{}
+		err = rows.Scan(&msg.ID, &msg.RoomID, &msg.UserId, &msg.Content, &msg.Created)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	page := &models.MessagePage{}
+	if len(messages) > limit {
+		messages = messages[:limit]
+		page.Before = messages[limit-1].ID
+	}
+
+	// The query finds the newest messages first, but they are shown oldest first.
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+	page.Messages = messages
+	return page, nil
+}
@@ -0,0 +1,166 @@
+package mysql
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"sabiraliyev.net/snippetbox/pkg/models"
+)
+
+// The defaultPageSize constant is the number of snippets on a page when ListOptions.Limit isn`t set.
+const defaultPageSize = 10
+
+// The cursorTimeLayout is how times are written into cursors. PostgreSQL timestamps have microsecond
+// precision and no time zone, so this layout survives the round trip exactly.
+const cursorTimeLayout = "2006-01-02 15:04:05.999999"
+
+// A sortOrder describes how a listing is ordered. The id column is always used as the tie-breaker, so
+// every row has a unique position and a cursor (the sort key and id of a row) marks a page boundary
+// precisely, however many rows are inserted or deleted in the meantime.
+type sortOrder struct {
+	key  string // The SQL expression rows are sorted by.
+	cast string // The SQL type the key of a cursor is cast to.
+	desc bool
+	// The value function returns the sort key of a snippet, as it is written into a cursor.
+	value func(*models.Snippet) string
+}
+
+func createdKey(s *models.Snippet) string {
+	return s.Created.Format(cursorTimeLayout)
+}
+
+// Snippets which never expire sort after all the others when ordered by expiry.
+func expiresKey(s *models.Snippet) string {
+	if s.Expires.IsZero() {
+		return "infinity"
+	}
+	return s.Expires.Format(cursorTimeLayout)
+}
+
+var sortOrders = map[string]sortOrder{
+	models.SortNewest:   {"created", "timestamp", true, createdKey},
+	models.SortOldest:   {"created", "timestamp", false, createdKey},
+	models.SortExpiring: {"COALESCE(expires, 'infinity')", "timestamp", false, expiresKey},
+	models.SortTitle:    {"title", "text", false, func(s *models.Snippet) string { return s.Title }},
+}
+
+// The encodeCursor function returns an opaque, URL-safe cursor for the position of a row.
+func encodeCursor(value string, id int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(value + "|" + strconv.Itoa(id)))
+}
+
+// The decodeCursor function returns the sort key and id held by a cursor. The id comes after the
+// last "|", so the key itself may contain the separator (as titles can).
+func decodeCursor(cursor string) (string, int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", 0, models.ErrInvalidCursor
+	}
+	i := strings.LastIndexByte(string(b), '|')
+	if i < 0 {
+		return "", 0, models.ErrInvalidCursor
+	}
+	id, err := strconv.Atoi(string(b[i+1:]))
+	if err != nil || id < 1 {
+		return "", 0, models.ErrInvalidCursor
+	}
+	return string(b[:i]), id, nil
+}
+
+// This will return one page of live snippets, using keyset pagination: rather than skipping over an
+// OFFSET of rows, the query starts right after (or before) the row the cursor points at, so fetching
+// a page deep into the listing costs no more than fetching the first one.
+func (m *SnippetModel) List(opt models.ListOptions) (*models.SnippetPage, error) {
+	order, ok := sortOrders[opt.Sort]
+	if !ok {
+		return nil, models.ErrInvalidCursor
+	}
+	limit := opt.Limit
+	if limit <= 0 {
+		limit = defaultPageSize
+	}
+
+	// Paging backwards walks the listing in reverse from the cursor. The rows are put back
+	// the right way round below.
+	backwards := opt.Before != ""
+	cursor := opt.After
+	if backwards {
+		cursor = opt.Before
+	}
+	cmp, dir := ">", "ASC"
+	if order.desc != backwards {
+		cmp, dir = "<", "DESC"
+	}
+
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets WHERE ` + live
+	var args []interface{}
+	// The arg function adds a value to args and returns the placeholder to use for it.
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	// Listings show the content of snippets, so view-limited snippets are left out along with unlisted
+	// and private ones: they could otherwise be read without using up a view.
+	if !opt.All {
+		stmt += ` AND visibility = 'public' AND max_views = 0`
+	}
+	if opt.Tag != "" {
+		stmt += ` AND id IN (SELECT st.snippet_id FROM snippet_tags st JOIN tags t ON t.id = st.tag_id
+		WHERE t.name = ` + arg(opt.Tag) + `)`
+	}
+	if cursor != "" {
+		value, id, err := decodeCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		if order.cast == "timestamp" && value != "infinity" {
+			if _, err := time.Parse(cursorTimeLayout, value); err != nil {
+				return nil, models.ErrInvalidCursor
+			}
+		}
+		stmt += fmt.Sprintf(` AND (%s, id) %s (%s::%s, %s)`, order.key, cmp, arg(value), order.cast, arg(id))
+	}
+
+	// Fetch one extra row to find out whether there is another page beyond this one.
+	stmt += fmt.Sprintf(` ORDER BY %s %s, id %s LIMIT %s`, order.key, dir, dir, arg(limit+1))
+
+	snippets, err := m.query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	more := len(snippets) > limit
+	if more {
+		snippets = snippets[:limit]
+	}
+	if backwards {
+		for i, j := 0, len(snippets)-1; i < j; i, j = i+1, j-1 {
+			snippets[i], snippets[j] = snippets[j], snippets[i]
+		}
+	}
+
+	page := &models.SnippetPage{Snippets: snippets}
+	if len(snippets) == 0 {
+		return page, nil
+	}
+	first := snippets[0]
+	last := snippets[len(snippets)-1]
+
+	// Coming from a cursor means there is a page on the side we came from.
+	if backwards {
+		page.Next = encodeCursor(order.value(last), last.ID)
+		if more {
+			page.Prev = encodeCursor(order.value(first), first.ID)
+		}
+	} else {
+		if more {
+			page.Next = encodeCursor(order.value(last), last.ID)
+		}
+		if opt.After != "" {
+			page.Prev = encodeCursor(order.value(first), first.ID)
+		}
+	}
+	return page, nil
+}
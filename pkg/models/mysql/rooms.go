@@ -0,0 +1,208 @@
+package mysql
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"sabiraliyev.net/snippetbox/pkg/models"
+)
+
+// RoomModel wraps a sql.DB connection pool and manages chat rooms and their members.
+type RoomModel struct {
+	DB *sql.DB
+}
+
+// The columns every room query selects, as seen by the user with ID $1, in the order scanRoom()
+// expects them. Direct rooms are named after the other user in them.
+const roomColumns = `r.id, r.kind,
+	COALESCE(r.name, (SELECT u.name FROM room_members o JOIN users u ON u.id = o.user_id
+		WHERE o.room_id = r.id AND o.user_id <> $1 LIMIT 1), ''),
+	r.created, m.user_id IS NOT NULL,
+	CASE WHEN m.user_id IS NULL THEN 0 ELSE (SELECT COUNT(*) FROM messages msg
+		WHERE msg.room_id = r.id AND msg.id > m.last_read AND msg.user_id <> $1) END`
+
+func scanRoom(row scanner) (*models.Room, error) {
+	room := &models.Room{}
+	err := row.Scan(&room.ID, &room.Kind, &room.Name, &room.Created, &room.Member, &room.Unread)
+	if err != nil {
+		return nil, err
+	}
+	return room, nil
+}
+
+// Insert creates a public or private room, with the user who created it as its first member, and
+// returns its ID.
+func (m *RoomModel) Insert(userID int, kind, name string) (int, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	// Rollback is a no-op once the transaction has been committed.
+	defer tx.Rollback()
+
+	var id int
+	stmt := `INSERT INTO rooms (kind, name, created_by, created) VALUES($1, $2, $3, NOW()) RETURNING id`
+	err = tx.QueryRow(stmt, kind, name, userID).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	stmt = `INSERT INTO room_members (room_id, user_id, joined) VALUES($1, $2, NOW())`
+	_, err = tx.Exec(stmt, id, userID)
+	if err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+// Direct returns the ID of the direct room between two users, creating it the first time they
+// talk to each other.
+func (m *RoomModel) Direct(userID, otherID int) (int, error) {
+	if userID > otherID {
+		userID, otherID = otherID, userID
+	}
+	key := fmt.Sprintf("%d:%d", userID, otherID)
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// The direct key is unique, so two users starting a conversation at the same moment end up in
+	// the same room: the second insert does nothing, and the room is selected instead.
+	var id int
+	stmt := `INSERT INTO rooms (kind, direct_key, created_by, created) VALUES($1, $2, $3, NOW())
+	ON CONFLICT (direct_key) DO NOTHING RETURNING id`
+	err = tx.QueryRow(stmt, models.RoomDirect, key, userID).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = tx.QueryRow(`SELECT id FROM rooms WHERE direct_key = $1`, key).Scan(&id)
+		if err != nil {
+			return 0, err
+		}
+		return id, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	stmt = `INSERT INTO room_members (room_id, user_id, joined) VALUES($1, $2, NOW()), ($1, $3, NOW())`
+	_, err = tx.Exec(stmt, id, userID, otherID)
+	if err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+// Get returns a room as seen by the user with the given ID (0 for anonymous visitors), or
+// ErrNoRecord if there is no such room.
+func (m *RoomModel) Get(id, userID int) (*models.Room, error) {
+	stmt := `SELECT ` + roomColumns + ` FROM rooms r
+	LEFT JOIN room_members m ON m.room_id = r.id AND m.user_id = $1
+	WHERE r.id = $2`
+
+	room, err := scanRoom(m.DB.QueryRow(stmt, userID, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, err
+	}
+	return room, nil
+}
+
+// ForUser returns the rooms a user is a member of, with the number of messages they haven`t read
+// in each. Public and private rooms come first, by name, then direct rooms.
+func (m *RoomModel) ForUser(userID int) ([]*models.Room, error) {
+	stmt := `SELECT ` + roomColumns + ` FROM rooms r
+	JOIN room_members m ON m.room_id = r.id AND m.user_id = $1
+	ORDER BY r.kind = 'direct', LOWER(r.name), r.id`
+	return m.list(stmt, userID)
+}
+
+// Public returns the public rooms a user hasn`t joined yet, by name. Anonymous visitors, with a
+// user ID of 0, get every public room.
+func (m *RoomModel) Public(userID int) ([]*models.Room, error) {
+	stmt := `SELECT ` + roomColumns + ` FROM rooms r
+	LEFT JOIN room_members m ON m.room_id = r.id AND m.user_id = $1
+	WHERE r.kind = 'public' AND m.user_id IS NULL
+	ORDER BY LOWER(r.name), r.id`
+	return m.list(stmt, userID)
+}
+
+func (m *RoomModel) list(stmt string, args ...interface{}) ([]*models.Room, error) {
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rooms := []*models.Room{}
+	for rows.Next() {
+		room, err := scanRoom(rows)
+		if err != nil {
+			return nil, err
+		}
+		rooms = append(rooms, room)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return rooms, nil
+}
+
+// AddMember adds a user to a room, when they join it or are invited to it. The messages already in
+// the room count as read, so a new member doesn`t start out with the whole history unread. Adding
+// someone who is already a member does nothing.
+func (m *RoomModel) AddMember(roomID, userID int) error {
+	stmt := `INSERT INTO room_members (room_id, user_id, last_read, joined)
+	VALUES($1, $2, (SELECT COALESCE(MAX(id), 0) FROM messages WHERE room_id = $1), NOW())
+	ON CONFLICT (room_id, user_id) DO NOTHING`
+	_, err := m.DB.Exec(stmt, roomID, userID)
+	return err
+}
+
+// Members returns the IDs of the members of a room.
+func (m *RoomModel) Members(roomID int) ([]int, error) {
+	rows, err := m.DB.Query(`SELECT user_id FROM room_members WHERE room_id = $1`, roomID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		err = rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// MarkRead records that a member of a room has read the messages up to the one with the given ID.
+// Messages can be marked read out of order, from different tabs, so last_read never goes back. It
+// returns ErrNoRecord if the user isn`t a member of the room or the message wasn`t posted in it, so
+// that a room can`t be marked read up to a later message from another room.
+func (m *RoomModel) MarkRead(roomID, userID, messageID int) error {
+	stmt := `UPDATE room_members SET last_read = GREATEST(last_read, msg.id) FROM messages msg
+	WHERE msg.id = $3 AND msg.room_id = $1 AND room_members.room_id = $1 AND room_members.user_id = $2`
+	result, err := m.DB.Exec(stmt, roomID, userID, messageID)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return models.ErrNoRecord
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package mysql
+
+import (
+	"sabiraliyev.net/snippetbox/pkg/models"
+)
+
+// The searchPageSize constant is the number of results on each page of search results.
+const searchPageSize = 10
+
+// The options passed to ts_headline(): mark the matching words with the models.HeadlineStart and
+// models.HeadlineStop characters, and show up to three short fragments of the content.
+const headlineOptions = "StartSel=" + models.HeadlineStart + ", StopSel=" + models.HeadlineStop +
+	", MaxFragments=3, MaxWords=20, MinWords=5, FragmentDelimiter=\" … \""
+
+// The extraScanner type scans the snippetColumns of a row into a snippet, as scanSnippet does, and any
+// columns following them into extra.
+type extraScanner struct {
+	scanner
+	extra []interface{}
+}
+
+func (s extraScanner) Scan(dest ...interface{}) error {
+	return s.scanner.Scan(append(dest, s.extra...)...)
+}
+
+// This will return a page of the live public snippets matching a web-search style query (words,
+// "quoted phrases", OR and -excluded words), best matches first. Unlisted and private snippets are
+// never returned, and neither are view-limited ones, whose headlines would give away their content
+// without using up a view.
+func (m *SnippetModel) Search(query string, page int) (*models.SearchPage, error) {
+	if page < 1 {
+		page = 1
+	}
+
+	stmt := `SELECT ` + snippetColumns + `, ts_rank(search, q), ts_headline('english', content, q, $2)
+	FROM snippets, websearch_to_tsquery('english', $1) q
+	WHERE ` + live + ` AND visibility = 'public' AND max_views = 0 AND search @@ q
+	ORDER BY ts_rank(search, q) DESC, id DESC LIMIT $3 OFFSET $4`
+
+	// Fetch one extra row to find out whether there is another page of results.
+	rows, err := m.DB.Query(stmt, query, headlineOptions, searchPageSize+1, (page-1)*searchPageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []*models.SearchResult{}
+	for rows.Next() {
+		r := &models.SearchResult{}
+		r.Snippet, err = scanSnippet(extraScanner{rows, []interface{}{&r.Rank, &r.Headline}})
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	more := len(results) > searchPageSize
+	if more {
+		results = results[:searchPageSize]
+	}
+	return &models.SearchPage{Results: results, Page: page, More: more}, nil
+}
@@ -1,10 +1,12 @@
 package mysql
 
 import (
+	"crypto/rand"
 	"database/sql"
 	"errors"
-	"log"
-	"strconv"
+	"time"
+
+	"github.com/lib/pq"
 
 	// Import the models package we crated. You need to prefix this with
 	// whatever module path you set up back in chapter 02.02 (Project Setup and
@@ -13,69 +15,351 @@ import (
 	"sabiraliyev.net/snippetbox/pkg/models"
 )
 
+// The columns every snippet query selects, in the order scanSnippet() expects them.
+// Snippets created before ownership was recorded have a NULL user_id, which we read as 0.
+// The names of the snippet`s tags are gathered into an array by the subquery at the end.
+const snippetColumns = `id, slug, COALESCE(user_id, 0), title, filename, content, created, expires, deleted, visibility,
+	max_views, views, language, language_confidence, ARRAY(SELECT t.name FROM snippet_tags st JOIN tags t ON t.id = st.tag_id
+	WHERE st.snippet_id = snippets.id ORDER BY t.name)`
+
+// The condition matching live snippets: those which haven`t expired, been deleted or used up their views.
+const live = `(expires IS NULL OR expires > NOW()) AND deleted = FALSE AND (max_views = 0 OR views < max_views)`
+
 // Define a SnippetModule type which wraps a sql.DB connection pool.
 type SnippetModel struct {
 	DB *sql.DB
 }
 
-// This will insert a new snippet into database.
-func (m *SnippetModel) Insert(title, content, expires string) (int, error) {
+// The scanner interface is satisfied by both *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// Copy the snippetColumns of the current row into a new Snippet struct.
+func scanSnippet(row scanner) (*models.Snippet, error) {
+	s := &models.Snippet{}
+	var expires sql.NullTime
+	err := row.Scan(&s.ID, &s.Slug, &s.UserID, &s.Title, &s.Filename, &s.Content, &s.Created, &expires, &s.Deleted, &s.Visibility,
+		&s.MaxViews, &s.Views, &s.Language, &s.LanguageConfidence, pq.Array(&s.Tags))
+	if err != nil {
+		return nil, err
+	}
+	s.Expires = expires.Time
+	return s, nil
+}
+
+// The slugAlphabet holds the 64 URL-safe characters snippet slugs are made of, and slugLength is
+// the number of characters in a slug. 64^10 possible slugs make them impractical to guess.
+const (
+	slugAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_"
+	slugLength   = 10
+)
+
+// Generate a random slug using crypto/rand. Because the alphabet has exactly 64 characters,
+// the low six bits of every random byte pick a character without bias.
+func newSlug() (string, error) {
+	b := make([]byte, slugLength)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	for i := range b {
+		b[i] = slugAlphabet[b[i]&63]
+	}
+	return string(b), nil
+}
+
+// This will insert a new snippet into database. The UserID, Title, Filename, Content, Expires,
+// Visibility, MaxViews, Tags, Language, LanguageConfidence and Files fields are stored, and the ID, Slug and Created
+// fields are filled in. The snippet is given a random slug and its first revision is recorded in
+// the same transaction.
+func (m *SnippetModel) Insert(s *models.Snippet) error {
+	// A new slug colliding with an existing one is very unlikely, but if it happens the unique
+	// constraint rejects the insert and we simply try again with another slug.
+	for attempt := 1; ; attempt++ {
+		slug, err := newSlug()
+		if err != nil {
+			return err
+		}
+
+		err = m.insert(slug, s)
+		var pqError *pq.Error
+		if errors.As(err, &pqError) && pqError.Code == "23505" && pqError.Constraint == "snippets_uc_slug" && attempt < 3 {
+			continue
+		}
+		return err
+	}
+}
+
+func (m *SnippetModel) insert(slug string, s *models.Snippet) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once the transaction has been committed.
+	defer tx.Rollback()
+
 	// Write the SQL statement we want to execute. We split it over two lines
 	// for readability (which is why it`s surrounded with backquotes instead
 	// of normal double quotes).
-	stmt := `INSERT INTO snippets (title, content, created, expires) VALUES($1, $2, NOW(), NOW() + $3 * INTERVAL '1 DAY') RETURNING id`
+	stmt := `INSERT INTO snippets (slug, user_id, title, filename, content, created, expires, visibility, max_views,
+	language, language_confidence) VALUES($1, $2, $3, $4, $5, NOW(), NOW() + $6 * INTERVAL '1 SECOND', $7, $8, $9, $10) RETURNING id, created`
 
-	result, err := m.DB.Prepare(stmt)
+	// Use the Scan() method on the result object to get the ID and creation time of our
+	// newly inserted record in the snippets table.
+	err = tx.QueryRow(stmt, slug, s.UserID, s.Title, s.Filename, s.Content, secondsFromNow(s.Expires), s.Visibility,
+		s.MaxViews, s.Language, s.LanguageConfidence).Scan(&s.ID, &s.Created)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
-	// Use the Scan() method on the result object to get the ID of our
-	// newly inserted record in the snippets table.
-	var snippetId int
-	err = result.QueryRow(title, content, expires).Scan(&snippetId)
+	err = insertRevision(tx, s, s.UserID)
 	if err != nil {
-		return 0, err
+		return err
+	}
+
+	err = insertTags(tx, s.ID, s.Tags)
+	if err != nil {
+		return err
+	}
+
+	err = insertFiles(tx, s.ID, s.Files)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	s.Slug = slug
+	return nil
+}
+
+// Attach tags to a snippet, creating any tags which don`t exist yet.
+func insertTags(tx *sql.Tx, snippetID int, tags []string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	stmt := `INSERT INTO tags (name) SELECT unnest($1::text[]) ON CONFLICT (name) DO NOTHING`
+	_, err := tx.Exec(stmt, pq.Array(tags))
+	if err != nil {
+		return err
 	}
 
-	return snippetId, nil
+	stmt = `INSERT INTO snippet_tags (snippet_id, tag_id) SELECT $1, id FROM tags WHERE name = ANY($2)`
+	_, err = tx.Exec(stmt, snippetID, pq.Array(tags))
+	return err
 }
 
-// Mark snippet as Deleted. No actually removal is performed.
-func (m *SnippetModel) Delete(id int) (int, error) {
-	idStr := strconv.Itoa(id)
-	errorCode := 0
-	stmt := "UPDATE snippets SET deleted = true WHERE id =" + idStr + ";"
+// Change when a snippet expires. A zero time means the snippet never expires.
+func (m *SnippetModel) SetExpires(id int, expires time.Time) error {
+	stmt := `UPDATE snippets SET expires = NOW() + $2 * INTERVAL '1 SECOND' WHERE id = $1 AND ` + live
+	return m.exec(stmt, id, secondsFromNow(expires))
+}
+
+// Expiry columns are TIMESTAMPs in the database`s own time zone, and are compared with NOW(), so
+// expiry times are passed to them as a number of seconds from now and worked out in SQL. A zero time
+// gives NULL, which is how snippets and tokens that never expire are stored.
+func secondsFromNow(t time.Time) sql.NullFloat64 {
+	return sql.NullFloat64{Float64: time.Until(t).Seconds(), Valid: !t.IsZero()}
+}
 
-	_, err := m.DB.Prepare(stmt)
+// Replace the title, filename, content and further files of a live snippet, recording every save as
+// a new revision authored by the given user, even if nothing changed.
+func (m *SnippetModel) Update(s *models.Snippet, userID int) error {
+	tx, err := m.DB.Begin()
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
+	defer tx.Rollback()
 
-	_, err = m.DB.Exec(stmt)
+	// Updating the row also locks it until we commit, so concurrent edits of the same snippet
+	// are serialized and can`t be given the same revision number.
+	stmt := `UPDATE snippets SET title = $2, filename = $3, content = $4 WHERE id = $1 AND ` + live
+	result, err := tx.Exec(stmt, s.ID, s.Title, s.Filename, s.Content)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
 	if err != nil {
-		errorCode = 0
+		return err
 	}
-	return errorCode, nil
+	if n == 0 {
+		return models.ErrNoRecord
+	}
+
+	err = insertRevision(tx, s, userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM snippet_files WHERE snippet_id = $1`, s.ID)
+	if err != nil {
+		return err
+	}
+	err = insertFiles(tx, s.ID, s.Files)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Store the snippet as it is now as its next revision, together with its further files. Revisions
+// are numbered from 1 for every snippet.
+func insertRevision(tx *sql.Tx, s *models.Snippet, userID int) error {
+	stmt := `INSERT INTO snippet_revisions (snippet_id, version, user_id, title, filename, content, created)
+	SELECT $1, COALESCE(MAX(version), 0) + 1, $2, $3, $4, $5, NOW() FROM snippet_revisions WHERE snippet_id = $1
+	RETURNING id`
+
+	var revisionID int
+	err := tx.QueryRow(stmt, s.ID, userID, s.Title, s.Filename, s.Content).Scan(&revisionID)
+	if err != nil {
+		return err
+	}
+
+	stmt = `INSERT INTO snippet_revision_files (revision_id, position, name, language, content) VALUES($1, $2, $3, $4, $5)`
+	for i, f := range s.Files {
+		_, err = tx.Exec(stmt, revisionID, i+1, f.Name, f.Language, f.Content)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// The columns every revision query selects, in the order scanRevision() expects them.
+const revisionColumns = `r.id, r.snippet_id, r.version, COALESCE(r.user_id, 0), COALESCE(u.name, ''),
+	r.title, r.filename, r.content, r.created`
+
+func scanRevision(row scanner) (*models.Revision, error) {
+	rev := &models.Revision{}
+	err := row.Scan(&rev.ID, &rev.SnippetID, &rev.Version, &rev.UserID, &rev.UserName,
+		&rev.Title, &rev.Filename, &rev.Content, &rev.Created)
+	if err != nil {
+		return nil, err
+	}
+	return rev, nil
+}
+
+// This will return every revision of a snippet, newest first.
+func (m *SnippetModel) Revisions(id int) ([]*models.Revision, error) {
+	stmt := `SELECT ` + revisionColumns + ` FROM snippet_revisions r LEFT JOIN users u ON u.id = r.user_id
+	WHERE r.snippet_id = $1 ORDER BY r.version DESC`
+
+	rows, err := m.DB.Query(stmt, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []*models.Revision{}
+	for rows.Next() {
+		rev, err := scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, rev)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+// This will return a single revision of a snippet, with its further files.
+func (m *SnippetModel) Revision(id, version int) (*models.Revision, error) {
+	stmt := `SELECT ` + revisionColumns + ` FROM snippet_revisions r LEFT JOIN users u ON u.id = r.user_id
+	WHERE r.snippet_id = $1 AND r.version = $2`
+
+	rev, err := scanRevision(m.DB.QueryRow(stmt, id, version))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, err
+	}
+
+	rev.Files, err = m.revisionFiles(rev.ID)
+	if err != nil {
+		return nil, err
+	}
+	return rev, nil
+}
+
+// Mark snippet as Deleted. No actually removal is performed, so the owner can restore it from their trash.
+func (m *SnippetModel) Delete(id int) error {
+	stmt := `UPDATE snippets SET deleted = TRUE WHERE id = $1 AND deleted = FALSE`
+	return m.exec(stmt, id)
+}
+
+// Take a deleted snippet out of the trash. Only the owner of the snippet can restore it.
+func (m *SnippetModel) Restore(id, userID int) error {
+	stmt := `UPDATE snippets SET deleted = FALSE WHERE id = $1 AND user_id = $2 AND deleted = TRUE`
+	return m.exec(stmt, id, userID)
+}
+
+// Permanently remove a snippet which is already in the trash.
+func (m *SnippetModel) Purge(id int) error {
+	stmt := `DELETE FROM snippets WHERE id = $1 AND deleted = TRUE`
+	return m.exec(stmt, id)
+}
+
+// This will return the snippets which expired after from and no later than to, oldest expiry first.
+// Deleted snippets are left out, as they have already gone.
+func (m *SnippetModel) Expired(from, to time.Time) ([]*models.Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets WHERE expires > $1 AND expires <= $2 AND deleted = FALSE
+	ORDER BY expires, id`
+	return m.query(stmt, from, to)
+}
+
+// Permanently remove up to limit snippets which expired more than grace ago, or which have used up all
+// of their views, and return how many were removed. Rows locked by other transactions (a reader
+// counting a view, say) are skipped rather than waited for, so each call only holds its locks for as
+// long as it takes to delete one small batch.
+func (m *SnippetModel) Reap(grace time.Duration, limit int) (int, error) {
+	stmt := `DELETE FROM snippets WHERE id IN (
+		SELECT id FROM snippets
+		WHERE expires < NOW() - $1 * INTERVAL '1 SECOND' OR (max_views > 0 AND views >= max_views)
+		LIMIT $2 FOR UPDATE SKIP LOCKED)`
+
+	result, err := m.DB.Exec(stmt, grace.Seconds(), limit)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+// Execute a statement which is expected to affect exactly one snippet. If no rows were affected,
+// the snippet doesn`t exist (or isn`t in the right state) and models.ErrNoRecord is returned.
+func (m *SnippetModel) exec(stmt string, args ...interface{}) error {
+	result, err := m.DB.Exec(stmt, args...)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return models.ErrNoRecord
+	}
+	return nil
 }
 
 // This will return a specific snippet based on its id.
 func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
-	stmt := `SELECT id, title, content, created, expires FROM snippets WHERE expires > NOW() AND id = $1`
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets WHERE ` + live + ` AND id = $1`
 
 	// Use the QueryRow() method on the connection pool to execute the SQL statement,
 	// passing the untrusted id variable as the value for the placeholder parameter.
 	// This returns a pointer to a sql.Row object which holds the result from the database.
 	row := m.DB.QueryRow(stmt, id)
 
-	// Initialize a pointer to a new zeroed Snippet struct.
-	s := &models.Snippet{}
-
-	// Use row.Scan() to copy the values from each field in sql.Row to the corresponding field
-	// in the Snippet struct. Notice that the arguments to row.Scan are *pointers* to the place
-	// you want to copy the data into, and the number of arguments must be exactly the same as
-	// the number of columns returned by your statement.
-	err := row.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+	// Use scanSnippet() to copy the values from each field in sql.Row to the fields of a new Snippet struct.
+	s, err := scanSnippet(row)
 	if err != nil {
 		// If the query returns no rows, then row.Scan() will return sl.ErrNoRows error. We use
 		// the errors.IS() function check for that error  specifically, and return our own
@@ -90,19 +374,83 @@ func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
 	return s, nil
 }
 
-//This will return the 10 most recently created snippets.
-func (m *SnippetModel) Latest() ([]*models.Snippet, error) {
-	stmt := `SELECT id, title, content, created, expires FROM snippets WHERE expires > NOW() ORDER BY created DESC LIMIT 10`
+// This will return a snippet which hasn`t been deleted, whether or not it has expired. It is for
+// moving snippets to the trash, which their owner may want to do after they have expired.
+func (m *SnippetModel) Find(id int) (*models.Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets WHERE deleted = FALSE AND id = $1`
+	s, err := scanSnippet(m.DB.QueryRow(stmt, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, err
+	}
+	return s, nil
+}
+
+// This will return a specific snippet based on its slug.
+func (m *SnippetModel) GetBySlug(slug string) (*models.Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets WHERE ` + live + ` AND slug = $1`
+
+	s, err := scanSnippet(m.DB.QueryRow(stmt, slug))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, err
+	}
+	return s, nil
+}
+
+// This will return the snippet with the given slug and count the view. For snippets with a view
+// limit, the counter is incremented by the same statement which checks the limit, so two concurrent
+// readers can never both be shown the last remaining view of a snippet.
+func (m *SnippetModel) View(slug string) (*models.Snippet, error) {
+	s, err := m.GetBySlug(slug)
+	if err != nil || s.MaxViews == 0 {
+		return s, err
+	}
+
+	stmt := `UPDATE snippets SET views = views + 1 WHERE ` + live + ` AND slug = $1 RETURNING ` + snippetColumns
+	s, err = scanSnippet(m.DB.QueryRow(stmt, slug))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, err
+	}
+	return s, nil
+}
+
+// This will return all unexpired snippets created by the given user, newest first.
+func (m *SnippetModel) ByUser(userID int) ([]*models.Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets WHERE ` + live + ` AND user_id = $1 ORDER BY created DESC`
+	return m.query(stmt, userID)
+}
+
+// This will return the snippets the given user has moved to the trash.
+func (m *SnippetModel) Trash(userID int) ([]*models.Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets WHERE deleted = TRUE AND user_id = $1 ORDER BY created DESC`
+	return m.query(stmt, userID)
+}
+
+// This will return every deleted snippet, regardless of its owner. It backs the admin panel.
+func (m *SnippetModel) Deleted() ([]*models.Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets WHERE deleted = TRUE ORDER BY created DESC`
+	return m.query(stmt)
+}
 
+// Run a query returning snippetColumns and collect the results.
+func (m *SnippetModel) query(stmt string, args ...interface{}) ([]*models.Snippet, error) {
 	// Use the Query() method on the connection pool to execute our SQL statement.
 	// This returns a sql.Rows resultset containing the result of the query.
-	rows, err := m.DB.Query(stmt)
+	rows, err := m.DB.Query(stmt, args...)
 	if err != nil {
 		return nil, err
 	}
 
 	// We defer rows.Close() to ensure the sql.Rows resultset is always property closed
-	// before the Latest() method returns. This defer statement should come *after*
+	// before the method returns. This defer statement should come *after*
 	// you check an error  from the Query() method. Otherwise, if Query() returns an error,
 	// you`ll get a panic trying to close a nil resultset.
 	defer rows.Close()
@@ -115,13 +463,7 @@ func (m *SnippetModel) Latest() ([]*models.Snippet, error) {
 	// iteration iteration over all the rows completes then the resultset automatically
 	// closes itself and frees-up the underlying database connection.
 	for rows.Next() {
-		// Create a pointer to a new zeroed Snippet struct.
-		s := &models.Snippet{}
-		// Use rows.Scan() to copy the values from each field in the row to the new
-		// Snippet object that we created. Again, the arguments to row.Scan() must be
-		// pointers to the place you want to copy the data into, and the number of arguments
-		// must be exactly the same as the number of columns returned by the statement.
-		err = rows.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+		s, err := scanSnippet(rows)
 		if err != nil {
 			return nil, err
 		}
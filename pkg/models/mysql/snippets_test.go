@@ -0,0 +1,64 @@
+package mysql
+
+import (
+	"strings"
+	"testing"
+
+	"sabiraliyev.net/snippetbox/pkg/models"
+)
+
+func TestNewSlug(t *testing.T) {
+	seen := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		slug, err := newSlug()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(slug) != slugLength {
+			t.Errorf("want slug of length %d; got %q", slugLength, slug)
+		}
+
+		for _, c := range slug {
+			if !strings.ContainsRune(slugAlphabet, c) {
+				t.Errorf("want URL-safe characters only; got %q in %q", c, slug)
+			}
+		}
+
+		if seen[slug] {
+			t.Errorf("want unique slugs; got %q twice", slug)
+		}
+		seen[slug] = true
+	}
+}
+
+func TestCursor(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		id    int
+	}{
+		{"Time", "2021-03-01 12:30:45.123456", 42},
+		{"Infinity", "infinity", 7},
+		{"Title with separator", "a|b|c", 3},
+		{"Empty", "", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, id, err := decodeCursor(encodeCursor(tt.value, tt.id))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if value != tt.value || id != tt.id {
+				t.Errorf("want %q, %d; got %q, %d", tt.value, tt.id, value, id)
+			}
+		})
+	}
+
+	for _, cursor := range []string{"!!!", "bm9pZA", "eHwweA"} {
+		if _, _, err := decodeCursor(cursor); err != models.ErrInvalidCursor {
+			t.Errorf("want ErrInvalidCursor for %q; got %v", cursor, err)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package mysql
+
+import (
+	"database/sql"
+
+	"sabiraliyev.net/snippetbox/pkg/models"
+)
+
+// TagModel wraps a sql.DB connection pool. Tags are attached to snippets by SnippetModel.Insert, and
+// snippets with a tag are listed by SnippetModel.List, so this only deals with the tags themselves.
+type TagModel struct {
+	DB *sql.DB
+}
+
+// Cloud returns the limit tags used by the most live public snippets, in alphabetical order. Like
+// listings, it leaves out view-limited snippets.
+func (m *TagModel) Cloud(limit int) ([]*models.Tag, error) {
+	stmt := `SELECT name, count FROM (
+		SELECT t.name, COUNT(*) AS count FROM tags t
+		JOIN snippet_tags st ON st.tag_id = t.id
+		JOIN snippets ON snippets.id = st.snippet_id
+		WHERE ` + live + ` AND visibility = 'public' AND max_views = 0
+		GROUP BY t.name ORDER BY count DESC, t.name LIMIT $1
+	) top ORDER BY name`
+
+	rows, err := m.DB.Query(stmt, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []*models.Tag{}
+	for rows.Next() {
+		t := &models.Tag{}
+		err = rows.Scan(&t.Name, &t.Count)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
@@ -0,0 +1,121 @@
+package mysql
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+
+	"sabiraliyev.net/snippetbox/pkg/models"
+)
+
+// The prefix every API token starts with, which makes tokens easy to recognise (and to find in
+// places they shouldn`t have been pasted).
+const tokenPrefix = "sb_"
+
+// TokenModel wraps a sql.DB connection pool and manages personal API tokens.
+type TokenModel struct {
+	DB *sql.DB
+}
+
+// Tokens are looked up by the SHA-256 hash of their text. Tokens hold 256 random bits, so there is
+// no need for a slow password hash like bcrypt.
+func hashToken(token string) []byte {
+	sum := sha256.Sum256([]byte(token))
+	return sum[:]
+}
+
+// The columns every token query selects, in the order scanToken() expects them.
+const tokenColumns = `id, user_id, name, scopes, created, expires, last_used`
+
+func scanToken(row scanner) (*models.Token, error) {
+	t := &models.Token{}
+	var expires, lastUsed sql.NullTime
+	err := row.Scan(&t.ID, &t.UserID, &t.Name, pq.Array(&t.Scopes), &t.Created, &expires, &lastUsed)
+	if err != nil {
+		return nil, err
+	}
+	t.Expires = expires.Time
+	t.LastUsed = lastUsed.Time
+	return t, nil
+}
+
+// Insert creates a new token for a user, with the given scopes, and returns its text. This is the
+// only time the text is available, as only its hash is stored. A zero expiry time means the token
+// never expires.
+func (m *TokenModel) Insert(userID int, name string, scopes []string, expires time.Time) (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	token := tokenPrefix + base64.RawURLEncoding.EncodeToString(b)
+
+	stmt := `INSERT INTO api_tokens (user_id, name, hash, scopes, created, expires) VALUES($1, $2, $3, $4, NOW(),
+	NOW() + $5 * INTERVAL '1 SECOND')`
+	_, err = m.DB.Exec(stmt, userID, name, hashToken(token), pq.Array(scopes), secondsFromNow(expires))
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// Authenticate returns the token with the given text, recording that it has been used. It returns
+// ErrInvalidCredentials if there is no such token or it has expired.
+func (m *TokenModel) Authenticate(token string) (*models.Token, error) {
+	stmt := `UPDATE api_tokens SET last_used = NOW() WHERE hash = $1 AND (expires IS NULL OR expires > NOW())
+	RETURNING ` + tokenColumns
+	t, err := scanToken(m.DB.QueryRow(stmt, hashToken(token)))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrInvalidCredentials
+		}
+		return nil, err
+	}
+	return t, nil
+}
+
+// ByUser returns a user`s tokens, newest first. Expired tokens are included, so users can see
+// which of their tokens have stopped working.
+func (m *TokenModel) ByUser(userID int) ([]*models.Token, error) {
+	stmt := `SELECT ` + tokenColumns + ` FROM api_tokens WHERE user_id = $1 ORDER BY created DESC, id DESC`
+
+	rows, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []*models.Token{}
+	for rows.Next() {
+		t, err := scanToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// Delete revokes one of a user`s tokens. It returns ErrNoRecord if the user has no such token.
+func (m *TokenModel) Delete(id, userID int) error {
+	result, err := m.DB.Exec(`DELETE FROM api_tokens WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return models.ErrNoRecord
+	}
+	return nil
+}
@@ -90,3 +90,19 @@ func (m *UserModel) Get(id int) (*models.User, error) {
 	}
 	return u, nil
 }
+
+// GetByEmail returns the active user with the given email address, or ErrNoRecord if there is none.
+// It is used to find people to talk to in the chat, so deactivated users can`t be found.
+func (m *UserModel) GetByEmail(email string) (*models.User, error) {
+	u := &models.User{}
+
+	stmt := `SELECT id, name, email, created, active, administrator FROM users WHERE email = $1 AND active = TRUE`
+	err := m.DB.QueryRow(stmt, email).Scan(&u.ID, &u.Name, &u.Email, &u.Created, &u.Active, &u.Administrator)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, err
+	}
+	return u, nil
+}
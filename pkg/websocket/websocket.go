@@ -0,0 +1,417 @@
+// Package websocket implements the server side of the WebSocket protocol (RFC 6455), using nothing
+// but the standard library. It covers what the chat needs: the opening handshake, reading masked
+// client frames (including fragmented messages), writing unfragmented messages, ping/pong and the
+// closing handshake. Extensions and subprotocols aren`t supported.
+package websocket
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// The message types, which are the opcodes of the frames they are sent in.
+const (
+	continuationFrame = 0
+	TextMessage       = 1
+	BinaryMessage     = 2
+	CloseMessage      = 8
+	PingMessage       = 9
+	PongMessage       = 10
+)
+
+// The status codes sent in close frames.
+const (
+	CloseNormalClosure    = 1000
+	CloseGoingAway        = 1001
+	CloseProtocolError    = 1002
+	CloseUnsupportedData  = 1003
+	CloseNoStatusReceived = 1005
+	CloseInvalidPayload   = 1007
+	ClosePolicyViolation  = 1008
+	CloseMessageTooBig    = 1009
+)
+
+// The GUID the Sec-WebSocket-Key of a handshake is combined with to make the Sec-WebSocket-Accept
+// response header.
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// The defaults for the limits of a new connection.
+const (
+	defaultReadLimit    = 64 << 10
+	defaultWriteTimeout = 10 * time.Second
+)
+
+var (
+	// ErrBadHandshake is returned by Upgrade when a request isn`t a valid WebSocket handshake.
+	ErrBadHandshake = errors.New("websocket: bad handshake")
+	// ErrMessageTooBig is returned by ReadMessage when a message is bigger than the read limit.
+	ErrMessageTooBig = errors.New("websocket: message too big")
+	// ErrProtocol is returned by ReadMessage when the peer breaks the protocol.
+	ErrProtocol = errors.New("websocket: protocol error")
+	// ErrInvalidUTF8 is returned by ReadMessage when a text message isn`t valid UTF-8.
+	ErrInvalidUTF8 = errors.New("websocket: invalid UTF-8 in text message")
+	// ErrClosed is returned when writing to a connection after its close frame has been sent.
+	ErrClosed = errors.New("websocket: connection closed")
+)
+
+// A CloseError is returned by ReadMessage when the peer closes the connection. Code is the status
+// code from its close frame, or CloseNoStatusReceived if there was none.
+type CloseError struct {
+	Code int
+	Text string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("websocket: closed by peer (%d %s)", e.Code, e.Text)
+}
+
+// A Conn is a WebSocket connection. One goroutine may read from it while others write to it:
+// writes are serialised, including the pongs and close frames sent on behalf of the reader.
+type Conn struct {
+	conn net.Conn
+	br   *bufio.Reader
+
+	// ReadLimit is the size of the largest message ReadMessage accepts, in bytes.
+	ReadLimit int64
+	// WriteTimeout is how long a write may take before it fails and the connection is broken.
+	WriteTimeout time.Duration
+
+	pongHandler func()
+
+	mu         sync.Mutex // Guards writes and closeSent.
+	closeSent  bool
+	closeOnce  sync.Once
+	closeError error
+}
+
+// Upgrade performs the opening handshake for a WebSocket request and returns the connection. If the
+// request isn`t a valid handshake, an error response is sent and ErrBadHandshake is returned.
+//
+// Requests from pages on other sites are refused, so that a third party page can`t use the visitor`s
+// cookies to open a connection on their behalf: if there is an Origin header, its host must be the
+// host the request was sent to.
+//
+// The deadlines the http.Server set on the underlying connection are cleared, as they are meant
+// for a single request rather than a long-lived connection.
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	fail := func(status int, reason string) (*Conn, error) {
+		if status == http.StatusUpgradeRequired {
+			w.Header().Set("Sec-WebSocket-Version", "13")
+		}
+		http.Error(w, http.StatusText(status), status)
+		return nil, fmt.Errorf("%w: %s", ErrBadHandshake, reason)
+	}
+
+	if r.Method != http.MethodGet {
+		return fail(http.StatusMethodNotAllowed, "method is not GET")
+	}
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") {
+		return fail(http.StatusBadRequest, "not a websocket upgrade")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		return fail(http.StatusUpgradeRequired, "unsupported version")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if b, err := base64.StdEncoding.DecodeString(key); err != nil || len(b) != 16 {
+		return fail(http.StatusBadRequest, "invalid Sec-WebSocket-Key")
+	}
+	if origin := r.Header.Get("Origin"); origin != "" {
+		u, err := url.Parse(origin)
+		if err != nil || !strings.EqualFold(u.Host, r.Host) {
+			return fail(http.StatusForbidden, "cross-origin request")
+		}
+	}
+
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		return fail(http.StatusInternalServerError, "connection can`t be hijacked")
+	}
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + AcceptKey(key) + "\r\n\r\n"
+	conn.SetWriteDeadline(time.Now().Add(defaultWriteTimeout))
+	_, err = conn.Write([]byte(response))
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetWriteDeadline(time.Time{})
+
+	return &Conn{
+		conn:         conn,
+		br:           rw.Reader,
+		ReadLimit:    defaultReadLimit,
+		WriteTimeout: defaultWriteTimeout,
+	}, nil
+}
+
+// AcceptKey returns the Sec-WebSocket-Accept header for a handshake with the given Sec-WebSocket-Key.
+func AcceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// The headerContains function reports whether a comma separated header includes a token, ignoring case.
+func headerContains(h http.Header, name, token string) bool {
+	for _, v := range h[http.CanonicalHeaderKey(name)] {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SetReadDeadline sets the deadline for reading the next message. Reads after it has passed fail.
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+// SetPongHandler sets a function called whenever a pong arrives, typically to extend the read deadline.
+// It is called by ReadMessage, on the reading goroutine.
+func (c *Conn) SetPongHandler(h func()) {
+	c.pongHandler = h
+}
+
+// ReadMessage reads the next text or binary message, putting fragmented messages back together.
+// Pings are answered and pongs passed to the pong handler on the way. If the peer closes the
+// connection, the close frame is echoed and a *CloseError is returned. If the peer breaks the
+// protocol, a close frame saying why is sent and an error is returned; the connection can`t be read
+// from again either way.
+func (c *Conn) ReadMessage() (int, []byte, error) {
+	var messageType int
+	var message []byte
+	for {
+		fin, op, payload, err := c.readFrame()
+		if err != nil {
+			return 0, nil, c.fail(err)
+		}
+
+		switch op {
+		case PingMessage:
+			err = c.write(PongMessage, payload)
+			if err != nil && !errors.Is(err, ErrClosed) {
+				return 0, nil, err
+			}
+			continue
+		case PongMessage:
+			if c.pongHandler != nil {
+				c.pongHandler()
+			}
+			continue
+		case CloseMessage:
+			return 0, nil, c.closedByPeer(payload)
+		case TextMessage, BinaryMessage:
+			if messageType != 0 {
+				return 0, nil, c.fail(fmt.Errorf("%w: new message in the middle of a fragmented one", ErrProtocol))
+			}
+			messageType = op
+		case continuationFrame:
+			if messageType == 0 {
+				return 0, nil, c.fail(fmt.Errorf("%w: continuation frame without a message", ErrProtocol))
+			}
+		default:
+			return 0, nil, c.fail(fmt.Errorf("%w: unknown opcode %d", ErrProtocol, op))
+		}
+
+		if int64(len(message)+len(payload)) > c.ReadLimit {
+			return 0, nil, c.fail(ErrMessageTooBig)
+		}
+		message = append(message, payload...)
+		if fin {
+			if messageType == TextMessage && !utf8.Valid(message) {
+				return 0, nil, c.fail(ErrInvalidUTF8)
+			}
+			return messageType, message, nil
+		}
+	}
+}
+
+// The readFrame method reads a single frame and unmasks its payload. Clients must mask every frame,
+// control frames must fit in a single frame of up to 125 bytes, and no extensions are negotiated, so
+// the reserved bits must be clear.
+func (c *Conn) readFrame() (fin bool, op int, payload []byte, err error) {
+	var header [2]byte
+	_, err = io.ReadFull(c.br, header[:])
+	if err != nil {
+		return false, 0, nil, err
+	}
+	fin = header[0]&0x80 != 0
+	op = int(header[0] & 0x0f)
+	masked := header[1]&0x80 != 0
+	length := uint64(header[1] & 0x7f)
+
+	if header[0]&0x70 != 0 {
+		return false, 0, nil, fmt.Errorf("%w: reserved bits set", ErrProtocol)
+	}
+	if !masked {
+		return false, 0, nil, fmt.Errorf("%w: unmasked client frame", ErrProtocol)
+	}
+
+	switch length {
+	case 126:
+		var b [2]byte
+		_, err = io.ReadFull(c.br, b[:])
+		length = uint64(binary.BigEndian.Uint16(b[:]))
+	case 127:
+		var b [8]byte
+		_, err = io.ReadFull(c.br, b[:])
+		length = binary.BigEndian.Uint64(b[:])
+	}
+	if err != nil {
+		return false, 0, nil, err
+	}
+
+	if op >= CloseMessage && (length > 125 || !fin) {
+		return false, 0, nil, fmt.Errorf("%w: invalid control frame", ErrProtocol)
+	}
+	if length > uint64(c.ReadLimit) {
+		return false, 0, nil, ErrMessageTooBig
+	}
+
+	var mask [4]byte
+	_, err = io.ReadFull(c.br, mask[:])
+	if err != nil {
+		return false, 0, nil, err
+	}
+	payload = make([]byte, length)
+	_, err = io.ReadFull(c.br, payload)
+	if err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, op, payload, nil
+}
+
+// The fail method sends a close frame explaining why a connection is being given up on, for errors
+// which are the peer`s fault, and returns the error.
+func (c *Conn) fail(err error) error {
+	code := 0
+	switch {
+	case errors.Is(err, ErrProtocol):
+		code = CloseProtocolError
+	case errors.Is(err, ErrMessageTooBig):
+		code = CloseMessageTooBig
+	case errors.Is(err, ErrInvalidUTF8):
+		code = CloseInvalidPayload
+	}
+	if code != 0 {
+		c.WriteClose(code, "")
+	}
+	return err
+}
+
+// The closedByPeer method handles a close frame from the peer, echoing its status code as the
+// closing handshake requires, and returns the matching *CloseError.
+func (c *Conn) closedByPeer(payload []byte) error {
+	e := &CloseError{Code: CloseNoStatusReceived}
+	if len(payload) == 1 {
+		return c.fail(fmt.Errorf("%w: invalid close frame", ErrProtocol))
+	}
+	if len(payload) >= 2 {
+		e.Code = int(binary.BigEndian.Uint16(payload))
+		e.Text = string(payload[2:])
+	}
+
+	if e.Code == CloseNoStatusReceived {
+		c.WriteClose(CloseNormalClosure, "")
+	} else {
+		c.WriteClose(e.Code, "")
+	}
+	return e
+}
+
+// WriteMessage sends a text or binary message in a single frame.
+func (c *Conn) WriteMessage(messageType int, data []byte) error {
+	if messageType != TextMessage && messageType != BinaryMessage {
+		return fmt.Errorf("websocket: invalid message type %d", messageType)
+	}
+	return c.write(messageType, data)
+}
+
+// WritePing sends a ping, which the peer should answer with a pong.
+func (c *Conn) WritePing(data []byte) error {
+	return c.write(PingMessage, data)
+}
+
+// WriteClose starts (or completes) the closing handshake by sending a close frame with a status code
+// and reason. Nothing can be written after it; calling it again does nothing.
+func (c *Conn) WriteClose(code int, text string) error {
+	payload := make([]byte, 2, 2+len(text))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	payload = append(payload, text...)
+	if len(payload) > 125 {
+		payload = payload[:125]
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closeSent {
+		return nil
+	}
+	c.closeSent = true
+	return c.writeFrame(CloseMessage, payload)
+}
+
+// The write method sends a frame, unless the close frame has already gone.
+func (c *Conn) write(op int, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closeSent {
+		return ErrClosed
+	}
+	return c.writeFrame(op, data)
+}
+
+// The writeFrame method sends a single, final, unmasked frame; servers never mask their frames. It
+// must be called with c.mu held.
+func (c *Conn) writeFrame(op int, data []byte) error {
+	frame := make([]byte, 0, 10+len(data))
+	frame = append(frame, 0x80|byte(op))
+	switch n := len(data); {
+	case n <= 125:
+		frame = append(frame, byte(n))
+	case n <= 0xffff:
+		frame = append(frame, 126, byte(n>>8), byte(n))
+	default:
+		frame = append(frame, 127)
+		frame = append(frame, make([]byte, 8)...)
+		binary.BigEndian.PutUint64(frame[2:], uint64(n))
+	}
+	frame = append(frame, data...)
+
+	c.conn.SetWriteDeadline(time.Now().Add(c.WriteTimeout))
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+// Close closes the underlying network connection, without a closing handshake. Call WriteClose first
+// to close the connection cleanly.
+func (c *Conn) Close() error {
+	c.closeOnce.Do(func() {
+		c.closeError = c.conn.Close()
+	})
+	return c.closeError
+}
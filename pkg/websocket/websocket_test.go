@@ -0,0 +1,243 @@
+package websocket
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAcceptKey(t *testing.T) {
+	// The example handshake from section 1.3 of RFC 6455.
+	got := AcceptKey("dGhlIHNhbXBsZSBub25jZQ==")
+	if want := "s3pPLMBiTxaQ9kYGzzhZRbK+xOo="; got != want {
+		t.Errorf("want %q; got %q", want, got)
+	}
+}
+
+// The testClient type is a minimal WebSocket client, speaking just enough of the protocol to test
+// the server side with.
+type testClient struct {
+	conn net.Conn
+	br   *bufio.Reader
+}
+
+// The dial function opens a connection to the test server and performs the opening handshake,
+// returning the status code of the response.
+func dial(t *testing.T, ts *httptest.Server, header http.Header) (*testClient, int) {
+	conn, err := net.Dial("tcp", strings.TrimPrefix(ts.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/ws", nil)
+	req.Header.Set("Connection", "keep-alive, Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	err = req.Write(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	br := bufio.NewReader(conn)
+	rs, err := http.ReadResponse(br, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rs.StatusCode == http.StatusSwitchingProtocols {
+		if got := rs.Header.Get("Sec-WebSocket-Accept"); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+			t.Errorf("want the accept key of the RFC example; got %q", got)
+		}
+	}
+	return &testClient{conn: conn, br: br}, rs.StatusCode
+}
+
+// The send method writes a frame, masked as clients must unless masked is false.
+func (c *testClient) send(t *testing.T, fin bool, op int, payload []byte, masked bool) {
+	b0 := byte(op)
+	if fin {
+		b0 |= 0x80
+	}
+	frame := []byte{b0}
+	maskBit := byte(0)
+	if masked {
+		maskBit = 0x80
+	}
+	switch n := len(payload); {
+	case n <= 125:
+		frame = append(frame, maskBit|byte(n))
+	default:
+		frame = append(frame, maskBit|126, byte(n>>8), byte(n))
+	}
+	if masked {
+		mask := []byte{1, 2, 3, 4}
+		frame = append(frame, mask...)
+		for i, b := range payload {
+			frame = append(frame, b^mask[i%4])
+		}
+	} else {
+		frame = append(frame, payload...)
+	}
+	_, err := c.conn.Write(frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// The receive method reads an unmasked frame from the server.
+func (c *testClient) receive(t *testing.T) (int, []byte) {
+	var header [2]byte
+	_, err := io.ReadFull(c.br, header[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := int(header[1] & 0x7f)
+	if n == 126 {
+		var b [2]byte
+		io.ReadFull(c.br, b[:])
+		n = int(binary.BigEndian.Uint16(b[:]))
+	}
+	payload := make([]byte, n)
+	_, err = io.ReadFull(c.br, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return int(header[0] & 0x0f), payload
+}
+
+// The newEchoServer function starts a server which echoes messages back until the connection is
+// closed, and reports the error which ended the connection.
+func newEchoServer(t *testing.T) (*httptest.Server, chan error) {
+	done := make(chan error, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.ReadLimit = 1000
+
+		for {
+			op, msg, err := conn.ReadMessage()
+			if err != nil {
+				done <- err
+				return
+			}
+			conn.WriteMessage(op, msg)
+		}
+	}))
+	return ts, done
+}
+
+func TestEcho(t *testing.T) {
+	ts, done := newEchoServer(t)
+	defer ts.Close()
+
+	c, code := dial(t, ts, nil)
+	if code != http.StatusSwitchingProtocols {
+		t.Fatalf("want %d; got %d", http.StatusSwitchingProtocols, code)
+	}
+
+	c.send(t, true, TextMessage, []byte("Hello"), true)
+	if op, msg := c.receive(t); op != TextMessage || string(msg) != "Hello" {
+		t.Errorf("want text %q; got %d %q", "Hello", op, msg)
+	}
+
+	// A fragmented message, with a ping in the middle of it.
+	long := strings.Repeat("x", 200)
+	c.send(t, false, TextMessage, []byte("Hel"), true)
+	c.send(t, true, PingMessage, []byte("ping"), true)
+	c.send(t, true, continuationFrame, []byte("lo "+long), true)
+	if op, msg := c.receive(t); op != PongMessage || string(msg) != "ping" {
+		t.Errorf("want pong %q; got %d %q", "ping", op, msg)
+	}
+	if op, msg := c.receive(t); op != TextMessage || string(msg) != "Hello "+long {
+		t.Errorf("want the fragments put together; got %d %q", op, msg)
+	}
+
+	// The closing handshake: the server echoes the status code.
+	c.send(t, true, CloseMessage, []byte{0x03, 0xe8, 'b', 'y', 'e'}, true)
+	op, msg := c.receive(t)
+	if op != CloseMessage || binary.BigEndian.Uint16(msg) != CloseNormalClosure {
+		t.Errorf("want close %d; got %d %v", CloseNormalClosure, op, msg)
+	}
+	var closeErr *CloseError
+	if err := <-done; !errors.As(err, &closeErr) || closeErr.Code != CloseNormalClosure || closeErr.Text != "bye" {
+		t.Errorf("want a CloseError with code %d; got %v", CloseNormalClosure, err)
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		fin      bool
+		op       int
+		payload  []byte
+		masked   bool
+		wantCode uint16
+		wantErr  error
+	}{
+		{"Unmasked", true, TextMessage, []byte("Hello"), false, CloseProtocolError, ErrProtocol},
+		{"Too big", true, BinaryMessage, make([]byte, 1001), true, CloseMessageTooBig, ErrMessageTooBig},
+		{"Invalid UTF-8", true, TextMessage, []byte{0xff, 0xfe}, true, CloseInvalidPayload, ErrInvalidUTF8},
+		{"Fragmented ping", false, PingMessage, nil, true, CloseProtocolError, ErrProtocol},
+		{"Unknown opcode", true, 3, nil, true, CloseProtocolError, ErrProtocol},
+		{"Stray continuation", true, continuationFrame, []byte("x"), true, CloseProtocolError, ErrProtocol},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts, done := newEchoServer(t)
+			defer ts.Close()
+
+			c, _ := dial(t, ts, nil)
+			c.send(t, tt.fin, tt.op, tt.payload, tt.masked)
+
+			op, msg := c.receive(t)
+			if op != CloseMessage || binary.BigEndian.Uint16(msg) != tt.wantCode {
+				t.Errorf("want close %d; got %d %v", tt.wantCode, op, msg)
+			}
+			if err := <-done; !errors.Is(err, tt.wantErr) {
+				t.Errorf("want error %v; got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestBadHandshake(t *testing.T) {
+	ts, _ := newEchoServer(t)
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		header   http.Header
+		wantCode int
+	}{
+		{"Cross-origin", http.Header{"Origin": {"https://evil.example.com"}}, http.StatusForbidden},
+		{"Same origin", http.Header{"Origin": {ts.URL}}, http.StatusSwitchingProtocols},
+		{"Old version", http.Header{"Sec-Websocket-Version": {"8"}}, http.StatusUpgradeRequired},
+		{"Bad key", http.Header{"Sec-Websocket-Key": {"short"}}, http.StatusBadRequest},
+		{"Not an upgrade", http.Header{"Upgrade": {"h2c"}}, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, code := dial(t, ts, tt.header)
+			defer c.conn.Close()
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+		})
+	}
+}
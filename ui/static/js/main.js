@@ -5,4 +5,80 @@ for (var i = 0; i < navLinks.length; i++) {
 		link.classList.add("live");
 		break;
 	}
-}
\ No newline at end of file
+}
+// Deliver chat messages live over a WebSocket, when the latest messages are being shown to a signed in
+// user. Messages are posted over the socket too; if it isn`t open, the form is submitted as usual.
+// Messages in the room being shown are marked read as they arrive, and messages in the user`s other
+// rooms bump their unread counters.
+var chatbox = document.getElementById("chatbox");
+var chatForm = document.querySelector("form[name='message']");
+if (chatbox && chatForm && !/[?&]before=/.test(window.location.search) && window.WebSocket) {
+	var chatSocket = null;
+	var chatInput = document.getElementById("usermsg");
+	var chatRoom = parseInt(chatbox.dataset.room, 10);
+
+	var countUnread = function(msg) {
+		var unread = document.querySelector("#room-" + msg.room_id + " .unread");
+		if (unread) {
+			unread.textContent = parseInt(unread.textContent, 10) + 1;
+			unread.hidden = false;
+		}
+	};
+
+	var showMessage = function(msg) {
+		if (document.getElementById("message-" + msg.id)) {
+			return;
+		}
+		var empty = chatbox.querySelector(".empty");
+		if (empty) {
+			empty.remove();
+		}
+
+		var div = document.createElement("div");
+		div.className = "message";
+		div.id = "message-" + msg.id;
+		var author = document.createElement("span");
+		author.className = "author";
+		author.textContent = msg.user_name;
+		var time = document.createElement("time");
+		time.textContent = new Date(msg.created).toLocaleString();
+		var content = document.createElement("p");
+		content.textContent = msg.content;
+		div.appendChild(author);
+		div.appendChild(time);
+		div.appendChild(content);
+		chatbox.appendChild(div);
+		div.scrollIntoView();
+	};
+
+	var connectChat = function() {
+		var scheme = window.location.protocol === "https:" ? "wss://" : "ws://";
+		chatSocket = new WebSocket(scheme + window.location.host + "/chat/ws");
+		chatSocket.onmessage = function(e) {
+			var event = JSON.parse(e.data);
+			if (event.type === "message" && event.message.room_id !== chatRoom) {
+				countUnread(event.message);
+			} else if (event.type === "message") {
+				showMessage(event.message);
+				chatSocket.send(JSON.stringify({type: "read", room: chatRoom, message: event.message.id}));
+			} else if (event.type === "error") {
+				window.alert(event.error);
+			}
+		};
+		chatSocket.onclose = function() {
+			// Try again in a little while, in case the server was restarted.
+			chatSocket = null;
+			window.setTimeout(connectChat, 5000);
+		};
+	};
+	connectChat();
+
+	chatForm.addEventListener("submit", function(e) {
+		if (!chatSocket || chatSocket.readyState !== WebSocket.OPEN) {
+			return;
+		}
+		e.preventDefault();
+		chatSocket.send(JSON.stringify({room: chatRoom, content: chatInput.value}));
+		chatInput.value = "";
+	});
+}